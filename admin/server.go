@@ -0,0 +1,237 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var _ http.Handler = new(Server)
+
+// DefaultPageLimit is the number of execution records per page
+// when the limit query parameter is not given.
+const DefaultPageLimit = 20
+
+// Server is the HTTP admin API of WolfX.
+// All requests and responses are JSON.
+//
+//	GET  /jobs                        lists registered jobs
+//	POST /jobs/{name}/executions      starts the job with {"parameters": {...}}
+//	GET  /executions?job=&offset=&limit=
+//	                                  pages through execution history
+//	GET  /executions/running          lists running executions with step progress
+//	GET  /executions/{id}             shows the execution
+//	POST /executions/{id}/stop        requests the execution to stop gracefully
+type Server struct {
+	wx *wolfx.WolfX
+}
+
+// Job is the response of a registered job.
+type Job struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+}
+
+// LaunchRequest is the request body to start a job.
+type LaunchRequest struct {
+	Parameters middleware.JobParameters `json:"parameters"`
+}
+
+// ErrorResponse is the response body of errors.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(wx *wolfx.WolfX) *Server {
+	return &Server{
+		wx: wx,
+	}
+}
+
+// ListenAndServe serves the admin API on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "jobs":
+		s.allow(w, r, http.MethodGet, s.listJobs)
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "executions":
+		s.allow(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			s.launch(w, r, parts[1])
+		})
+	case path == "executions":
+		s.allow(w, r, http.MethodGet, s.listExecutions)
+	case path == "executions/running":
+		s.allow(w, r, http.MethodGet, s.listRunningExecutions)
+	case len(parts) == 2 && parts[0] == "executions":
+		s.allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			s.getExecution(w, r, parts[1])
+		})
+	case len(parts) == 3 && parts[0] == "executions" && parts[2] == "stop":
+		s.allow(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			s.stop(w, r, parts[1])
+		})
+	default:
+		writeError(w, http.StatusNotFound, errors.New("Not found path: "+r.URL.Path))
+	}
+}
+
+func (s *Server) allow(w http.ResponseWriter, r *http.Request, method string,
+	handler http.HandlerFunc) {
+
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed,
+			errors.New("Not allowed method: "+r.Method))
+		return
+	}
+	handler(w, r)
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	running := make(map[string]bool)
+	for _, je := range s.wx.RunningExecutions() {
+		running[je.JobName] = true
+	}
+
+	jobs := make([]Job, 0, len(s.wx.JobExecutors))
+	for _, e := range s.wx.JobExecutors {
+		jobs = append(jobs, Job{
+			Name:    e.Name(),
+			Running: running[e.Name()],
+		})
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) launch(w http.ResponseWriter, r *http.Request, jobName string) {
+	req := new(LaunchRequest)
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	je, err := s.wx.Launch(jobName, req.Parameters)
+	switch {
+	case errors.Is(err, wolfx.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, je.Record())
+}
+
+func (s *Server) listExecutions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := wolfx.JobExecutionQuery{
+		JobName: query.Get("job"),
+		Limit:   DefaultPageLimit,
+	}
+	var err error
+	if v := query.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("Invalid offset: "+v))
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			writeError(w, http.StatusBadRequest, errors.New("Invalid limit: "+v))
+			return
+		}
+	}
+
+	page, err := s.wx.FindJobExecutions(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) listRunningExecutions(w http.ResponseWriter, r *http.Request) {
+	executions := s.wx.RunningExecutions()
+	records := make([]middleware.JobExecutionRecord, 0, len(executions))
+	for _, je := range executions {
+		records = append(records, je.Record())
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (s *Server) getExecution(w http.ResponseWriter, r *http.Request, idStr string) {
+	id, ok := parseID(w, idStr)
+	if !ok {
+		return
+	}
+	rec, err := s.wx.FindJobExecution(id)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
+
+func (s *Server) stop(w http.ResponseWriter, r *http.Request, idStr string) {
+	id, ok := parseID(w, idStr)
+	if !ok {
+		return
+	}
+	if err := s.wx.Stop(id); err != nil {
+		if _, errFind := s.wx.FindJobExecution(id); errFind == nil {
+			writeError(w, http.StatusConflict, errors.New("Not running job execution: "+idStr))
+			return
+		}
+		writeRepositoryError(w, err)
+		return
+	}
+	rec, err := s.wx.FindJobExecution(id)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, rec)
+}
+
+func parseID(w http.ResponseWriter, idStr string) (int64, bool) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("Invalid execution id: "+idStr))
+		return 0, false
+	}
+	return id, true
+}
+
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, wolfx.ErrJobExecutionNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		middleware.LoggerFromContext(context.Background()).
+			Error("Failed to write response: ", err)
+	}
+}
@@ -0,0 +1,231 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type EchoJob struct{}
+
+func (j *EchoJob) Name() string {
+	return "EchoJob"
+}
+
+func (j *EchoJob) Run() error {
+	return j.RunContext(context.Background())
+}
+
+func (j *EchoJob) RunContext(ctx context.Context) error {
+	return wolfx.NewJobBuilderContext(ctx).
+		Single(j.EchoStep).
+		Build()
+}
+
+func (j *EchoJob) EchoStep(ctx context.Context) error {
+	return wolfx.NewStepBuilder(ctx).
+		SetReader(new(EchoReader)).
+		SetWriter(new(DiscardWriter)).
+		Build()
+}
+
+type EchoReader struct{}
+
+func (r *EchoReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+	params := middleware.JobParametersFromContext(ctx)
+	ch <- []string{params["message"]}
+	return nil
+}
+
+// EndlessJob runs until it is stopped.
+type EndlessJob struct{}
+
+func (j *EndlessJob) Name() string {
+	return "EndlessJob"
+}
+
+func (j *EndlessJob) Run() error {
+	return j.RunContext(context.Background())
+}
+
+func (j *EndlessJob) RunContext(ctx context.Context) error {
+	return wolfx.NewJobBuilderContext(ctx).
+		Single(j.EndlessStep).
+		Single(j.EndlessStep).
+		Build()
+}
+
+func (j *EndlessJob) EndlessStep(ctx context.Context) error {
+	return wolfx.NewStepBuilder(ctx).
+		SetReader(new(EndlessReader)).
+		SetWriter(new(DiscardWriter)).
+		Build()
+}
+
+type EndlessReader struct{}
+
+func (r *EndlessReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ch <- []string{"a", "b"}:
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+type DiscardWriter struct{}
+
+func (w *DiscardWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	for range ch {
+	}
+	return nil
+}
+
+func newTestServer(t *testing.T) (*wolfx.WolfX, *httptest.Server) {
+	middleware.Logger = gogger.NewLog(&gogger.LogConfig{LogMinLevel: gogger.LevelOff})
+	wx := wolfx.New()
+	wx.Add(new(EchoJob)).Add(new(EndlessJob))
+	server := httptest.NewServer(NewServer(wx))
+	t.Cleanup(server.Close)
+	return wx, server
+}
+
+func request(t *testing.T, method, url string, body interface{}, v interface{}) int {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	if v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestListJobs(t *testing.T) {
+	_, server := newTestServer(t)
+
+	var jobs []Job
+	status := request(t, http.MethodGet, server.URL+"/jobs", nil, &jobs)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []Job{{Name: "EchoJob"}, {Name: "EndlessJob"}}, jobs)
+
+	var errRes ErrorResponse
+	status = request(t, http.MethodPost, server.URL+"/jobs", nil, &errRes)
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+	assert.Equal(t, "Not allowed method: POST", errRes.Error)
+}
+
+func TestLaunchAndHistory(t *testing.T) {
+	wx, server := newTestServer(t)
+
+	for i := 0; i < 3; i++ {
+		var rec middleware.JobExecutionRecord
+		status := request(t, http.MethodPost, server.URL+"/jobs/EchoJob/executions",
+			LaunchRequest{Parameters: middleware.JobParameters{"message": "hello"}}, &rec)
+		assert.Equal(t, http.StatusAccepted, status)
+		assert.Equal(t, int64(i+1), rec.ID)
+		assert.Equal(t, "hello", rec.Parameters["message"])
+		waitFinished(t, wx)
+	}
+
+	var rec middleware.JobExecutionRecord
+	status := request(t, http.MethodGet, server.URL+"/executions/2", nil, &rec)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, middleware.StatusCompleted, rec.Status)
+	assert.Len(t, rec.Steps, 1)
+	assert.Equal(t, "EchoStep", rec.Steps[0].StepName)
+	assert.Equal(t, uint64(1), rec.Steps[0].WriteCount)
+
+	var page wolfx.JobExecutionPage
+	status = request(t, http.MethodGet, server.URL+"/executions?job=EchoJob&offset=1&limit=1", nil, &page)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, int64(2), page.Items[0].ID)
+
+	var errRes ErrorResponse
+	status = request(t, http.MethodGet, server.URL+"/executions?limit=0", nil, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status = request(t, http.MethodGet, server.URL+"/executions/99", nil, &errRes)
+	assert.Equal(t, http.StatusNotFound, status)
+	status = request(t, http.MethodPost, server.URL+"/jobs/NoJob/executions", nil, &errRes)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "Not found job name: NoJob", errRes.Error)
+	status = request(t, http.MethodPost, server.URL+"/executions/1/stop", nil, &errRes)
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestRunningAndStop(t *testing.T) {
+	wx, server := newTestServer(t)
+
+	var rec middleware.JobExecutionRecord
+	status := request(t, http.MethodPost, server.URL+"/jobs/EndlessJob/executions", nil, &rec)
+	assert.Equal(t, http.StatusAccepted, status)
+
+	// Another job runs while EndlessJob is running.
+	var echo middleware.JobExecutionRecord
+	status = request(t, http.MethodPost, server.URL+"/jobs/EchoJob/executions", nil, &echo)
+	assert.Equal(t, http.StatusAccepted, status)
+
+	var running []middleware.JobExecutionRecord
+	assert.Eventually(t, func() bool {
+		request(t, http.MethodGet, server.URL+"/executions/running", nil, &running)
+		return len(running) == 1 && len(running[0].Steps) == 1 &&
+			running[0].Steps[0].ReadCount > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	request(t, http.MethodGet, server.URL+"/executions/2", nil, &echo)
+	assert.Equal(t, middleware.StatusCompleted, echo.Status)
+	assert.Equal(t, rec.ID, running[0].ID)
+	assert.Equal(t, middleware.StatusStarted, running[0].Steps[0].Status)
+
+	var jobs []Job
+	request(t, http.MethodGet, server.URL+"/jobs", nil, &jobs)
+	assert.Equal(t, []Job{{Name: "EchoJob"}, {Name: "EndlessJob", Running: true}}, jobs)
+
+	status = request(t, http.MethodPost, server.URL+"/executions/1/stop", nil, &rec)
+	assert.Equal(t, http.StatusAccepted, status)
+	waitFinished(t, wx)
+
+	request(t, http.MethodGet, server.URL+"/executions/1", nil, &rec)
+	assert.Equal(t, middleware.StatusStopped, rec.Status)
+	// The second step is never executed.
+	assert.Len(t, rec.Steps, 1)
+	assert.Equal(t, middleware.StatusStopped, rec.Steps[0].Status)
+	assert.Equal(t, rec.Steps[0].ReadCount, rec.Steps[0].WriteCount)
+}
+
+func waitFinished(t *testing.T, wx *wolfx.WolfX) {
+	for _, je := range wx.RunningExecutions() {
+		select {
+		case <-je.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Job execution did not finish")
+		}
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/stretchr/testify v1.7.1
 	github.com/yackrru/gogger v1.2.1
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	golang.org/x/text v0.14.0
 )
 
 require (
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxParams is the maximum number of the parameters per statement
+// if WriterConfig.MaxParams is not specified.
+// It is the lowest limit of the major drivers, SQLite before 3.32.0.
+const DefaultMaxParams = 999
+
+var (
+	valuesPattern     = regexp.MustCompile(`(?i)\bvalues\s*\(`)
+	dollarPlaceholder = regexp.MustCompile(`\$(\d+)`)
+	anyPlaceholder    = regexp.MustCompile(`\?|\$\d+`)
+)
+
+// batchInsert builds the multi-row INSERT ... VALUES (...),(...)
+// from the insert dml string of a single row.
+type batchInsert struct {
+	prefix string
+	tuple  string
+	suffix string
+
+	// params is the number of the parameters per row.
+	params int
+}
+
+func newBatchInsert(query string) (*batchInsert, error) {
+	loc := valuesPattern.FindStringIndex(query)
+	if loc == nil {
+		return nil, fmt.Errorf("Not found VALUES of SQL for BatchSize: %s", query)
+	}
+	start := loc[1] - 1
+	depth := 0
+	end := -1
+	for i := start; i < len(query) && end < 0; i++ {
+		switch query[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = i + 1
+			}
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("Not found VALUES of SQL for BatchSize: %s", query)
+	}
+	tuple := query[start:end]
+	return &batchInsert{
+		prefix: query[:start],
+		tuple:  tuple,
+		suffix: query[end:],
+		params: len(anyPlaceholder.FindAllString(tuple, -1)),
+	}, nil
+}
+
+// rowsPerStmt returns the number of rows per statement
+// not exceeding batchSize and maxParams.
+func (b *batchInsert) rowsPerStmt(batchSize, maxParams int) int {
+	if b.params > 0 && b.params*batchSize > maxParams {
+		batchSize = maxParams / b.params
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return batchSize
+}
+
+// build returns the statement of n rows.
+// The placeholders like $1 are renumbered for each row.
+func (b *batchInsert) build(n int) string {
+	var sb strings.Builder
+	sb.WriteString(b.prefix)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		offset := i * b.params
+		sb.WriteString(dollarPlaceholder.ReplaceAllStringFunc(b.tuple, func(s string) string {
+			n, _ := strconv.Atoi(s[1:])
+			return "$" + strconv.Itoa(n+offset)
+		}))
+	}
+	sb.WriteString(b.suffix)
+	return sb.String()
+}
+
+// statements are the statements prepared once per Write
+// by the number of rows.
+type statements struct {
+	db    *sql.DB
+	tx    *sql.Tx
+	query string
+	batch *batchInsert
+	cache map[int]*sql.Stmt
+}
+
+// get returns the statement of n rows.
+func (s *statements) get(ctx context.Context, n int) (*sql.Stmt, error) {
+	if stmt, ok := s.cache[n]; ok {
+		return stmt, nil
+	}
+	query := s.query
+	if s.batch != nil {
+		query = s.batch.build(n)
+	}
+	var stmt *sql.Stmt
+	var err error
+	if s.tx != nil {
+		stmt, err = s.tx.PrepareContext(ctx, query)
+	} else {
+		stmt, err = s.db.PrepareContext(ctx, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.cache == nil {
+		s.cache = make(map[int]*sql.Stmt)
+	}
+	s.cache[n] = stmt
+	return stmt, nil
+}
+
+func (s *statements) close() error {
+	var err error
+	for _, stmt := range s.cache {
+		if errClose := stmt.Close(); err == nil {
+			err = errClose
+		}
+	}
+	s.cache = nil
+	return err
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBatchInsert(t *testing.T) {
+	t.Run("Question", func(t *testing.T) {
+		b, err := newBatchInsert("insert into users (id, name) VALUES (?, lower(?)) on conflict do nothing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, b.params)
+		assert.Equal(t, "insert into users (id, name) VALUES (?, lower(?)), (?, lower(?)) on conflict do nothing", b.build(2))
+	})
+
+	t.Run("Dollar", func(t *testing.T) {
+		b, err := newBatchInsert("insert into users (id, name) values ($1, $2)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "insert into users (id, name) values ($1, $2), ($3, $4), ($5, $6)", b.build(3))
+	})
+
+	t.Run("Rows per statement", func(t *testing.T) {
+		b := &batchInsert{params: 3}
+		assert.Equal(t, 100, b.rowsPerStmt(100, 999))
+		assert.Equal(t, 333, b.rowsPerStmt(1000, 999))
+		assert.Equal(t, 1, b.rowsPerStmt(100, 2))
+	})
+
+	t.Run("Not found VALUES", func(t *testing.T) {
+		_, err := newBatchInsert("insert into users select * from tmp")
+		assert.EqualError(t, err, "Not found VALUES of SQL for BatchSize: insert into users select * from tmp")
+	})
+}
+
+func openWriterDB(t testing.TB) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("create table users (id integer primary key, name text)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func writeChunks(writer *Writer, chunks ...[]middleware.MapMapperType) error {
+	ch := make(chan interface{}, len(chunks))
+	for _, chunk := range chunks {
+		ch <- chunk
+	}
+	close(ch)
+	return writer.Write(context.TODO(), ch)
+}
+
+func userChunk(from, to int) []middleware.MapMapperType {
+	var chunk []middleware.MapMapperType
+	for i := from; i < to; i++ {
+		chunk = append(chunk, middleware.MapMapperType{"id": strconv.Itoa(i), "name": "name" + strconv.Itoa(i)})
+	}
+	return chunk
+}
+
+func TestWriteBatch(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Without transaction", true: "With transaction"}[transactional], func(t *testing.T) {
+			db := openWriterDB(t)
+			writer := NewWriter(&WriterConfig{
+				DB:                db,
+				SQL:               "insert into users (id, name) values (?, ?)",
+				Transactional:     transactional,
+				PropsBindPosition: map[string]uint{"id": 0, "name": 1},
+				BatchSize:         3,
+				MaxParams:         4,
+			})
+			assert.NoError(t, writeChunks(writer, userChunk(0, 5), userChunk(5, 6)))
+
+			var count int
+			var last string
+			if err := db.QueryRow("select count(*), max(name) from users").Scan(&count, &last); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 6, count)
+			assert.Equal(t, "name5", last)
+		})
+	}
+}
+
+func TestWriteBatchWithRejectWriter(t *testing.T) {
+	db := openWriterDB(t)
+	if _, err := db.Exec("insert into users values (3, 'existing')"); err != nil {
+		t.Fatal(err)
+	}
+
+	var rejects []*middleware.Reject
+	writer := NewWriter(&WriterConfig{
+		DB:                db,
+		SQL:               "insert into users (id, name) values (?, ?)",
+		Transactional:     true,
+		PropsBindPosition: map[string]uint{"id": 0, "name": 1},
+		BatchSize:         4,
+		RejectWriter: middleware.RejectWriterFunc(func(ctx context.Context, reject *middleware.Reject) error {
+			rejects = append(rejects, reject)
+			return nil
+		}),
+	})
+	assert.NoError(t, writeChunks(writer, userChunk(0, 6)))
+
+	var count int
+	if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 6, count)
+	if assert.Len(t, rejects, 1) {
+		assert.Equal(t, []string{"3", "name3"}, rejects[0].Values)
+	}
+}
+
+// BenchmarkWrite writes 10000 rows per op and reports rows/s.
+func BenchmarkWrite(b *testing.B) {
+	const rows = 10000
+	chunks := make([][]middleware.MapMapperType, 0, rows/1000)
+	for i := 0; i < rows; i += 1000 {
+		chunks = append(chunks, userChunk(i, i+1000))
+	}
+
+	benchmarks := []struct {
+		name          string
+		transactional bool
+		batchSize     uint
+	}{
+		{"PerRow", false, 0},
+		{"PerRowInTransaction", true, 0},
+		{"Batch100InTransaction", true, 100},
+		{"Batch400InTransaction", true, 400},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			db := openWriterDB(b)
+			writer := NewWriter(&WriterConfig{
+				DB:                db,
+				SQL:               "insert into users (id, name) values (?, ?)",
+				Transactional:     bm.transactional,
+				PropsBindPosition: map[string]uint{"id": 0, "name": 1},
+				BatchSize:         bm.batchSize,
+			})
+
+			var elapsed time.Duration
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				if _, err := db.Exec("delete from users"); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				start := time.Now()
+				if err := writeChunks(writer, chunks...); err != nil {
+					b.Fatal(err)
+				}
+				elapsed += time.Since(start)
+			}
+			b.ReportMetric(float64(rows*b.N)/elapsed.Seconds(), "rows/s")
+		})
+	}
+}
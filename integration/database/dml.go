@@ -0,0 +1,224 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode is the kind of the dml generated by Writer from WriterConfig.Table.
+type Mode int
+
+const (
+	// ModeInsert inserts the rows,
+	// e.g. "INSERT INTO t (k, v) VALUES (?, ?)".
+	ModeInsert Mode = iota
+
+	// ModeUpsert inserts the rows or updates them by the keys
+	// in the way of Dialect.
+	ModeUpsert
+
+	// ModeUpdate updates the rows by the keys,
+	// e.g. "UPDATE t SET v = ? WHERE k = ?".
+	ModeUpdate
+
+	// ModeDelete deletes the rows by the keys,
+	// e.g. "DELETE FROM t WHERE k = ?".
+	ModeDelete
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeInsert:
+		return "insert"
+	case ModeUpsert:
+		return "upsert"
+	case ModeUpdate:
+		return "update"
+	case ModeDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Dialect is the SQL dialect of the database
+// which decides the dml of ModeUpsert, the placeholders
+// and the savepoints of WriterConfig.RejectWriter under transaction.
+type Dialect int
+
+const (
+	// DialectStandard upserts by MERGE of the SQL standard
+	// terminated by ";" as SQL Server requires.
+	// The parameters in its VALUES are not typed,
+	// so it does not work on the databases requiring typed parameters
+	// there, e.g. DB2.
+	// The savepoints are not released but replaced by the next one
+	// of the same name as the SQL standard, e.g. Oracle,
+	// which has no RELEASE SAVEPOINT.
+	DialectStandard Dialect = iota
+
+	// DialectSQLite upserts by "ON CONFLICT (k) DO UPDATE".
+	DialectSQLite
+
+	// DialectPostgres upserts by "ON CONFLICT (k) DO UPDATE"
+	// with the placeholders like $1.
+	DialectPostgres
+
+	// DialectMySQL upserts by "ON DUPLICATE KEY UPDATE".
+	DialectMySQL
+
+	// DialectSQLServer upserts by MERGE as DialectStandard
+	// with the savepoints by "SAVE TRANSACTION".
+	DialectSQLServer
+)
+
+func (d Dialect) placeholder() Placeholder {
+	if d == DialectPostgres {
+		return PlaceholderDollar
+	}
+	return PlaceholderQuestion
+}
+
+// savepoint returns the statements to set the savepoint of name,
+// to roll back to it and to release it.
+// release is empty if the savepoint is not released.
+func (d Dialect) savepoint(name string) (set, rollback, release string) {
+	switch d {
+	case DialectSQLite, DialectPostgres, DialectMySQL:
+		return "SAVEPOINT " + name, "ROLLBACK TO SAVEPOINT " + name, "RELEASE SAVEPOINT " + name
+	case DialectSQLServer:
+		return "SAVE TRANSACTION " + name, "ROLLBACK TRANSACTION " + name, ""
+	}
+	return "SAVEPOINT " + name, "ROLLBACK TO SAVEPOINT " + name, ""
+}
+
+// dml is the dml generated by WriterConfig.Table.
+type dml struct {
+	query string
+
+	// names are the columns bound to the placeholders in order.
+	names []string
+}
+
+// newDML generates the dml of conf.Mode.
+func newDML(conf *WriterConfig) (*dml, error) {
+	keys, values := conf.KeyColumns, conf.ValueColumns
+	if conf.Mode != ModeInsert && len(keys) == 0 {
+		return nil, fmt.Errorf("Not specified KeyColumns for Mode: %s", conf.Mode)
+	}
+	if conf.Mode == ModeUpdate && len(values) == 0 {
+		return nil, fmt.Errorf("Not specified ValueColumns for Mode: %s", conf.Mode)
+	}
+	if conf.BatchSize > 1 && (conf.Mode == ModeUpdate || conf.Mode == ModeDelete) {
+		return nil, fmt.Errorf("Not supported BatchSize with Mode: %s", conf.Mode)
+	}
+
+	cols := append(append([]string{}, keys...), values...)
+	if conf.Mode == ModeInsert && len(cols) == 0 {
+		return nil, fmt.Errorf("Not specified columns for Mode: %s", conf.Mode)
+	}
+	if conf.Mode == ModeUpdate {
+		cols = append(append([]string{}, values...), keys...)
+	}
+	if conf.Mode == ModeDelete {
+		cols = keys
+	}
+	d := &dml{names: cols}
+
+	ph := conf.Dialect.placeholder()
+	params := make([]string, len(cols))
+	for i := range cols {
+		params[i] = ph.format(i + 1)
+	}
+
+	var sb strings.Builder
+	switch conf.Mode {
+	case ModeInsert:
+		writeInsert(&sb, conf.Table, cols, params)
+	case ModeUpsert:
+		writeUpsert(&sb, conf.Table, conf.Dialect, keys, values, params)
+	case ModeUpdate:
+		fmt.Fprintf(&sb, "UPDATE %s SET ", conf.Table)
+		writeAssigns(&sb, values, params, ", ")
+		sb.WriteString(" WHERE ")
+		writeAssigns(&sb, keys, params[len(values):], " AND ")
+	case ModeDelete:
+		fmt.Fprintf(&sb, "DELETE FROM %s WHERE ", conf.Table)
+		writeAssigns(&sb, keys, params, " AND ")
+	default:
+		return nil, fmt.Errorf("Not supported such a mode: %s", conf.Mode)
+	}
+	d.query = sb.String()
+	return d, nil
+}
+
+func writeInsert(sb *strings.Builder, table string, cols, params []string) {
+	fmt.Fprintf(sb, "INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(cols, ", "), strings.Join(params, ", "))
+}
+
+func writeUpsert(sb *strings.Builder, table string, dialect Dialect, keys, values, params []string) {
+	cols := append(append([]string{}, keys...), values...)
+	switch dialect {
+	case DialectSQLite, DialectPostgres:
+		writeInsert(sb, table, cols, params)
+		fmt.Fprintf(sb, " ON CONFLICT (%s) DO ", strings.Join(keys, ", "))
+		if len(values) == 0 {
+			sb.WriteString("NOTHING")
+			return
+		}
+		sb.WriteString("UPDATE SET ")
+		for i, col := range values {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(sb, "%s = excluded.%s", col, col)
+		}
+	case DialectMySQL:
+		writeInsert(sb, table, cols, params)
+		sb.WriteString(" ON DUPLICATE KEY UPDATE ")
+		if len(values) == 0 {
+			fmt.Fprintf(sb, "%s = %s", keys[0], keys[0])
+			return
+		}
+		for i, col := range values {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(sb, "%s = VALUES(%s)", col, col)
+		}
+	default:
+		fmt.Fprintf(sb, "MERGE INTO %s wolfx_target USING (VALUES (%s)) wolfx_source (%s) ON (",
+			table, strings.Join(params, ", "), strings.Join(cols, ", "))
+		for i, col := range keys {
+			if i > 0 {
+				sb.WriteString(" AND ")
+			}
+			fmt.Fprintf(sb, "wolfx_target.%s = wolfx_source.%s", col, col)
+		}
+		sb.WriteString(")")
+		if len(values) > 0 {
+			sb.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+			for i, col := range values {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				fmt.Fprintf(sb, "%s = wolfx_source.%s", col, col)
+			}
+		}
+		sources := make([]string, len(cols))
+		for i, col := range cols {
+			sources[i] = "wolfx_source." + col
+		}
+		fmt.Fprintf(sb, " WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);",
+			strings.Join(cols, ", "), strings.Join(sources, ", "))
+	}
+}
+
+func writeAssigns(sb *strings.Builder, cols, params []string, sep string) {
+	for i, col := range cols {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		fmt.Fprintf(sb, "%s = %s", col, params[i])
+	}
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"testing"
+)
+
+func TestNewDML(t *testing.T) {
+	tests := []struct {
+		name  string
+		conf  WriterConfig
+		query string
+		names []string
+	}{
+		{
+			name:  "Insert",
+			conf:  WriterConfig{Table: "users", ValueColumns: []string{"id", "name"}},
+			query: "INSERT INTO users (id, name) VALUES (?, ?)",
+			names: []string{"id", "name"},
+		},
+		{
+			name: "Upsert of SQLite",
+			conf: WriterConfig{Table: "users", Mode: ModeUpsert, Dialect: DialectSQLite,
+				KeyColumns: []string{"id"}, ValueColumns: []string{"name", "age"}},
+			query: "INSERT INTO users (id, name, age) VALUES (?, ?, ?) ON CONFLICT (id) DO UPDATE SET name = excluded.name, age = excluded.age",
+			names: []string{"id", "name", "age"},
+		},
+		{
+			name: "Upsert of PostgreSQL without values",
+			conf: WriterConfig{Table: "users", Mode: ModeUpsert, Dialect: DialectPostgres,
+				KeyColumns: []string{"id", "code"}},
+			query: "INSERT INTO users (id, code) VALUES ($1, $2) ON CONFLICT (id, code) DO NOTHING",
+			names: []string{"id", "code"},
+		},
+		{
+			name: "Upsert of MySQL",
+			conf: WriterConfig{Table: "users", Mode: ModeUpsert, Dialect: DialectMySQL,
+				KeyColumns: []string{"id"}, ValueColumns: []string{"name"}},
+			query: "INSERT INTO users (id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)",
+			names: []string{"id", "name"},
+		},
+		{
+			name: "Upsert by MERGE",
+			conf: WriterConfig{Table: "users", Mode: ModeUpsert,
+				KeyColumns: []string{"id"}, ValueColumns: []string{"name"}},
+			query: "MERGE INTO users wolfx_target USING (VALUES (?, ?)) wolfx_source (id, name)" +
+				" ON (wolfx_target.id = wolfx_source.id)" +
+				" WHEN MATCHED THEN UPDATE SET name = wolfx_source.name" +
+				" WHEN NOT MATCHED THEN INSERT (id, name) VALUES (wolfx_source.id, wolfx_source.name);",
+			names: []string{"id", "name"},
+		},
+		{
+			name: "Update",
+			conf: WriterConfig{Table: "users", Mode: ModeUpdate, Dialect: DialectPostgres,
+				KeyColumns: []string{"id", "code"}, ValueColumns: []string{"name"}},
+			query: "UPDATE users SET name = $1 WHERE id = $2 AND code = $3",
+			names: []string{"name", "id", "code"},
+		},
+		{
+			name: "Delete",
+			conf: WriterConfig{Table: "users", Mode: ModeDelete,
+				KeyColumns: []string{"id"}, ValueColumns: []string{"name"}},
+			query: "DELETE FROM users WHERE id = ?",
+			names: []string{"id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newDML(&tt.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.query, d.query)
+			assert.Equal(t, tt.names, d.names)
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := newDML(&WriterConfig{Table: "users", Mode: ModeUpsert, ValueColumns: []string{"name"}})
+		assert.EqualError(t, err, "Not specified KeyColumns for Mode: upsert")
+		_, err = newDML(&WriterConfig{Table: "users", Mode: ModeUpdate, KeyColumns: []string{"id"}})
+		assert.EqualError(t, err, "Not specified ValueColumns for Mode: update")
+		_, err = newDML(&WriterConfig{Table: "users", Mode: ModeDelete, KeyColumns: []string{"id"}, BatchSize: 10})
+		assert.EqualError(t, err, "Not supported BatchSize with Mode: delete")
+		_, err = newDML(&WriterConfig{Table: "users", Mode: Mode(9), KeyColumns: []string{"id"}})
+		assert.EqualError(t, err, "Not supported such a mode: Mode(9)")
+	})
+}
+
+func TestDialectSavepoint(t *testing.T) {
+	tests := []struct {
+		dialect                Dialect
+		set, rollback, release string
+	}{
+		{DialectStandard, "SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp", ""},
+		{DialectPostgres, "SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp", "RELEASE SAVEPOINT sp"},
+		{DialectSQLServer, "SAVE TRANSACTION sp", "ROLLBACK TRANSACTION sp", ""},
+	}
+	for _, tt := range tests {
+		set, rollback, release := tt.dialect.savepoint("sp")
+		assert.Equal(t, tt.set, set)
+		assert.Equal(t, tt.rollback, rollback)
+		assert.Equal(t, tt.release, release)
+	}
+}
+
+func TestWriteByMode(t *testing.T) {
+	db := openWriterDB(t)
+	if _, err := db.Exec("insert into users values (1, 'name1'), (2, 'name2'), (3, 'name3')"); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(conf *WriterConfig, chunk []middleware.MapMapperType) (*Writer, *middleware.StepExecution) {
+		conf.DB = db
+		conf.Table = "users"
+		conf.Dialect = DialectSQLite
+		conf.KeyColumns = []string{"id"}
+
+		se := middleware.NewStepExecution(nil, "SyncStep")
+		ctx := middleware.WithStepExecution(context.TODO(), se)
+		ch := make(chan interface{}, 1)
+		ch <- chunk
+		close(ch)
+		writer := NewWriter(conf)
+		if err := writer.Write(ctx, ch); err != nil {
+			t.Fatal(err)
+		}
+		return writer, se
+	}
+	names := func() []string {
+		rows, err := db.Query("select name from users order by id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, name)
+		}
+		return names
+	}
+
+	t.Run("Upsert", func(t *testing.T) {
+		for _, batchSize := range []uint{0, 2} {
+			writer, se := write(&WriterConfig{
+				Mode:          ModeUpsert,
+				ValueColumns:  []string{"name"},
+				Transactional: true,
+				BatchSize:     batchSize,
+			}, []middleware.MapMapperType{
+				{"id": "2", "name": "updated2"},
+				{"id": "4", "name": "name4"},
+				{"id": "5", "name": "name5"},
+			})
+			assert.Equal(t, int64(3), writer.AffectedRows())
+			assert.Equal(t, "3", se.ExecutionContext().GetString(AffectedRowsKey))
+		}
+		assert.Equal(t, []string{"name1", "updated2", "name3", "name4", "name5"}, names())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		writer, _ := write(&WriterConfig{
+			Mode:         ModeUpdate,
+			ValueColumns: []string{"name"},
+		}, []middleware.MapMapperType{
+			{"id": "1", "name": "updated1"},
+			{"id": "9", "name": "missing"},
+		})
+		assert.Equal(t, int64(1), writer.AffectedRows())
+		assert.Equal(t, []string{"updated1", "updated2", "name3", "name4", "name5"}, names())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		writer, se := write(&WriterConfig{
+			Mode:           ModeDelete,
+			CommitPerChunk: true,
+		}, []middleware.MapMapperType{
+			{"id": "3"},
+			{"id": "4"},
+			{"id": "9"},
+		})
+		assert.Equal(t, int64(2), writer.AffectedRows())
+		assert.Equal(t, "2", se.ExecutionContext().GetString(AffectedRowsKey))
+		assert.Equal(t, "3", se.ExecutionContext().GetString(CommittedCountKey))
+		assert.Equal(t, []string{"updated1", "updated2", "name5"}, names())
+	})
+}
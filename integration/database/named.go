@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Placeholder is the style of the placeholders of the driver.
+type Placeholder int
+
+const (
+	// PlaceholderQuestion is "?", e.g. MySQL and SQLite.
+	PlaceholderQuestion Placeholder = iota
+
+	// PlaceholderDollar is "$1", "$2" and so on, e.g. PostgreSQL.
+	PlaceholderDollar
+)
+
+func (p Placeholder) format(n int) string {
+	if p == PlaceholderDollar {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// namedQuery is SQL whose named parameters like :name
+// are replaced with the placeholders.
+type namedQuery struct {
+	query string
+
+	// names are the names of the parameters in order of the placeholders.
+	names []string
+}
+
+// compileNamed replaces the named parameters in query
+// with the placeholders of style.
+// prefixes are the characters starting the names, e.g. ":" for :name
+// and ":@" for :name and @name.
+// The prefixes in the string literals, the quoted identifiers and the comments,
+// and the doubled ones like ::int are kept as they are.
+func compileNamed(query string, style Placeholder, prefixes string) *namedQuery {
+	var b strings.Builder
+	var names []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			// The doubled quotes are read as two literals.
+			b.WriteString(query[i : i+end+2])
+			i += end + 2
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case strings.IndexByte(prefixes, c) >= 0 && i+1 < len(query) && query[i+1] == c:
+			// The doubled prefixes like ::int and @@version are not names.
+			b.WriteString(query[i : i+2])
+			i += 2
+		case strings.IndexByte(prefixes, c) >= 0 && i+1 < len(query) && isNameStart(query[i+1]):
+			j := i + 2
+			for j < len(query) && isNamePart(query[j]) {
+				j++
+			}
+			names = append(names, query[i+1:j])
+			b.WriteString(style.format(len(names)))
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return &namedQuery{query: b.String(), names: names}
+}
+
+// args returns the arguments of the parameters.
+// The value of a name is looked up in named and then in params.
+func (q *namedQuery) args(named map[string]interface{}, params map[string]string) ([]interface{}, error) {
+	args := make([]interface{}, len(q.names))
+	for i, name := range q.names {
+		if v, ok := named[name]; ok {
+			args[i] = v
+			continue
+		}
+		v, ok := params[name]
+		if !ok {
+			return nil, fmt.Errorf("Not found parameter: %s", name)
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// namedArgs returns the values of args of sql.NamedArg by the names.
+func namedArgs(args []interface{}) (map[string]interface{}, error) {
+	named := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		na, ok := arg.(sql.NamedArg)
+		if !ok {
+			return nil, fmt.Errorf("Not supported positional argument with NamedParams: %v", arg)
+		}
+		named[na.Name] = na.Value
+	}
+	return named, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || '0' <= c && c <= '9'
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompileNamed(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		style    Placeholder
+		prefixes string
+		want     string
+		names    []string
+	}{
+		{
+			name:  "Question",
+			query: "select * from users where id > :min_id and name = :name and id < :min_id",
+			style: PlaceholderQuestion,
+			want:  "select * from users where id > ? and name = ? and id < ?",
+			names: []string{"min_id", "name", "min_id"},
+		},
+		{
+			name:  "Dollar",
+			query: "select * from users where id > :id and name = :name",
+			style: PlaceholderDollar,
+			want:  "select * from users where id > $1 and name = $2",
+			names: []string{"id", "name"},
+		},
+		{
+			name:  "Literals, comments and casts",
+			query: "select ':a', \"b:c\", 'it''s :d', id::text -- :e\nfrom t /* :f */ where x = :g",
+			style: PlaceholderQuestion,
+			want:  "select ':a', \"b:c\", 'it''s :d', id::text -- :e\nfrom t /* :f */ where x = ?",
+			names: []string{"g"},
+		},
+		{
+			name:     "At sign",
+			query:    "insert into users values (@id, :name, '@x', @@version)",
+			style:    PlaceholderQuestion,
+			prefixes: ":@",
+			want:     "insert into users values (?, ?, '@x', @@version)",
+			names:    []string{"id", "name"},
+		},
+		{
+			name:  "At sign without prefix",
+			query: "select * from users where id = @id and name = :name",
+			style: PlaceholderQuestion,
+			want:  "select * from users where id = @id and name = ?",
+			names: []string{"name"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes := tt.prefixes
+			if prefixes == "" {
+				prefixes = ":"
+			}
+			q := compileNamed(tt.query, tt.style, prefixes)
+			assert.Equal(t, tt.want, q.query)
+			assert.Equal(t, tt.names, q.names)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var _ middleware.Reader = new(PagingReader)
+
+// DefaultPageSize is the number of rows per page of PagingReader
+// if neither PageSize nor ChunkSize is specified.
+const DefaultPageSize = 1000
+
+// PagePositionKey is the key of the execution context of the step
+// where PagingReader puts the position of the last item
+// processed by the writer.
+const PagePositionKey = "database.PagingReader.position"
+
+// Paging is the method of pagination of PagingReader.
+type Paging int
+
+const (
+	// PagingKeyset fetches the rows after the sort keys of the last row,
+	// e.g. "WHERE id > ? ORDER BY id LIMIT n".
+	PagingKeyset Paging = iota
+
+	// PagingOffset fetches the rows by offset,
+	// e.g. "ORDER BY id LIMIT n OFFSET m".
+	PagingOffset
+)
+
+// PagePosition is the position to restart PagingReader.
+type PagePosition struct {
+	// Keys are the values of SortKeys of the last row read by PagingKeyset.
+	Keys []string
+
+	// Offset is the number of the rows read by PagingOffset.
+	Offset int64
+}
+
+// String returns the position in the form of the JSON array of Keys
+// or Offset, which is parsed by ParsePagePosition.
+func (p PagePosition) String() string {
+	if p.Keys == nil {
+		return strconv.FormatInt(p.Offset, 10)
+	}
+	b, _ := json.Marshal(p.Keys)
+	return string(b)
+}
+
+// ParsePagePosition parses the position returned by PagePosition.String,
+// e.g. given by job parameters.
+func ParsePagePosition(s string) (*PagePosition, error) {
+	if strings.HasPrefix(s, "[") {
+		var keys []string
+		if err := json.Unmarshal([]byte(s), &keys); err != nil {
+			return nil, fmt.Errorf("Invalid page position: %s", s)
+		}
+		return &PagePosition{Keys: keys}, nil
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return nil, fmt.Errorf("Invalid page position: %s", s)
+	}
+	return &PagePosition{Offset: offset}, nil
+}
+
+// PagingReader is an implementation of middleware.Reader.
+// Unlike Reader holding one cursor, it reads data by the queries of pages
+// so that no connection is held between the pages.
+// It sends the same chunks as Reader.
+type PagingReader struct {
+	conf *PagingReaderConfig
+
+	mu       sync.Mutex
+	position PagePosition
+}
+
+// PagingReaderConfig is the configuration of PagingReader.
+type PagingReaderConfig struct {
+	DB *sql.DB
+
+	// SQL is a select dml string without ORDER BY, LIMIT and OFFSET.
+	// It is queried as the subquery of each page, e.g.
+	// "SELECT * FROM (SQL) wolfx_page WHERE id > ? ORDER BY id LIMIT 1000".
+	SQL string
+
+	// CountSQL is a select dml string counting the rows of SQL.
+	// If it is not empty, PagingReader reports the count as the total
+	// of the step so that the completion time can be estimated.
+	CountSQL string
+
+	// Args, ArgsFunc, NamedParams and Placeholder are the arguments
+	// of SQL and CountSQL as ReaderConfig.
+	Args        []interface{}
+	ArgsFunc    func(ctx context.Context) ([]interface{}, error)
+	NamedParams bool
+	Placeholder Placeholder
+
+	// SortKeys are the columns of SQL ordering the rows in ascending order.
+	// They must be unique and not null together, e.g. the primary key.
+	SortKeys []string
+
+	// Paging is the method of pagination.
+	Paging Paging
+
+	// PageSize is the number of rows per query.
+	// If specify 0, ChunkSize or DefaultPageSize is used.
+	PageSize uint
+
+	// ChunkSize is the number of rows to be sent to writer at once.
+	// If specify 0, PagingReader will send all fetched rows at once.
+	ChunkSize uint
+
+	// Restart is the position to restart reading after.
+	Restart *PagePosition
+
+	// RowMapperFunc is the mapping function.
+	// If it is nil, PagingReader will send data as the type
+	// of MapMapperType to channel.
+	// If not nil, PagingReader will send data as the type that user defined.
+	RowMapperFunc middleware.RowMapper
+}
+
+func NewPagingReader(conf *PagingReaderConfig) *PagingReader {
+	return &PagingReader{
+		conf: conf,
+	}
+}
+
+// Position returns the position of the last item processed by the writer.
+// It is also put to the execution context of the step by PagePositionKey.
+// It advances when the writer has processed a chunk,
+// so the items sent to the failed writer are read again at the restart.
+// If the reader runs outside a step, it advances when a chunk is sent.
+// It is saved to restart reading by PagingReaderConfig.Restart.
+func (r *PagingReader) Position() PagePosition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.position
+}
+
+func (r *PagingReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	if len(r.conf.SortKeys) == 0 {
+		return errors.New("Not specified SortKeys of PagingReader")
+	}
+	var position PagePosition
+	if restart := r.conf.Restart; restart != nil {
+		if r.conf.Paging == PagingKeyset && restart.Keys != nil &&
+			len(restart.Keys) != len(r.conf.SortKeys) {
+			return fmt.Errorf("Mismatched page position: %s, expected %d keys",
+				restart, len(r.conf.SortKeys))
+		}
+		position = *restart
+	}
+
+	b := &binder{
+		args:        r.conf.Args,
+		argsFunc:    r.conf.ArgsFunc,
+		namedParams: r.conf.NamedParams,
+		placeholder: r.conf.Placeholder,
+	}
+	if err := b.init(ctx); err != nil {
+		return err
+	}
+	if err := countTotal(ctx, r.conf.DB, b, r.conf.CountSQL); err != nil {
+		return err
+	}
+	query, args, err := b.bind(ctx, r.conf.SQL)
+	if err != nil {
+		return err
+	}
+
+	pageSize := int(r.conf.PageSize)
+	if pageSize == 0 {
+		pageSize = int(r.conf.ChunkSize)
+	}
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	s := &pagingState{
+		ctx:      ctx,
+		ch:       ch,
+		reader:   r,
+		position: position,
+	}
+	for {
+		page, err := r.fetch(ctx, query, args, s.position, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, item := range page {
+			if err := s.add(item); err != nil {
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	if len(s.chunk) != 0 || r.conf.ChunkSize == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+// fetch queries the page after position.
+// The rows are closed before the items are sent
+// so that the connection is not held by the writer.
+func (r *PagingReader) fetch(ctx context.Context, query string, args []interface{},
+	position PagePosition, pageSize int) ([]middleware.MapMapperType, error) {
+
+	query, args = r.pageQuery(query, args, position, pageSize)
+	_, span := tracing.StartSpan(ctx, "database.PagingReader.Query")
+	rows, err := r.conf.DB.QueryContext(ctx, query, args...)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]interface{}, len(cols))
+	for i := range cols {
+		vals[i] = new(sql.RawBytes)
+	}
+	page := make([]middleware.MapMapperType, 0, pageSize)
+	for rows.Next() {
+		if err := rows.Scan(vals...); err != nil {
+			return nil, err
+		}
+		page = append(page, rawBytesToMapMapper(cols, vals))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// pageQuery returns the query of the page after position.
+// The placeholders of the keys follow args.
+func (r *PagingReader) pageQuery(query string, args []interface{},
+	position PagePosition, pageSize int) (string, []interface{}) {
+
+	var b strings.Builder
+	b.WriteString("SELECT * FROM (")
+	b.WriteString(query)
+	b.WriteString(") wolfx_page")
+
+	keys := r.conf.SortKeys
+	args = append([]interface{}{}, args...)
+	if r.conf.Paging == PagingKeyset && position.Keys != nil {
+		// (k1 > ?) OR (k1 = ? AND k2 > ?) OR ...
+		b.WriteString(" WHERE ")
+		for i := range keys {
+			if i > 0 {
+				b.WriteString(" OR ")
+			}
+			b.WriteString("(")
+			for j := 0; j <= i; j++ {
+				op := " = "
+				if j == i {
+					op = " > "
+				}
+				if j > 0 {
+					b.WriteString(" AND ")
+				}
+				args = append(args, position.Keys[j])
+				b.WriteString(keys[j] + op + r.conf.Placeholder.format(len(args)))
+			}
+			b.WriteString(")")
+		}
+	}
+
+	b.WriteString(" ORDER BY ")
+	b.WriteString(strings.Join(keys, ", "))
+	b.WriteString(" LIMIT ")
+	b.WriteString(strconv.Itoa(pageSize))
+	if r.conf.Paging == PagingOffset && position.Offset > 0 {
+		b.WriteString(" OFFSET ")
+		b.WriteString(strconv.FormatInt(position.Offset, 10))
+	}
+	return b.String(), args
+}
+
+// pagingState is the state of PagingReader.Read.
+type pagingState struct {
+	ctx    context.Context
+	ch     chan<- interface{}
+	reader *PagingReader
+
+	chunk []middleware.MapMapperType
+
+	// position is the position of the last item added to chunk.
+	position PagePosition
+}
+
+func (s *pagingState) add(item middleware.MapMapperType) error {
+	conf := s.reader.conf
+	if conf.Paging == PagingKeyset {
+		keys := make([]string, len(conf.SortKeys))
+		for i, key := range conf.SortKeys {
+			v, ok := item[key]
+			if !ok {
+				return fmt.Errorf("Not found sort key in columns: %s", key)
+			}
+			keys[i] = v
+		}
+		s.position = PagePosition{Keys: keys}
+	} else {
+		s.position = PagePosition{Offset: s.position.Offset + 1}
+	}
+
+	s.chunk = append(s.chunk, item)
+	if chunkSize := int(conf.ChunkSize); chunkSize > 0 && len(s.chunk) >= chunkSize {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *pagingState) flush() error {
+	position := s.position
+	commit := func() {
+		s.reader.mu.Lock()
+		s.reader.position = position
+		s.reader.mu.Unlock()
+		middleware.ExecutionContextFromContext(s.ctx).Put(PagePositionKey, position.String())
+	}
+	written := middleware.OnChunkWritten(s.ctx, commit)
+
+	if err := sendChunk(s.ctx, s.ch, s.chunk, s.reader.conf.RowMapperFunc); err != nil {
+		return err
+	}
+	s.chunk = nil
+
+	if !written {
+		commit()
+	}
+	return nil
+}
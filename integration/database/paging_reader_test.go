@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"testing"
+)
+
+func openUsersDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	ddl, err := os.ReadFile("./testdata/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(ddl)); err != nil {
+		t.Fatal(err)
+	}
+	createData(t, db)
+	return db
+}
+
+func readChunks(ctx context.Context, reader middleware.Reader) ([][]middleware.MapMapperType, error) {
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(ctx, ch)
+	}()
+	var chunks [][]middleware.MapMapperType
+	for chunk := range ch {
+		chunks = append(chunks, chunk.([]middleware.MapMapperType))
+	}
+	return chunks, <-errCh
+}
+
+func TestPagingReader(t *testing.T) {
+	db := openUsersDB(t)
+
+	for _, chunkSize := range []uint{0, 5} {
+		want, err := readChunks(context.TODO(), NewReader(&ReaderConfig{
+			DB:        db,
+			SQL:       "select * from users order by id",
+			ChunkSize: chunkSize,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, paging := range []Paging{PagingKeyset, PagingOffset} {
+			se := middleware.NewStepExecution(nil, "ReadStep")
+			ctx := middleware.WithStepExecution(context.TODO(), se)
+			reader := NewPagingReader(&PagingReaderConfig{
+				DB:        db,
+				SQL:       "select * from users",
+				SortKeys:  []string{"id"},
+				Paging:    paging,
+				PageSize:  3,
+				ChunkSize: chunkSize,
+			})
+			got, err := readChunks(ctx, reader)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			last := want[len(want)-1]
+			if paging == PagingKeyset {
+				assert.Equal(t, PagePosition{Keys: []string{last[len(last)-1]["id"]}}, reader.Position())
+			} else {
+				assert.Equal(t, PagePosition{Offset: 24}, reader.Position())
+			}
+			assert.Equal(t, reader.Position().String(), se.ExecutionContext().GetString(PagePositionKey))
+		}
+	}
+}
+
+// failingWriter fails when it receives the chunk of failAt counting from 1.
+type failingWriter struct {
+	failAt int
+}
+
+func (w *failingWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	var n int
+	for range ch {
+		n++
+		if n == w.failAt {
+			return errors.New("Write error")
+		}
+	}
+	return nil
+}
+
+func TestPagingReaderPositionWithFailedWriter(t *testing.T) {
+	db := openUsersDB(t)
+
+	tests := []struct {
+		name   string
+		paging Paging
+		want   PagePosition
+	}{
+		{"Keyset", PagingKeyset, PagePosition{Keys: []string{"4"}}},
+		{"Offset", PagingOffset, PagePosition{Offset: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewPagingReader(&PagingReaderConfig{
+				DB:        db,
+				SQL:       "select * from users",
+				SortKeys:  []string{"id"},
+				Paging:    tt.paging,
+				ChunkSize: 5,
+			})
+
+			err := wolfx.NewStepBuilder(context.TODO()).
+				SetReader(reader).
+				SetWriter(&failingWriter{failAt: 2}).
+				Build()
+			assert.EqualError(t, err, "Write error")
+			// The 2nd chunk is read again at the restart.
+			assert.Equal(t, tt.want, reader.Position())
+		})
+	}
+}
+
+func TestPagingReaderWithCompositeKeys(t *testing.T) {
+	db := openUsersDB(t)
+	if _, err := db.Exec("update users set name = 'Same' where id in (3, 5, 7)"); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := readChunks(context.TODO(), NewReader(&ReaderConfig{
+		DB:        db,
+		SQL:       "select * from users where id > ? order by name, id",
+		Args:      []interface{}{0},
+		ChunkSize: 4,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readChunks(context.TODO(), NewPagingReader(&PagingReaderConfig{
+		DB:        db,
+		SQL:       "select * from users where id > ?",
+		Args:      []interface{}{0},
+		SortKeys:  []string{"name", "id"},
+		PageSize:  2,
+		ChunkSize: 4,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestPagingReaderRestart(t *testing.T) {
+	db := openUsersDB(t)
+
+	tests := []struct {
+		name     string
+		paging   Paging
+		position string
+	}{
+		{"Keyset", PagingKeyset, `["21"]`},
+		{"Offset", PagingOffset, "22"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restart, err := ParsePagePosition(tt.position)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunks, err := readChunks(context.TODO(), NewPagingReader(&PagingReaderConfig{
+				DB:        db,
+				SQL:       "select * from users",
+				SortKeys:  []string{"id"},
+				Paging:    tt.paging,
+				ChunkSize: 2,
+				Restart:   restart,
+			}))
+			assert.NoError(t, err)
+			var ids []string
+			for _, chunk := range chunks {
+				for _, item := range chunk {
+					ids = append(ids, item["id"])
+				}
+			}
+			assert.Equal(t, []string{"22", "23"}, ids)
+		})
+	}
+}
+
+func TestPagingReaderErrors(t *testing.T) {
+	db := openUsersDB(t)
+
+	_, err := readChunks(context.TODO(), NewPagingReader(&PagingReaderConfig{
+		DB:  db,
+		SQL: "select * from users",
+	}))
+	assert.EqualError(t, err, "Not specified SortKeys of PagingReader")
+
+	_, err = readChunks(context.TODO(), NewPagingReader(&PagingReaderConfig{
+		DB:       db,
+		SQL:      "select id as user_id from users",
+		SortKeys: []string{"user_id"},
+		Restart:  &PagePosition{Keys: []string{"1", "2"}},
+	}))
+	assert.EqualError(t, err, `Mismatched page position: ["1","2"], expected 1 keys`)
+
+	_, err = ParsePagePosition("abc")
+	assert.EqualError(t, err, "Invalid page position: abc")
+}
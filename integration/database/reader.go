@@ -3,7 +3,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"reflect"
 )
 
 var _ middleware.Reader = new(Reader)
@@ -21,6 +24,29 @@ type ReaderConfig struct {
 	// SQL is a select dml string.
 	SQL string
 
+	// CountSQL is a select dml string counting the rows of SQL.
+	// If it is not empty, Reader reports the count as the total
+	// of the step so that the completion time can be estimated.
+	CountSQL string
+
+	// Args are the arguments of the placeholders in SQL and CountSQL.
+	Args []interface{}
+
+	// ArgsFunc returns the dynamic arguments at the start of Read,
+	// e.g. from the execution context of the step.
+	// They are added after Args.
+	ArgsFunc func(ctx context.Context) ([]interface{}, error)
+
+	// If NamedParams is true, the named parameters like :name in SQL and CountSQL
+	// are replaced with the placeholders of Placeholder.
+	// The value of each name is sql.NamedArg of the name in Args or ArgsFunc,
+	// otherwise the job parameter of the name.
+	// Args and ArgsFunc must be sql.NamedArg, e.g. sql.Named("status", 1).
+	NamedParams bool
+
+	// Placeholder is the style of the placeholders replacing the named parameters.
+	Placeholder Placeholder
+
 	// ChunkSize is the number of rows to be sent to writer at once.
 	// If specify 0, Reader will send all fetched rows at once.
 	ChunkSize uint
@@ -30,6 +56,20 @@ type ReaderConfig struct {
 	// of MapMapperType to channel.
 	// If not nil, Reader will send data as the type that user defined.
 	RowMapperFunc middleware.RowMapper
+
+	// If Records is true, Reader scans the values by the types of the columns
+	// and sends []*middleware.Record keeping the Go types and NULL,
+	// e.g. int64, float64, bool, string, []byte and time.Time.
+	// They are converted to MapMapperType by middleware.ToMapMapperChunk.
+	// RowMapperFunc is not supported.
+	Records bool
+
+	// Struct is the struct value of the type that the rows are scanned into,
+	// e.g. User{}, as ScanStruct.
+	// If it is not nil, Reader sends []middleware.CustomMapperType
+	// whose Props are the structs.
+	// RowMapperFunc is not supported.
+	Struct interface{}
 }
 
 func NewReader(conf *ReaderConfig) *Reader {
@@ -41,67 +81,212 @@ func NewReader(conf *ReaderConfig) *Reader {
 func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
 	defer close(ch)
 
-	rows, err := r.conf.DB.Query(r.conf.SQL)
+	b := &binder{
+		args:        r.conf.Args,
+		argsFunc:    r.conf.ArgsFunc,
+		namedParams: r.conf.NamedParams,
+		placeholder: r.conf.Placeholder,
+	}
+	if err := b.init(ctx); err != nil {
+		return err
+	}
+	if err := countTotal(ctx, r.conf.DB, b, r.conf.CountSQL); err != nil {
+		return err
+	}
+
+	query, args, err := b.bind(ctx, r.conf.SQL)
+	if err != nil {
+		return err
+	}
+	_, span := tracing.StartSpan(ctx, "database.Reader.Query")
+	rows, err := r.conf.DB.QueryContext(ctx, query, args...)
+	span.RecordError(err)
+	span.End()
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	cols, err := rows.Columns()
+	scan, err := r.newScan(rows)
 	if err != nil {
 		return err
 	}
+	var chunk []interface{}
+	for rows.Next() {
+		item, err := scan()
+		if err != nil {
+			return err
+		}
+		chunk = append(chunk, item)
+		if r.conf.ChunkSize > 0 && len(chunk) == int(r.conf.ChunkSize) {
+			if err := r.sendItems(ctx, ch, chunk); err != nil {
+				return err
+			}
+			chunk = nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(chunk) != 0 || r.conf.ChunkSize == 0 {
+		return r.sendItems(ctx, ch, chunk)
+	}
+
+	return nil
+}
+
+// newScan returns the function scanning the current row of rows
+// into the item of MapMapperType, *middleware.Record or the value of Struct.
+func (r *Reader) newScan(rows *sql.Rows) (func() (interface{}, error), error) {
+	if (r.conf.Records || r.conf.Struct != nil) && r.conf.RowMapperFunc != nil {
+		return nil, errors.New("Not supported RowMapperFunc with Records or Struct")
+	}
+
+	switch {
+	case r.conf.Records:
+		s, err := newRecordScanner(rows)
+		if err != nil {
+			return nil, err
+		}
+		return func() (interface{}, error) {
+			return s.scan(rows)
+		}, nil
+	case r.conf.Struct != nil:
+		cols, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		s, err := newStructScanner(reflect.TypeOf(r.conf.Struct), cols)
+		if err != nil {
+			return nil, err
+		}
+		return func() (interface{}, error) {
+			return s.scan(rows)
+		}, nil
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
 	vals := make([]interface{}, len(cols))
-	for i, _ := range cols {
+	for i := range cols {
 		vals[i] = new(sql.RawBytes)
 	}
-
-	if r.conf.ChunkSize > 0 {
-		cursor := 0
-		var chunk []middleware.MapMapperType
-		for rows.Next() {
-			cursor++
-			if err := rows.Scan(vals...); err != nil {
-				return err
-			}
-			resultSet := rawBytesToMapMapper(cols, vals)
-			chunk = append(chunk, resultSet)
-			if cursor%int(r.conf.ChunkSize) == 0 {
-				if err := r.sendChunk(ctx, ch, chunk); err != nil {
-					return err
-				}
-				chunk = []middleware.MapMapperType{}
-			}
+	return func() (interface{}, error) {
+		if err := rows.Scan(vals...); err != nil {
+			return nil, err
 		}
-		if len(chunk) != 0 {
-			if err := r.sendChunk(ctx, ch, chunk); err != nil {
-				return err
-			}
+		return rawBytesToMapMapper(cols, vals), nil
+	}, nil
+}
+
+// sendItems sends items as the chunk of their type.
+func (r *Reader) sendItems(ctx context.Context, ch chan<- interface{}, items []interface{}) error {
+	var chunk interface{}
+	switch {
+	case r.conf.Records:
+		var records []*middleware.Record
+		for _, item := range items {
+			records = append(records, item.(*middleware.Record))
 		}
-	} else {
-		var chunk []middleware.MapMapperType
-		for rows.Next() {
-			if err := rows.Scan(vals...); err != nil {
-				return err
-			}
-			resultSet := rawBytesToMapMapper(cols, vals)
-			chunk = append(chunk, resultSet)
+		chunk = records
+	case r.conf.Struct != nil:
+		var structs []middleware.CustomMapperType
+		for _, item := range items {
+			structs = append(structs, middleware.CustomMapperType{Props: item})
 		}
-		if err := r.sendChunk(ctx, ch, chunk); err != nil {
-			return err
+		chunk = structs
+	default:
+		var resultSets []middleware.MapMapperType
+		for _, item := range items {
+			resultSets = append(resultSets, item.(middleware.MapMapperType))
 		}
+		chunk = resultSets
 	}
+	return r.sendChunk(ctx, ch, chunk)
+}
+
+// binder binds the arguments of ReaderConfig and PagingReaderConfig.
+type binder struct {
+	args        []interface{}
+	argsFunc    func(ctx context.Context) ([]interface{}, error)
+	namedParams bool
+	placeholder Placeholder
+}
 
+// init adds the dynamic arguments of argsFunc.
+func (b *binder) init(ctx context.Context) error {
+	if b.argsFunc == nil {
+		return nil
+	}
+	dynamic, err := b.argsFunc(ctx)
+	if err != nil {
+		return err
+	}
+	b.args = append(append([]interface{}{}, b.args...), dynamic...)
+	return nil
+}
+
+// bind returns query and the arguments to execute.
+// If namedParams is true, the named parameters of query are replaced
+// and bound to the values of args or the job parameters.
+func (b *binder) bind(ctx context.Context, query string) (string, []interface{}, error) {
+	if !b.namedParams {
+		return query, b.args, nil
+	}
+	named, err := namedArgs(b.args)
+	if err != nil {
+		return "", nil, err
+	}
+	q := compileNamed(query, b.placeholder, ":")
+	bound, err := q.args(named, middleware.JobParametersFromContext(ctx))
+	if err != nil {
+		return "", nil, err
+	}
+	return q.query, bound, nil
+}
+
+// countTotal reports the count of countSQL as the total of the step.
+// It does nothing if countSQL is empty.
+func countTotal(ctx context.Context, db *sql.DB, b *binder, countSQL string) error {
+	if countSQL == "" {
+		return nil
+	}
+	query, args, err := b.bind(ctx, countSQL)
+	if err != nil {
+		return err
+	}
+	var total uint64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return err
+	}
+	middleware.SetProgressTotal(ctx, total, middleware.ProgressItems)
 	return nil
 }
 
 func (r *Reader) sendChunk(ctx context.Context, ch chan<- interface{},
-	chunk []middleware.MapMapperType) error {
+	chunk interface{}) error {
+
+	return sendChunk(ctx, ch, chunk, r.conf.RowMapperFunc)
+}
+
+// sendChunk sends chunk to ch through mapper if it is not nil.
+// chunk is the slice of MapMapperType if mapper is not nil.
+func sendChunk(ctx context.Context, ch chan<- interface{},
+	chunk interface{}, mapper middleware.RowMapper) error {
 
-	if r.conf.RowMapperFunc == nil {
+	ctx = middleware.NextChunkContext(ctx)
+	if mapper == nil {
 		ch <- chunk
 	} else {
-		if err := r.conf.RowMapperFunc(ctx, ch, chunk); err != nil {
+		items := chunk.([]middleware.MapMapperType)
+		ctx, span := tracing.StartSpan(ctx, "RowMapper",
+			tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+		err := mapper(ctx, ch, items)
+		span.RecordError(err)
+		span.End()
+		if err != nil {
 			return err
 		}
 	}
@@ -78,6 +78,30 @@ func TestRead(t *testing.T) {
 		}
 	})
 
+	t.Run("CountSQL", func(t *testing.T) {
+		createData(t, db)
+
+		reader := NewReader(&ReaderConfig{
+			DB:       db,
+			SQL:      "select * from users order by id",
+			CountSQL: "select count(*) from users",
+		})
+
+		se := middleware.NewStepExecution(nil, "ReadStep")
+		ctx := middleware.WithStepExecution(context.TODO(), se)
+		ch := make(chan interface{})
+		go func() {
+			if err := reader.Read(ctx, ch); err != nil {
+				t.Error(err)
+			}
+		}()
+		chunk := (<-ch).([]middleware.MapMapperType)
+
+		p := se.Progress()
+		assert.Equal(t, middleware.ProgressItems, p.Unit)
+		assert.Equal(t, uint64(len(chunk)), p.Total)
+	})
+
 	t.Run("MapMapperType with ChunkSize", func(t *testing.T) {
 		createData(t, db)
 
@@ -306,3 +330,87 @@ func CustomDtoMapper(ctx context.Context, ch chan<- interface{},
 
 	return nil
 }
+
+func TestReadWithArgs(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ddl, err := os.ReadFile("./testdata/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(ddl)); err != nil {
+		t.Fatal(err)
+	}
+	createData(t, db)
+
+	read := func(ctx context.Context, conf *ReaderConfig) ([]string, error) {
+		conf.DB = db
+		ch := make(chan interface{})
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- NewReader(conf).Read(ctx, ch)
+		}()
+		var names []string
+		for chunk := range ch {
+			for _, item := range chunk.([]middleware.MapMapperType) {
+				names = append(names, item["name"])
+			}
+		}
+		return names, <-errCh
+	}
+
+	t.Run("Args and ArgsFunc", func(t *testing.T) {
+		names, err := read(context.TODO(), &ReaderConfig{
+			SQL:  "select * from users where id >= ? and id <= ? order by id",
+			Args: []interface{}{1},
+			ArgsFunc: func(ctx context.Context) ([]interface{}, error) {
+				return []interface{}{3}, nil
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Andy", "Jessica", "Taro"}, names)
+	})
+
+	t.Run("NamedParams", func(t *testing.T) {
+		je := middleware.NewJobExecution("ReadJob", middleware.JobParameters{"min_id": "2"})
+		se := middleware.NewStepExecution(je, "ReadStep")
+		ctx := middleware.WithStepExecution(middleware.WithJobExecution(context.TODO(), je), se)
+		names, err := read(ctx, &ReaderConfig{
+			SQL:         "select * from users where id >= :min_id and id <= :max_id order by id",
+			CountSQL:    "select count(*) from users where id >= :min_id and id <= :max_id",
+			Args:        []interface{}{sql.Named("max_id", 4)},
+			NamedParams: true,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Jessica", "Taro", "Rovert"}, names)
+		assert.Equal(t, uint64(3), se.Progress().Total)
+	})
+
+	t.Run("Not found parameter", func(t *testing.T) {
+		_, err := read(context.TODO(), &ReaderConfig{
+			SQL:         "select * from users where id = :id",
+			NamedParams: true,
+		})
+		assert.EqualError(t, err, "Not found parameter: id")
+	})
+
+	t.Run("Positional argument with NamedParams", func(t *testing.T) {
+		_, err := read(context.TODO(), &ReaderConfig{
+			SQL:         "select * from users where id = :id",
+			Args:        []interface{}{1},
+			NamedParams: true,
+		})
+		assert.EqualError(t, err, "Not supported positional argument with NamedParams: 1")
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := read(ctx, &ReaderConfig{SQL: "select * from users"})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
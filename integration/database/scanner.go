@@ -0,0 +1,219 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// valueKind is the Go type of the values of a column.
+type valueKind int
+
+const (
+	kindAny valueKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindString
+	kindBytes
+	kindTime
+)
+
+var (
+	bytesType    = reflect.TypeOf([]byte(nil))
+	rawBytesType = reflect.TypeOf(sql.RawBytes(nil))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// kindOf returns the kind of the scan type of the column.
+// sql.Null* and the pointers are the kinds of their values.
+func kindOf(ct *sql.ColumnType) valueKind {
+	t := ct.ScanType()
+	if t == nil {
+		return kindAny
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case bytesType, rawBytesType:
+		return kindBytes
+	case timeType, reflect.TypeOf(sql.NullTime{}):
+		return kindTime
+	case reflect.TypeOf(sql.NullString{}):
+		return kindString
+	case reflect.TypeOf(sql.NullInt64{}), reflect.TypeOf(sql.NullInt32{}),
+		reflect.TypeOf(sql.NullInt16{}), reflect.TypeOf(sql.NullByte{}):
+		return kindInt
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return kindFloat
+	case reflect.TypeOf(sql.NullBool{}):
+		return kindBool
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return kindInt
+	case reflect.Float32, reflect.Float64:
+		return kindFloat
+	case reflect.Bool:
+		return kindBool
+	case reflect.String:
+		return kindString
+	}
+	return kindAny
+}
+
+// recordScanner scans rows into middleware.Record
+// by the types of the columns.
+type recordScanner struct {
+	cols  []string
+	kinds []valueKind
+	vals  []interface{}
+}
+
+func newRecordScanner(rows *sql.Rows) (*recordScanner, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	s := &recordScanner{
+		cols:  cols,
+		kinds: make([]valueKind, len(cols)),
+		vals:  make([]interface{}, len(cols)),
+	}
+	for i, ct := range types {
+		s.kinds[i] = kindOf(ct)
+		s.vals[i] = new(interface{})
+	}
+	return s, nil
+}
+
+func (s *recordScanner) scan(rows *sql.Rows) (*middleware.Record, error) {
+	if err := rows.Scan(s.vals...); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(s.cols))
+	for i, v := range s.vals {
+		values[i] = convertValue(*(v.(*interface{})), s.kinds[i])
+	}
+	return middleware.NewRecord(s.cols, values), nil
+}
+
+// convertValue converts the bytes returned by some drivers, e.g. MySQL,
+// to the Go type of kind.
+// The bytes are copied because the driver may reuse them.
+func convertValue(v interface{}, kind valueKind) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	s := string(b)
+	switch kind {
+	case kindInt:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case kindFloat:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case kindBool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case kindBytes:
+		return append([]byte{}, b...)
+	}
+	return s
+}
+
+// ScanStruct scans the current row of rows into dest,
+// the pointer to a struct.
+// The columns are mapped to the fields by the prop tags, e.g. `prop:"id"`,
+// and the columns without the fields are ignored.
+// The fields of sql.Null* and the pointers receive NULL.
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Not supported such a destination type: %T", dest)
+	}
+	s, err := newStructScanner(v.Elem().Type(), cols)
+	if err != nil {
+		return err
+	}
+	return s.scanInto(rows, v.Elem())
+}
+
+// structScanner scans rows into the structs of typ.
+type structScanner struct {
+	typ reflect.Type
+
+	// fields are the indexes of the fields of the columns.
+	// It is -1 if the column has no field.
+	fields []int
+}
+
+func newStructScanner(typ reflect.Type, cols []string) (*structScanner, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Not supported such a struct type: %s", typ)
+	}
+	byTag := make(map[string]int, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get(middleware.CustomMapperTag)
+		if tag == "" || tag == "-" || !f.IsExported() {
+			continue
+		}
+		byTag[tag] = i
+	}
+	s := &structScanner{
+		typ:    typ,
+		fields: make([]int, len(cols)),
+	}
+	mapped := false
+	for i, col := range cols {
+		idx, ok := byTag[col]
+		if !ok {
+			idx = -1
+		}
+		mapped = mapped || ok
+		s.fields[i] = idx
+	}
+	if !mapped {
+		return nil, fmt.Errorf("Not found fields of columns by prop tags: %s", typ)
+	}
+	return s, nil
+}
+
+// scan returns the new struct of the current row.
+func (s *structScanner) scan(rows *sql.Rows) (interface{}, error) {
+	v := reflect.New(s.typ).Elem()
+	if err := s.scanInto(rows, v); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func (s *structScanner) scanInto(rows *sql.Rows, v reflect.Value) error {
+	dest := make([]interface{}, len(s.fields))
+	for i, idx := range s.fields {
+		if idx < 0 {
+			dest[i] = new(interface{})
+			continue
+		}
+		dest[i] = v.Field(idx).Addr().Interface()
+	}
+	return rows.Scan(dest...)
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"testing"
+)
+
+func openItemsDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`create table items (
+    id integer,
+    name text,
+    price real,
+    data blob
+)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`insert into items values
+(1, 'Pen', 1.5, x'0102'),
+(2, '', null, null),
+(3, null, 10, null)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func readAny(reader *Reader) ([]interface{}, error) {
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+	var chunks []interface{}
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, <-errCh
+}
+
+func TestReadRecords(t *testing.T) {
+	db := openItemsDB(t)
+
+	chunks, err := readAny(NewReader(&ReaderConfig{
+		DB:        db,
+		SQL:       "select * from items order by id",
+		ChunkSize: 2,
+		Records:   true,
+	}))
+	assert.NoError(t, err)
+	if !assert.Len(t, chunks, 2) {
+		return
+	}
+	records := append(chunks[0].([]*middleware.Record), chunks[1].([]*middleware.Record)...)
+	cols := []string{"id", "name", "price", "data"}
+	assert.Equal(t, []*middleware.Record{
+		{Columns: cols, Values: []interface{}{int64(1), "Pen", 1.5, []byte{1, 2}}},
+		{Columns: cols, Values: []interface{}{int64(2), "", nil, nil}},
+		{Columns: cols, Values: []interface{}{int64(3), nil, float64(10), nil}},
+	}, records)
+	assert.False(t, records[1].IsNull("name"))
+	assert.True(t, records[2].IsNull("name"))
+
+	items, err := middleware.ToMapMapperChunk(chunks[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []middleware.MapMapperType{
+		{"id": "3", "name": "", "price": "10", "data": ""},
+	}, items)
+}
+
+type Item struct {
+	ID    int64           `prop:"id"`
+	Name  sql.NullString  `prop:"name"`
+	Price *float64        `prop:"price"`
+	Data  []byte          `prop:"data"`
+	Note  string          `prop:"note"`
+	Other sql.NullFloat64 `prop:"-"`
+}
+
+func TestReadStruct(t *testing.T) {
+	db := openItemsDB(t)
+
+	chunks, err := readAny(NewReader(&ReaderConfig{
+		DB:     db,
+		SQL:    "select * from items order by id",
+		Struct: Item{},
+	}))
+	assert.NoError(t, err)
+	if !assert.Len(t, chunks, 1) {
+		return
+	}
+	price := 1.5
+	assert.Equal(t, []middleware.CustomMapperType{
+		{Props: Item{ID: 1, Name: sql.NullString{String: "Pen", Valid: true}, Price: &price, Data: []byte{1, 2}}},
+		{Props: Item{ID: 2, Name: sql.NullString{String: "", Valid: true}}},
+		{Props: Item{ID: 3, Price: func() *float64 { f := 10.0; return &f }()}},
+	}, chunks[0])
+
+	_, err = readAny(NewReader(&ReaderConfig{
+		DB:            db,
+		SQL:           "select * from items",
+		Struct:        Item{},
+		RowMapperFunc: CustomDtoMapper,
+	}))
+	assert.EqualError(t, err, "Not supported RowMapperFunc with Records or Struct")
+
+	_, err = readAny(NewReader(&ReaderConfig{
+		DB:     db,
+		SQL:    "select id as item_id from items",
+		Struct: Item{},
+	}))
+	assert.EqualError(t, err, "Not found fields of columns by prop tags: database.Item")
+}
+
+func TestScanStruct(t *testing.T) {
+	db := openItemsDB(t)
+
+	rows, err := db.Query("select id, name, 'x' as extra from items where id = 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	var item Item
+	assert.NoError(t, ScanStruct(rows, &item))
+	assert.Equal(t, Item{ID: 3}, item)
+
+	assert.EqualError(t, ScanStruct(rows, item), "Not supported such a destination type: database.Item")
+}
+
+func TestReadChunkContexts(t *testing.T) {
+	db := openItemsDB(t)
+
+	for _, conf := range []*ReaderConfig{
+		{DB: db, SQL: "select * from items", ChunkSize: 2},
+		{DB: db, SQL: "select * from items", ChunkSize: 2, Records: true},
+		{DB: db, SQL: "select * from items", ChunkSize: 2, Struct: Item{}},
+	} {
+		var indexes []uint64
+		ctx := middleware.WithChunkContext(context.TODO(), func(index uint64) context.Context {
+			indexes = append(indexes, index)
+			return context.TODO()
+		})
+
+		ch := make(chan interface{})
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- NewReader(conf).Read(ctx, ch)
+		}()
+		for range ch {
+		}
+		assert.NoError(t, <-errCh)
+		assert.Equal(t, []uint64{0, 1}, indexes)
+	}
+}
@@ -6,12 +6,17 @@ import (
 	"fmt"
 	"github.com/yackrru/wolfx/middleware"
 	"reflect"
+	"strconv"
+	"sync"
 )
 
 // Writer is an implementation of middleware.Writer.
 // It is used to write data to database.
 type Writer struct {
 	conf *WriterConfig
+
+	mu       sync.Mutex
+	affected int64
 }
 
 // WriterConfig is the configuration of Writer.
@@ -19,68 +24,224 @@ type WriterConfig struct {
 	DB *sql.DB
 
 	// SQL is a insert dml string.
+	// If Table is specified, SQL is generated by Mode instead.
 	SQL string
 
+	// If NamedParams is true, the named parameters like :email or @email in SQL
+	// are bound to the props of the same names, the keys of MapMapperType
+	// or the prop tags of CustomMapperType, instead of PropsBindPosition.
+	// The names are validated against the prop tags of CustomMapperType
+	// once with the first item before any chunk is executed,
+	// and writing fails if a prop of the names is not found in an item
+	// even if RejectWriter is specified.
+	NamedParams bool
+
+	// Placeholder is the style of the placeholders replacing the named parameters.
+	// If specify 0, the placeholders of Dialect are used,
+	// e.g. PlaceholderDollar for DialectPostgres.
+	Placeholder Placeholder
+
+	// Table is the table name to generate SQL by Mode.
+	// The columns are bound to the props of the same names
+	// as NamedParams.
+	Table string
+
+	// Mode is the kind of the dml generated with Table.
+	// If specify 0, ModeInsert is used.
+	Mode Mode
+
+	// Dialect is the SQL dialect of the database for ModeUpsert, the placeholders
+	// and the savepoints of RejectWriter.
+	// The placeholders of the dml generated with Table are always those of Dialect.
+	// If specify 0, DialectStandard is used.
+	Dialect Dialect
+
+	// KeyColumns are the columns identifying the rows
+	// by ModeUpsert, ModeUpdate and ModeDelete.
+	KeyColumns []string
+
+	// ValueColumns are the columns other than KeyColumns
+	// written by ModeInsert, ModeUpsert and ModeUpdate.
+	ValueColumns []string
+
 	// If Transactional is true, Writer writes data under transaction.
 	Transactional bool
 
 	// PropsBindPosition is the position mapping of columns.
 	// Keys are column names and values are positions starting from 0.
 	PropsBindPosition map[string]uint
+
+	// RejectWriter receives the items failed to execute SQL,
+	// e.g. by constraint violations, instead of failing the step.
+	// The rejects have the number of the item in the chunk and Table as Source.
+	// If Transactional is true, each item is executed in a savepoint
+	// of Dialect so that the failure does not abort the transaction.
+	RejectWriter middleware.RejectWriter
+
+	// RejectIf reports whether the error of executing SQL is rejected.
+	// If it is nil, all errors are rejected.
+	RejectIf func(err error) bool
+
+	// BatchSize is the number of rows inserted by a statement.
+	// If it is greater than 1, SQL must be an insert dml string of a single row,
+	// e.g. "insert into users (id, name) values (?, ?)",
+	// and its VALUES is repeated as "values (?, ?), (?, ?), ...".
+	// The placeholders like $1 are renumbered for each row.
+	BatchSize uint
+
+	// MaxParams is the maximum number of the parameters per statement
+	// of the driver, which limits the rows of BatchSize.
+	// If specify 0, DefaultMaxParams is used.
+	MaxParams uint
+
+	// CommitInterval is the number of items committed by a transaction.
+	// If CommitInterval is specified or CommitPerChunk is true,
+	// Writer writes data under transaction regardless of Transactional
+	// and a failure rolls back only the items after the last commit.
+	// If specify 0 and CommitPerChunk is false with Transactional,
+	// all items are committed by one transaction.
+	CommitInterval uint
+
+	// If CommitPerChunk is true, each chunk is committed by a transaction.
+	// With CommitInterval, the transaction is also committed
+	// every CommitInterval items in a chunk.
+	CommitPerChunk bool
+
+	// RestartCount is the number of the items committed by the failed execution,
+	// e.g. the value of CommittedCountKey in its execution context.
+	// The first RestartCount items are skipped to restart after them.
+	RestartCount uint64
 }
 
+// placeholder returns the style of the placeholders replacing the named parameters.
+func (c *WriterConfig) placeholder() Placeholder {
+	if c.Placeholder == PlaceholderQuestion {
+		return c.Dialect.placeholder()
+	}
+	return c.Placeholder
+}
+
+// CommittedCountKey is the key of the execution context
+// to which Writer puts the number of the committed items
+// including RestartCount as a decimal string.
+// Without transaction, it is updated when each chunk is written
+// and counts the items executed before the failure.
+const CommittedCountKey = "database.Writer.committed"
+
+// AffectedRowsKey is the key of the execution context
+// to which Writer puts the number of the rows affected by the committed items
+// as a decimal string.
+const AffectedRowsKey = "database.Writer.affected"
+
+// rejectSavepoint is the savepoint of each item
+// if WriterConfig.RejectWriter is specified under transaction.
+const rejectSavepoint = "wolfx_reject"
+
 func NewWriter(conf *WriterConfig) *Writer {
 	return &Writer{
 		conf: conf,
 	}
 }
 
+// AffectedRows returns the number of the rows affected by the items
+// committed by the last Write, as reported by the driver.
+// e.g. MySQL reports 2 for each row updated by ModeUpsert.
+func (w *Writer) AffectedRows() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.affected
+}
+
 func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) error {
-	var tx *sql.Tx
-	if w.conf.Transactional {
-		var err error
-		tx, err = w.conf.DB.Begin()
+	query := w.conf.SQL
+	var names []string
+	if w.conf.Table != "" {
+		d, err := newDML(w.conf)
+		if err != nil {
+			return err
+		}
+		query, names = d.query, d.names
+	} else if w.conf.NamedParams {
+		q := compileNamed(query, w.conf.placeholder(), ":@")
+		query, names = q.query, q.names
+	}
+
+	stmts := &statements{
+		db:    w.conf.DB,
+		query: query,
+	}
+	if w.conf.BatchSize > 1 {
+		batch, err := newBatchInsert(query)
 		if err != nil {
 			return err
 		}
+		stmts.batch = batch
 	}
 
+	c := &committer{
+		writer:    w,
+		stmts:     stmts,
+		committed: w.conf.RestartCount,
+	}
+	w.mu.Lock()
+	w.affected = 0
+	w.mu.Unlock()
+	skip := w.conf.RestartCount
+
+	validated := names == nil
 	executable := func() error {
+		defer stmts.close()
 		for chunk := range ch {
-			var items [][]string
-			switch chunk.(type) {
-			case []middleware.MapMapperType:
-				items = middleware.MapMapperToFlatItems(chunk.([]middleware.MapMapperType),
-					w.conf.PropsBindPosition)
-			case []middleware.CustomMapperType:
-				items = middleware.CustomMapperToFlatItems(chunk.([]middleware.CustomMapperType),
-					w.conf.PropsBindPosition)
-			default:
-				v := reflect.ValueOf(chunk)
-				return fmt.Errorf("Not supported such a chunk type: %s", v.Type())
+			ctx := middleware.NextChunkContext(ctx)
+			if !validated {
+				var err error
+				if validated, err = validatePropTags(chunk, names); err != nil {
+					return err
+				}
+			}
+			props, err := middleware.ToMapMapperChunk(chunk)
+			if err != nil {
+				return err
+			}
+			var items []item
+			if names != nil {
+				items, err = namedItems(props, names)
+			} else {
+				items = flatItems(middleware.MapMapperToFlatItems(props, w.conf.PropsBindPosition))
+			}
+			if err != nil {
+				return err
 			}
 
-			for _, item := range items {
-				var stmt *sql.Stmt
-				var err error
-				if w.conf.Transactional {
-					stmt, err = tx.Prepare(w.conf.SQL)
-					if err != nil {
-						return err
-					}
-				} else {
-					stmt, err = w.conf.DB.Prepare(w.conf.SQL)
-					if err != nil {
-						return err
-					}
+			if skip > 0 {
+				n := skip
+				if n > uint64(len(items)) {
+					n = uint64(len(items))
 				}
+				items = items[n:]
+				skip -= n
+			}
 
-				defer stmt.Close()
-				args := make([]interface{}, len(item))
-				for idx, e := range item {
-					args[idx] = e
+			for len(items) > 0 {
+				if err := c.begin(ctx); err != nil {
+					return err
+				}
+				n := c.room(len(items))
+				done, affected, err := w.writeItems(ctx, stmts, items[:n])
+				if err != nil {
+					if c.stmts.tx == nil {
+						// The items executed before the error are not rolled back.
+						c.add(ctx, done, affected)
+					}
+					return err
 				}
-				if _, err := stmt.Exec(args...); err != nil {
+				items = items[n:]
+				if err := c.add(ctx, n, affected); err != nil {
+					return err
+				}
+			}
+			if w.conf.CommitPerChunk {
+				if err := c.commit(ctx); err != nil {
 					return err
 				}
 			}
@@ -88,24 +249,331 @@ func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) error {
 		return nil
 	}
 
-	err := executable()
+	if err := executable(); err != nil {
+		c.rollback(ctx)
+		return err
+	}
+	return c.commit(ctx)
+}
+
+// validatePropTags validates that the type of the first item of chunk
+// has the prop tags of names if chunk is of CustomMapperType.
+// It reports whether the first item is validated.
+func validatePropTags(chunk interface{}, names []string) (bool, error) {
+	structs, ok := chunk.([]middleware.CustomMapperType)
+	if !ok {
+		return true, nil
+	}
+	if len(structs) == 0 {
+		return false, nil
+	}
+
+	t := reflect.TypeOf(structs[0].Props)
+	tags := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tags[t.Field(i).Tag.Get(middleware.CustomMapperTag)] = true
+	}
+	for _, name := range names {
+		if !tags[name] {
+			return false, fmt.Errorf("Not found prop of named parameter: %s", name)
+		}
+	}
+	return true, nil
+}
+
+// item is an item of a chunk to execute SQL with.
+type item struct {
+	// values are the values bound to SQL.
+	values []string
+
+	// number is the number of the item in the chunk starting from 1.
+	number int
+}
+
+// flatItems converts the flat items of a chunk to items.
+func flatItems(values [][]string) []item {
+	items := make([]item, len(values))
+	for i, v := range values {
+		items[i] = item{values: v, number: i + 1}
+	}
+	return items
+}
+
+// namedItems converts props to the items of the values of the props of names.
+// It fails if a prop of names is not found in any of props.
+func namedItems(props []middleware.MapMapperType, names []string) ([]item, error) {
+	items := make([]item, len(props))
+	for i, prop := range props {
+		items[i] = item{values: make([]string, len(names)), number: i + 1}
+		for j, name := range names {
+			v, ok := prop[name]
+			if !ok {
+				return nil, fmt.Errorf("Not found prop of named parameter: %s", name)
+			}
+			items[i].values[j] = v
+		}
+	}
+	return items, nil
+}
+
+// writeItems executes SQL with items in order.
+// It returns the number of the items executed or rejected and the number of the affected rows,
+// which are those before the error if it fails.
+func (w *Writer) writeItems(ctx context.Context, stmts *statements, items []item) (int, int64, error) {
+	if stmts.batch != nil {
+		return w.writeBatch(ctx, stmts, items)
+	}
+	var affected int64
+	for i, it := range items {
+		n, err := w.writeItem(ctx, stmts, it)
+		if err != nil {
+			return i, affected, err
+		}
+		affected += n
+	}
+	return len(items), affected, nil
+}
+
+// writeItem executes SQL with it.
+// It returns the number of the affected rows.
+func (w *Writer) writeItem(ctx context.Context, stmts *statements, it item) (int64, error) {
+	stmt, err := stmts.get(ctx, 1)
+	if err != nil {
+		return 0, err
+	}
+	affected, rejected, err := w.exec(ctx, stmts.tx, stmt, toArgs(it.values))
 	if err != nil {
-		if w.conf.Transactional {
-			if errRb := tx.Rollback(); errRb != nil {
-				middleware.Logger.Error(errRb)
+		return 0, err
+	}
+	if rejected != nil {
+		if err := w.reject(ctx, it, rejected); err != nil {
+			return 0, err
+		}
+	}
+	return affected, nil
+}
+
+// reject sends it rejected by err to RejectWriter.
+func (w *Writer) reject(ctx context.Context, it item, err error) error {
+	reject := &middleware.Reject{
+		Source: w.conf.Table,
+		Item:   it.number,
+		Values: it.values,
+		Err:    err,
+	}
+	return middleware.WriteReject(ctx, w.conf.RejectWriter, reject)
+}
+
+// writeBatch executes the multi-row insert of items.
+// If a batch is rejected, its items are executed one by one
+// so that only the failed items are rejected.
+// It returns the number of the items executed or rejected
+// and the number of the affected rows as writeItems.
+func (w *Writer) writeBatch(ctx context.Context, stmts *statements, items []item) (int, int64, error) {
+	maxParams := int(w.conf.MaxParams)
+	if maxParams == 0 {
+		maxParams = DefaultMaxParams
+	}
+	size := stmts.batch.rowsPerStmt(int(w.conf.BatchSize), maxParams)
+
+	var total int64
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		rows := items[start:end]
+		stmt, err := stmts.get(ctx, len(rows))
+		if err != nil {
+			return start, total, err
+		}
+		var args []interface{}
+		for _, it := range rows {
+			args = append(args, toArgs(it.values)...)
+		}
+		affected, rejected, err := w.exec(ctx, stmts.tx, stmt, args)
+		if err != nil {
+			return start, total, err
+		}
+		if rejected == nil {
+			total += affected
+			continue
+		}
+		for i, it := range rows {
+			affected, err := w.writeItem(ctx, stmts, it)
+			if err != nil {
+				return start + i, total, err
 			}
+			total += affected
 		}
-		return err
+	}
+	return len(items), total, nil
+}
+
+func toArgs(item []string) []interface{} {
+	args := make([]interface{}, len(item))
+	for idx, e := range item {
+		args[idx] = e
+	}
+	return args
+}
+
+// exec executes stmt with args and returns the number of the affected rows.
+// It is 0 if the driver does not report it.
+// If the error of executing is rejected, it is returned as rejected.
+func (w *Writer) exec(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, args []interface{}) (affected int64, rejected error, err error) {
+	if w.conf.RejectWriter == nil {
+		res, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return 0, nil, err
+		}
+		return rowsAffected(res), nil, nil
 	}
 
-	if w.conf.Transactional {
-		if err := tx.Commit(); err != nil {
-			if errRb := tx.Rollback(); errRb != nil {
-				middleware.Logger.Error(errRb)
+	set, rollback, release := w.conf.Dialect.savepoint(rejectSavepoint)
+	if tx != nil {
+		if _, err := tx.ExecContext(ctx, set); err != nil {
+			return 0, nil, err
+		}
+	}
+	res, errExec := stmt.ExecContext(ctx, args...)
+	// The savepoint is released even if the error is not rejected,
+	// so that the transaction is not left in it.
+	if tx != nil {
+		if errExec != nil {
+			if _, err := tx.ExecContext(ctx, rollback); err != nil {
+				return 0, nil, err
+			}
+		}
+		if release != "" {
+			if _, err := tx.ExecContext(ctx, release); err != nil {
+				return 0, nil, err
 			}
-			return err
 		}
 	}
+	if errExec != nil {
+		if w.conf.RejectIf != nil && !w.conf.RejectIf(errExec) {
+			return 0, nil, errExec
+		}
+		return 0, errExec, nil
+	}
+	return rowsAffected(res), nil, nil
+}
+
+func rowsAffected(res sql.Result) int64 {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// committer commits the transactions of Writer by the commit interval
+// and puts the number of the committed items and the affected rows
+// to the execution context.
+type committer struct {
+	writer *Writer
+	stmts  *statements
+
+	// pending is the number of the items written in the current transaction
+	// and pendingAffected is the number of the rows affected by them.
+	pending         uint64
+	pendingAffected int64
+	committed       uint64
+}
+
+func (c *committer) transactional() bool {
+	conf := c.writer.conf
+	return conf.Transactional || conf.CommitInterval > 0 || conf.CommitPerChunk
+}
+
+// begin begins the transaction if not begun.
+func (c *committer) begin(ctx context.Context) error {
+	if !c.transactional() || c.stmts.tx != nil {
+		return nil
+	}
+	tx, err := c.writer.conf.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	c.stmts.tx = tx
+	return nil
+}
+
+// room returns the number of the items up to n
+// which can be written before the commit by CommitInterval.
+func (c *committer) room(n int) int {
+	interval := uint64(c.writer.conf.CommitInterval)
+	if interval == 0 {
+		return n
+	}
+	if rest := interval - c.pending; rest < uint64(n) {
+		return int(rest)
+	}
+	return n
+}
 
+// add counts n items written with the affected rows
+// and commits the transaction if it reaches CommitInterval.
+func (c *committer) add(ctx context.Context, n int, affected int64) error {
+	c.pending += uint64(n)
+	c.pendingAffected += affected
+	if c.stmts.tx == nil {
+		c.checkpoint(ctx)
+		return nil
+	}
+	interval := uint64(c.writer.conf.CommitInterval)
+	if interval > 0 && c.pending >= interval {
+		return c.commit(ctx)
+	}
 	return nil
 }
+
+// commit commits the transaction if begun.
+// The statements prepared in it are closed.
+func (c *committer) commit(ctx context.Context) error {
+	tx := c.stmts.tx
+	if tx == nil {
+		return nil
+	}
+	c.stmts.close()
+	c.stmts.tx = nil
+	if err := tx.Commit(); err != nil {
+		c.pending, c.pendingAffected = 0, 0
+		return err
+	}
+	c.checkpoint(ctx)
+	return nil
+}
+
+// rollback rolls back the transaction if begun.
+// The items committed before are kept.
+func (c *committer) rollback(ctx context.Context) {
+	tx := c.stmts.tx
+	if tx == nil {
+		return
+	}
+	c.stmts.close()
+	c.stmts.tx = nil
+	c.pending, c.pendingAffected = 0, 0
+	if err := tx.Rollback(); err != nil {
+		middleware.LoggerFromContext(ctx).Error(err)
+	}
+}
+
+// checkpoint moves the pending items to the committed.
+func (c *committer) checkpoint(ctx context.Context) {
+	c.committed += c.pending
+	c.pending = 0
+
+	w := c.writer
+	w.mu.Lock()
+	w.affected += c.pendingAffected
+	affected := w.affected
+	w.mu.Unlock()
+	c.pendingAffected = 0
+
+	execCtx := middleware.ExecutionContextFromContext(ctx)
+	execCtx.Put(CommittedCountKey, strconv.FormatUint(c.committed, 10))
+	execCtx.Put(AffectedRowsKey, strconv.FormatInt(affected, 10))
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/integration/file"
 	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
 )
@@ -169,3 +173,391 @@ func TestCustomMapperTypeWrite(t *testing.T) {
 		count++
 	}
 }
+
+func TestWriteWithRejectWriter(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Without transaction", true: "With transaction"}[transactional], func(t *testing.T) {
+			db, err := sql.Open("sqlite3", ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+			if _, err := db.Exec("create table users (id integer primary key, name text not null)"); err != nil {
+				t.Fatal(err)
+			}
+
+			var rejects []*middleware.Reject
+			writer := NewWriter(&WriterConfig{
+				DB:                db,
+				SQL:               "insert into users values (?, nullif(?, ''))",
+				Transactional:     transactional,
+				PropsBindPosition: middleware.PropsBindPosition{"id": 0, "name": 1},
+				RejectWriter: middleware.RejectWriterFunc(func(ctx context.Context, reject *middleware.Reject) error {
+					rejects = append(rejects, reject)
+					return nil
+				}),
+			})
+
+			se := middleware.NewStepExecution(nil, "LoadStep")
+			ctx := middleware.WithStepExecution(context.TODO(), se)
+			ch := make(chan interface{}, 1)
+			ch <- []middleware.MapMapperType{
+				{"id": "1", "name": "name1"},
+				{"id": "1", "name": "duplicated"},
+				{"id": "2", "name": ""},
+				{"id": "3", "name": "name3"},
+			}
+			close(ch)
+			assert.NoError(t, writer.Write(ctx, ch))
+
+			var count int
+			if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 2, count)
+			assert.Equal(t, uint64(2), se.SkipCount())
+			if assert.Len(t, rejects, 2) {
+				assert.Equal(t, "LoadStep", rejects[0].StepName)
+				assert.Equal(t, 2, rejects[0].Item)
+				assert.Equal(t, []string{"1", "duplicated"}, rejects[0].Values)
+				assert.Contains(t, rejects[0].Err.Error(), "UNIQUE constraint failed")
+				assert.Equal(t, 3, rejects[1].Item)
+				assert.Equal(t, []string{"2", ""}, rejects[1].Values)
+				assert.Contains(t, rejects[1].Err.Error(), "NOT NULL constraint failed")
+			}
+		})
+	}
+}
+
+func TestWriteWithRejectIf(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("create table users (id integer primary key)"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []Dialect{DialectStandard, DialectSQLite} {
+		for _, transactional := range []bool{false, true} {
+			if _, err := db.Exec("delete from users"); err != nil {
+				t.Fatal(err)
+			}
+			writer := NewWriter(&WriterConfig{
+				DB:                db,
+				SQL:               "insert into users values (?)",
+				Dialect:           dialect,
+				Transactional:     transactional,
+				PropsBindPosition: middleware.PropsBindPosition{"id": 0},
+				RejectWriter: middleware.RejectWriterFunc(func(ctx context.Context, reject *middleware.Reject) error {
+					t.Error("Unexpected reject")
+					return nil
+				}),
+				RejectIf: func(err error) bool {
+					return false
+				},
+			})
+			write := func(chunk []middleware.MapMapperType) error {
+				ch := make(chan interface{}, 1)
+				ch <- chunk
+				close(ch)
+				return writer.Write(context.TODO(), ch)
+			}
+			assert.Error(t, write([]middleware.MapMapperType{{"id": "1"}, {"id": "1"}}))
+
+			// The savepoint is rolled back with the error not rejected,
+			// so that the writer is available again.
+			assert.NoError(t, write([]middleware.MapMapperType{{"id": "2"}}))
+			var ids []int
+			rows, err := db.Query("select id from users order by id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+				var id int
+				if err := rows.Scan(&id); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			rows.Close()
+			if transactional {
+				assert.Equal(t, []int{2}, ids)
+			} else {
+				assert.Equal(t, []int{1, 2}, ids)
+			}
+		}
+	}
+}
+
+func TestWriteWithCommitInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      WriterConfig
+		count     int
+		committed string
+	}{
+		{"Every 2 items", WriterConfig{CommitInterval: 2}, 8, "8"},
+		{"Per chunk", WriterConfig{CommitPerChunk: true}, 6, "6"},
+		{"Per chunk and every 2 items", WriterConfig{CommitInterval: 2, CommitPerChunk: true}, 8, "8"},
+		{"One transaction", WriterConfig{Transactional: true}, 0, ""},
+		{"Without transaction", WriterConfig{}, 8, "8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openWriterDB(t)
+			conf := tt.conf
+			conf.DB = db
+			conf.SQL = "insert into users (id, name) values (?, ?)"
+			conf.PropsBindPosition = map[string]uint{"id": 0, "name": 1}
+
+			se := middleware.NewStepExecution(nil, "LoadStep")
+			ctx := middleware.WithStepExecution(context.TODO(), se)
+			ch := make(chan interface{}, 3)
+			ch <- userChunk(0, 3)
+			ch <- userChunk(3, 6)
+			ch <- append(userChunk(6, 8), userChunk(0, 1)...)
+			close(ch)
+			assert.Error(t, NewWriter(&conf).Write(ctx, ch))
+
+			var count int
+			if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.count, count)
+			assert.Equal(t, tt.committed, se.ExecutionContext().GetString(CommittedCountKey))
+		})
+	}
+}
+
+func TestWriteWithRestartCount(t *testing.T) {
+	db := openWriterDB(t)
+	if _, err := db.Exec("insert into users values (0, 'name0'), (1, 'name1'), (2, 'name2'), (3, 'name3')"); err != nil {
+		t.Fatal(err)
+	}
+
+	se := middleware.NewStepExecution(nil, "LoadStep")
+	ctx := middleware.WithStepExecution(context.TODO(), se)
+	ch := make(chan interface{}, 2)
+	ch <- userChunk(0, 3)
+	ch <- userChunk(3, 6)
+	close(ch)
+	writer := NewWriter(&WriterConfig{
+		DB:                db,
+		SQL:               "insert into users (id, name) values (?, ?)",
+		PropsBindPosition: map[string]uint{"id": 0, "name": 1},
+		CommitPerChunk:    true,
+		RestartCount:      4,
+	})
+	assert.NoError(t, writer.Write(ctx, ch))
+
+	var count int
+	if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 6, count)
+	assert.Equal(t, "6", se.ExecutionContext().GetString(CommittedCountKey))
+}
+
+func TestWriteWithNamedParams(t *testing.T) {
+	db := openWriterDB(t)
+
+	writer := NewWriter(&WriterConfig{
+		DB:          db,
+		SQL:         "insert into users (name, id) values (upper(:name), @id)",
+		NamedParams: true,
+		BatchSize:   2,
+	})
+	assert.NoError(t, writeChunks(writer, userChunk(0, 3)))
+
+	ch := make(chan interface{}, 1)
+	ch <- []middleware.CustomMapperType{{Props: TestChunkType{Id: "3", Name: "name3"}}}
+	close(ch)
+	assert.NoError(t, writer.Write(context.TODO(), ch))
+
+	rows, err := db.Query("select id, name from users order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, id+":"+name)
+	}
+	assert.Equal(t, []string{"0:NAME0", "1:NAME1", "2:NAME2", "3:NAME3"}, got)
+
+	writer = NewWriter(&WriterConfig{
+		DB:          db,
+		SQL:         "insert into users (id, name) values (:id, :email)",
+		NamedParams: true,
+	})
+	assert.EqualError(t, writeChunks(writer, userChunk(4, 6)), "Not found prop of named parameter: email")
+
+	// The names are validated against the prop tags.
+	ch = make(chan interface{}, 1)
+	ch <- []middleware.CustomMapperType{{Props: TestChunkType{Id: "4", Name: "name4"}}}
+	close(ch)
+	assert.EqualError(t, writer.Write(context.TODO(), ch), "Not found prop of named parameter: email")
+	var count int
+	if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, count)
+}
+
+func TestWriterConfigPlaceholder(t *testing.T) {
+	assert.Equal(t, PlaceholderQuestion, (&WriterConfig{}).placeholder())
+	assert.Equal(t, PlaceholderDollar, (&WriterConfig{Dialect: DialectPostgres}).placeholder())
+	assert.Equal(t, PlaceholderDollar, (&WriterConfig{Placeholder: PlaceholderDollar}).placeholder())
+}
+
+func TestWriteWithNamedParamsNotFoundInLaterChunk(t *testing.T) {
+	db := openWriterDB(t)
+
+	se := middleware.NewStepExecution(nil, "LoadStep")
+	ctx := middleware.WithStepExecution(context.TODO(), se)
+	ch := make(chan interface{}, 2)
+	ch <- userChunk(0, 3)
+	ch <- []middleware.MapMapperType{{"id": "3"}}
+	close(ch)
+	writer := NewWriter(&WriterConfig{
+		DB:             db,
+		SQL:            "insert into users (id, name) values (:id, :name)",
+		NamedParams:    true,
+		CommitInterval: 2,
+	})
+	assert.EqualError(t, writer.Write(ctx, ch), "Not found prop of named parameter: name")
+
+	// The item pending in the interval is rolled back.
+	var count int
+	if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "2", se.ExecutionContext().GetString(CommittedCountKey))
+}
+
+func TestWriteWithNamedParamsNotFoundInLaterItem(t *testing.T) {
+	for _, batchSize := range []uint{0, 2} {
+		t.Run("BatchSize "+strconv.Itoa(int(batchSize)), func(t *testing.T) {
+			db := openWriterDB(t)
+
+			ch := make(chan interface{}, 1)
+			ch <- []middleware.MapMapperType{
+				{"id": "0", "name": "name0"},
+				{"id": "1"},
+				{"id": "2", "name": "name2"},
+			}
+			close(ch)
+			writer := NewWriter(&WriterConfig{
+				DB:           db,
+				Table:        "users",
+				KeyColumns:   []string{"id"},
+				ValueColumns: []string{"name"},
+				BatchSize:    batchSize,
+				RejectWriter: middleware.RejectWriterFunc(func(ctx context.Context, reject *middleware.Reject) error {
+					t.Error("Unexpected reject")
+					return nil
+				}),
+			})
+			assert.EqualError(t, writer.Write(context.TODO(), ch), "Not found prop of named parameter: name")
+
+			// The chunk is not executed.
+			var count int
+			if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 0, count)
+		})
+	}
+
+	t.Run("Prop tags", func(t *testing.T) {
+		db := openWriterDB(t)
+
+		type idOnly struct {
+			Id string `prop:"id"`
+		}
+		ch := make(chan interface{}, 1)
+		ch <- []middleware.CustomMapperType{
+			{Props: TestChunkType{Id: "0", Name: "name0"}},
+			{Props: idOnly{Id: "1"}},
+		}
+		close(ch)
+		writer := NewWriter(&WriterConfig{
+			DB:          db,
+			SQL:         "insert into users (id, name) values (:id, :name)",
+			NamedParams: true,
+		})
+		assert.EqualError(t, writer.Write(context.TODO(), ch), "Not found prop of named parameter: name")
+
+		var count int
+		if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 0, count)
+	})
+}
+
+// ItemRow has the props of the columns selected by TestReaderToWriter.
+type ItemRow struct {
+	ID    int64          `prop:"id"`
+	Name  sql.NullString `prop:"name"`
+	Price *float64       `prop:"price"`
+}
+
+// TestReaderToWriter passes the chunks of Reader
+// with Records and Struct to file.Writer and Writer.
+func TestReaderToWriter(t *testing.T) {
+	transfer := func(t *testing.T, reader *Reader, writer middleware.Writer) {
+		ch := make(chan interface{})
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- reader.Read(context.TODO(), ch)
+		}()
+		assert.NoError(t, writer.Write(context.TODO(), ch))
+		assert.NoError(t, <-errCh)
+	}
+
+	for _, conf := range []ReaderConfig{{Records: true}, {Struct: ItemRow{}}} {
+		name := map[bool]string{true: "Records", false: "Struct"}[conf.Records]
+		t.Run(name, func(t *testing.T) {
+			conf := conf
+			conf.DB = openItemsDB(t)
+			conf.SQL = "select id, name, price from items order by id"
+
+			path := filepath.Join(t.TempDir(), "items.csv")
+			fileWriter, err := file.CreateWriter(path, &file.WriterOptions{
+				WriterConfig: file.WriterConfig{
+					PropsBindPosition: middleware.PropsBindPosition{"id": 0, "name": 1, "price": 2},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			transfer(t, NewReader(&conf), fileWriter)
+			b, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "id,name,price\n1,Pen,1.5\n2,,\n3,,10\n", string(b))
+
+			db := openWriterDB(t)
+			transfer(t, NewReader(&conf), NewWriter(&WriterConfig{
+				DB:          db,
+				SQL:         "insert into users (id, name) values (:id, nullif(:name, ''))",
+				NamedParams: true,
+			}))
+			var count, nulls int
+			if err := db.QueryRow("select count(*), count(*) - count(name) from users").Scan(&count, &nulls); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 3, count)
+			assert.Equal(t, 2, nulls)
+		})
+	}
+}
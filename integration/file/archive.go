@@ -0,0 +1,418 @@
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	_ middleware.Reader = new(ArchiveReader)
+	_ middleware.Writer = new(ArchiveWriter)
+)
+
+// MetaEntryKey is the key of the entry name added to items by ArchiveReader.
+const MetaEntryKey = "_entry"
+
+// ArchiveFormat is the format of archives.
+type ArchiveFormat int
+
+const (
+	// ArchiveAuto detects the format from the file extension.
+	ArchiveAuto ArchiveFormat = iota
+	ArchiveZip
+
+	// ArchiveTar is the tar archive,
+	// which may be compressed as a whole, e.g. ".tar.gz" and ".tgz".
+	ArchiveTar
+)
+
+var archiveFormatNames = map[ArchiveFormat]string{
+	ArchiveAuto: "auto",
+	ArchiveZip:  "zip",
+	ArchiveTar:  "tar",
+}
+
+func (f ArchiveFormat) String() string {
+	return archiveFormatNames[f]
+}
+
+// archiveFormat returns the format of the archive of path
+// and the compression of the whole archive.
+func archiveFormat(p string, f ArchiveFormat) (ArchiveFormat, Compression, error) {
+	name := strings.ToLower(filepath.Base(p))
+	if f == ArchiveAuto {
+		switch {
+		case strings.HasSuffix(name, ".zip"):
+			f = ArchiveZip
+		case strings.HasSuffix(name, ".tgz"),
+			strings.Contains(name, ".tar"):
+			f = ArchiveTar
+		default:
+			return 0, 0, fmt.Errorf("Not supported archive: %s", p)
+		}
+	}
+	if f != ArchiveTar {
+		return f, CompressionNone, nil
+	}
+	if strings.HasSuffix(name, ".tgz") {
+		return f, CompressionGzip, nil
+	}
+	return f, CompressionFromExt(name), nil
+}
+
+// matchEntry reports whether the entry name matches pattern.
+// The pattern without "/" is matched with the base name.
+func matchEntry(pattern, name string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	return path.Match(pattern, name)
+}
+
+// ArchiveReader is an implementation of middleware.Reader.
+// It reads the csv entries of a zip or tar archive in the order of the archive,
+// streaming each entry through Reader.
+type ArchiveReader struct {
+	conf *ArchiveReaderConfig
+}
+
+// ArchiveReaderConfig is the configuration of ArchiveReader.
+type ArchiveReaderConfig struct {
+	// Path is the path of the archive.
+	Path string
+
+	// Format is the format of the archive.
+	// If specify ArchiveAuto, it is detected from the extension,
+	// e.g. ".zip", ".tar", ".tar.gz" and ".tgz".
+	Format ArchiveFormat
+
+	// Pattern is the pattern of the entry names in the syntax of path.Match,
+	// e.g. "*.csv".
+	// The pattern without "/" is matched with the base name of the entries.
+	// If it is empty, all entries are read.
+	Pattern string
+
+	// Options are the options to read each entry as OpenReader.
+	// Compression applies to the entries, e.g. ".csv.gz" in the tar archive.
+	// ChunkSize, HeaderFunc, TrailerFunc and SumColumns apply to each entry,
+	// so a chunk never contains the items of two entries.
+	// The Source of the rejected records is "path!entry".
+	// Size and File are ignored.
+	Options ReaderOptions
+}
+
+func NewArchiveReader(conf *ArchiveReaderConfig) *ArchiveReader {
+	return &ArchiveReader{
+		conf: conf,
+	}
+}
+
+// Read sends the items of the entries with the entry name
+// by the key MetaEntryKey.
+func (r *ArchiveReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	format, c, err := archiveFormat(r.conf.Path, r.conf.Format)
+	if err != nil {
+		return err
+	}
+	if format == ArchiveZip {
+		return r.readZip(ctx, ch)
+	}
+	return r.readTar(ctx, ch, c)
+}
+
+func (r *ArchiveReader) readZip(ctx context.Context, ch chan<- interface{}) error {
+	zr, err := zip.OpenReader(r.conf.Path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		matched, err := matchEntry(r.conf.Pattern, f.Name)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = r.readEntry(ctx, ch, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ArchiveReader) readTar(ctx context.Context, ch chan<- interface{}, c Compression) error {
+	file, err := os.Open(r.conf.Path)
+	if err != nil {
+		return err
+	}
+	if c == CompressionNone {
+		// The tar archive may be compressed without the extension.
+		c = CompressionAuto
+	}
+	rc, err := NewDecompressReader(file, c, "")
+	if err != nil {
+		file.Close()
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		matched, err := matchEntry(r.conf.Pattern, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		if err := r.readEntry(ctx, ch, hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// readEntry reads the entry of name by Reader
+// and sends the items with the entry name to ch.
+func (r *ArchiveReader) readEntry(ctx context.Context, ch chan<- interface{}, name string, entry io.Reader) error {
+	opts := r.conf.Options
+	rc, err := NewDecompressReader(entry, opts.Compression, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var raw *rawRecorder
+	if opts.RejectWriter != nil {
+		raw = new(rawRecorder)
+	}
+	csvReader, err := newCSVReader(rc, &opts, raw)
+	if err != nil {
+		return err
+	}
+	conf := opts.ReaderConfig
+	conf.Reader = csvReader
+	conf.Size = 0
+	conf.File = nil
+	// The metadata is added before RowMapperFunc.
+	conf.RowMapperFunc = nil
+	reader := &Reader{
+		conf: &conf,
+		path: r.conf.Path + "!" + name,
+		raw:  raw,
+	}
+
+	entryCh := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		// The chunks are sent to ch by ArchiveReader.
+		errCh <- reader.Read(middleware.WithoutChunkContext(ctx), entryCh)
+	}()
+
+	var errSend error
+	for chunk := range entryCh {
+		// Drain entryCh for Reader to return.
+		if errSend != nil {
+			continue
+		}
+		items := chunk.([]middleware.MapMapperType)
+		for _, item := range items {
+			item[MetaEntryKey] = name
+		}
+		errSend = sendChunk(ctx, ch, items, opts.RowMapperFunc)
+	}
+	if err := <-errCh; err != nil {
+		return fmt.Errorf("%w in %s", err, name)
+	}
+	return errSend
+}
+
+// ArchiveWriter is an implementation of middleware.Writer.
+// It writes csv files split as RollingWriter into the entries of
+// a zip or tar archive.
+// The archive is written to a temp file and renamed to Path
+// when the step completes successfully, like WriterOptions.Atomic.
+type ArchiveWriter struct {
+	conf    *ArchiveWriterConfig
+	rolling *RollingWriter
+}
+
+// ArchiveWriterConfig is the configuration of ArchiveWriter.
+type ArchiveWriterConfig struct {
+	// Path is the path of the archive.
+	Path string
+
+	// Format is the format of the archive.
+	// If specify ArchiveAuto, it is detected from the extension,
+	// e.g. ".zip", ".tar", ".tar.gz" and ".tgz".
+	Format ArchiveFormat
+
+	// RollingWriterConfig is the configuration to split the rows.
+	// Template is the entry name, e.g. "sales_{{.Key}}.csv".
+	// The paths of the manifest are the entry names
+	// and the manifest is written outside the archive
+	// when the archive is committed.
+	RollingWriterConfig
+
+	// Atomic is the options of the sidecar files of the archive.
+	Atomic *AtomicOptions
+}
+
+func NewArchiveWriter(conf *ArchiveWriterConfig) *ArchiveWriter {
+	return &ArchiveWriter{
+		conf:    conf,
+		rolling: NewRollingWriter(&conf.RollingWriterConfig),
+	}
+}
+
+// Manifest returns the entries written so far.
+func (w *ArchiveWriter) Manifest() []ManifestEntry {
+	return w.rolling.Manifest()
+}
+
+func (w *ArchiveWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	format, c, err := archiveFormat(w.conf.Path, w.conf.Format)
+	if err != nil {
+		return err
+	}
+	if c == CompressionBzip2 {
+		return ErrNotSupportedCompression
+	}
+	atomic, err := CreateAtomic(w.conf.Path, w.conf.Atomic)
+	if err != nil {
+		return err
+	}
+	wc, err := NewCompressWriter(atomic, c)
+	if err != nil {
+		atomic.Abort()
+		return err
+	}
+
+	var output archiveOutput
+	if format == ArchiveZip {
+		output = &zipOutput{writer: zip.NewWriter(wc)}
+	} else {
+		output = &tarOutput{writer: tar.NewWriter(wc), dir: filepath.Dir(w.conf.Path)}
+	}
+	err = w.rolling.write(ctx, ch, output)
+	if errClose := output.finish(); err == nil {
+		err = errClose
+	}
+	if errClose := wc.Close(); err == nil {
+		err = errClose
+	}
+	// The manifest is written after the archive is committed.
+	return finishAtomic(ctx, atomic, err, w.rolling.writeManifest)
+}
+
+// archiveOutput is rollingOutput of the entries of an archive.
+type archiveOutput interface {
+	rollingOutput
+
+	// finish writes the end of the archive.
+	finish() error
+}
+
+// zipOutput is archiveOutput of the zip archive.
+type zipOutput struct {
+	writer *zip.Writer
+}
+
+func (o *zipOutput) create(path string) (io.Writer, error) {
+	return o.writer.CreateHeader(&zip.FileHeader{
+		Name:     path,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
+
+func (o *zipOutput) close() error {
+	return nil
+}
+
+func (o *zipOutput) finish() error {
+	return o.writer.Close()
+}
+
+// tarOutput is archiveOutput of the tar archive.
+// The entry is written to a temp file in dir
+// because its size precedes the content in the tar archive.
+type tarOutput struct {
+	writer *tar.Writer
+	dir    string
+
+	name string
+	temp *os.File
+}
+
+func (o *tarOutput) create(path string) (io.Writer, error) {
+	temp, err := os.CreateTemp(o.dir, ".wolfx-entry.*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	o.name = path
+	o.temp = temp
+	return temp, nil
+}
+
+func (o *tarOutput) close() error {
+	defer os.Remove(o.temp.Name())
+	defer o.temp.Close()
+
+	size, err := o.temp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := o.temp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := o.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     o.name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(o.writer, o.temp)
+	return err
+}
+
+func (o *tarOutput) finish() error {
+	return o.writer.Close()
+}
@@ -0,0 +1,293 @@
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content string
+}
+
+func gzipString(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func createZip(t *testing.T, path string, entries ...archiveEntry) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createTarGz(t *testing.T, path string, entries ...archiveEntry) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     e.name,
+			Mode:     0644,
+			Size:     int64(len(e.content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(gzipString(t, buf.String())), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readArchive(t *testing.T, reader *ArchiveReader) ([]middleware.MapMapperType, error) {
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+	var items []middleware.MapMapperType
+	for chunk := range ch {
+		items = append(items, chunk.([]middleware.MapMapperType)...)
+	}
+	return items, <-errCh
+}
+
+func TestArchiveReader(t *testing.T) {
+	entries := []archiveEntry{
+		{"a.csv", "id,name\n1,Alice\n2,Bob\n"},
+		{"readme.txt", "not csv"},
+		{"sub/b.csv.gz", gzipString(t, "id,name\n3,Carol\n")},
+	}
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "in.zip")
+	createZip(t, zipPath, entries...)
+	tgzPath := filepath.Join(dir, "in.tgz")
+	createTarGz(t, tgzPath, entries...)
+
+	for _, path := range []string{zipPath, tgzPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			items, err := readArchive(t, NewArchiveReader(&ArchiveReaderConfig{
+				Path:    path,
+				Pattern: "*.csv*",
+				Options: ReaderOptions{
+					ReaderConfig: ReaderConfig{HasHeader: true, ChunkSize: 1},
+				},
+			}))
+			assert.NoError(t, err)
+			assert.Equal(t, []middleware.MapMapperType{
+				{"id": "1", "name": "Alice", MetaEntryKey: "a.csv"},
+				{"id": "2", "name": "Bob", MetaEntryKey: "a.csv"},
+				{"id": "3", "name": "Carol", MetaEntryKey: "sub/b.csv.gz"},
+			}, items)
+		})
+	}
+
+	t.Run("Pattern with directory", func(t *testing.T) {
+		items, err := readArchive(t, NewArchiveReader(&ArchiveReaderConfig{
+			Path:    zipPath,
+			Pattern: "sub/*",
+			Options: ReaderOptions{ReaderConfig: ReaderConfig{HasHeader: true}},
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"id": "3", "name": "Carol", MetaEntryKey: "sub/b.csv.gz"},
+		}, items)
+	})
+
+	t.Run("Error in entry", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.zip")
+		createZip(t, path, archiveEntry{"a.csv", "id,name\n1,Alice,x\n"})
+		_, err := readArchive(t, NewArchiveReader(&ArchiveReaderConfig{
+			Path:    path,
+			Options: ReaderOptions{ReaderConfig: ReaderConfig{HasHeader: true}},
+		}))
+		assert.EqualError(t, err, "record on line 2: wrong number of fields in a.csv")
+	})
+
+	t.Run("Rejected records", func(t *testing.T) {
+		path := filepath.Join(dir, "reject.zip")
+		createZip(t, path, archiveEntry{"a.csv", "id,name\n1,Alice,x\n2,Bob\n"})
+		var rejects []*middleware.Reject
+		items, err := readArchive(t, NewArchiveReader(&ArchiveReaderConfig{
+			Path: path,
+			Options: ReaderOptions{ReaderConfig: ReaderConfig{
+				HasHeader: true,
+				RejectWriter: middleware.RejectWriterFunc(func(ctx context.Context, reject *middleware.Reject) error {
+					rejects = append(rejects, reject)
+					return nil
+				}),
+			}},
+		}))
+		assert.NoError(t, err)
+		assert.Len(t, items, 1)
+		if assert.Len(t, rejects, 1) {
+			assert.Equal(t, path+"!a.csv", rejects[0].Source)
+			assert.Equal(t, "1,Alice,x", rejects[0].Raw)
+		}
+	})
+
+	t.Run("Chunk contexts", func(t *testing.T) {
+		var indexes []uint64
+		ctx := middleware.WithChunkContext(context.TODO(), func(index uint64) context.Context {
+			indexes = append(indexes, index)
+			return context.TODO()
+		})
+		reader := NewArchiveReader(&ArchiveReaderConfig{
+			Path:    zipPath,
+			Pattern: "*.csv*",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, ChunkSize: 1},
+			},
+		})
+
+		ch := make(chan interface{})
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- reader.Read(ctx, ch)
+		}()
+		var chunks int
+		for range ch {
+			chunks++
+		}
+		assert.NoError(t, <-errCh)
+		assert.Len(t, indexes, chunks)
+		for i, index := range indexes {
+			assert.Equal(t, uint64(i), index)
+		}
+	})
+
+	t.Run("Not supported archive", func(t *testing.T) {
+		_, err := readArchive(t, NewArchiveReader(&ArchiveReaderConfig{Path: "in.rar"}))
+		assert.EqualError(t, err, "Not supported archive: in.rar")
+	})
+}
+
+func TestArchiveWriter(t *testing.T) {
+	for _, name := range []string{"out.zip", "out.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, name)
+			writer := NewArchiveWriter(&ArchiveWriterConfig{
+				Path: path,
+				RollingWriterConfig: RollingWriterConfig{
+					Template:          "sales_{{.Key}}.csv",
+					PartitionKey:      "region",
+					PropsBindPosition: rollingProps,
+				},
+			})
+
+			ch := make(chan interface{}, 2)
+			ch <- []middleware.MapMapperType{
+				{"id": "1", "region": "east"},
+				{"id": "2", "region": "east"},
+			}
+			ch <- []middleware.MapMapperType{
+				{"id": "3", "region": "west"},
+			}
+			close(ch)
+			assert.NoError(t, writer.Write(context.TODO(), ch))
+
+			manifest := writer.Manifest()
+			if assert.Len(t, manifest, 2) {
+				assert.Equal(t, "sales_east.csv", manifest[0].Path)
+				assert.Equal(t, uint64(2), manifest[0].Rows)
+				assert.Equal(t, "sales_west.csv", manifest[1].Path)
+			}
+
+			items, err := readArchive(t, NewArchiveReader(&ArchiveReaderConfig{
+				Path:    path,
+				Options: ReaderOptions{ReaderConfig: ReaderConfig{HasHeader: true}},
+			}))
+			assert.NoError(t, err)
+			assert.Equal(t, []middleware.MapMapperType{
+				{"id": "1", "region": "east", MetaEntryKey: "sales_east.csv"},
+				{"id": "2", "region": "east", MetaEntryKey: "sales_east.csv"},
+				{"id": "3", "region": "west", MetaEntryKey: "sales_west.csv"},
+			}, items)
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
+func TestArchiveWriterManifest(t *testing.T) {
+	write := func(t *testing.T, stepErr error) (string, string) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.zip")
+		manifestPath := filepath.Join(dir, "manifest.json")
+		writer := NewArchiveWriter(&ArchiveWriterConfig{
+			Path: path,
+			RollingWriterConfig: RollingWriterConfig{
+				Template:          "sales.csv",
+				PropsBindPosition: rollingProps,
+				ManifestPath:      manifestPath,
+			},
+		})
+
+		se := middleware.NewStepExecution(nil, "WriteStep")
+		ctx := middleware.WithStepExecution(context.TODO(), se)
+		ch := make(chan interface{}, 1)
+		ch <- []middleware.MapMapperType{{"id": "1", "region": "east"}}
+		close(ch)
+		assert.NoError(t, writer.Write(ctx, ch))
+
+		// The manifest is written when the step ends.
+		assert.NoFileExists(t, manifestPath)
+		se.Finish(stepErr)
+		return path, manifestPath
+	}
+
+	t.Run("Step completed", func(t *testing.T) {
+		path, manifestPath := write(t, nil)
+		assert.FileExists(t, path)
+		b, err := os.ReadFile(manifestPath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"path": "sales.csv"`)
+	})
+
+	t.Run("Step failed", func(t *testing.T) {
+		path, manifestPath := write(t, fmt.Errorf("Reader Error"))
+		assert.NoFileExists(t, path)
+		assert.NoFileExists(t, manifestPath)
+	})
+}
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path/filepath"
+)
+
+// Suffixes of the sidecar files written by AtomicFile.Commit.
+const (
+	DoneMarkerSuffix = ".done"
+	ChecksumSuffix   = ".sha256"
+)
+
+// AtomicOptions is the options of CreateAtomic.
+type AtomicOptions struct {
+	// If DoneMarker is true, an empty file with DoneMarkerSuffix
+	// is written after the rename.
+	DoneMarker bool
+
+	// If Checksum is true, the SHA-256 checksum of the file is written
+	// to the file with ChecksumSuffix after the rename
+	// in the format of sha256sum.
+	Checksum bool
+}
+
+// AtomicFile writes to a temp file in the directory of the final path
+// and renames it to the final path on Commit,
+// so that readers never see a partially written file.
+type AtomicFile struct {
+	path string
+	opts AtomicOptions
+	temp *os.File
+	hash hash.Hash
+
+	closed bool
+	done   bool
+}
+
+// CreateAtomic creates the temp file for path.
+func CreateAtomic(path string, opts *AtomicOptions) (*AtomicFile, error) {
+	if opts == nil {
+		opts = new(AtomicOptions)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{
+		path: path,
+		opts: *opts,
+		temp: temp,
+		hash: sha256.New(),
+	}, nil
+}
+
+// Name returns the final path.
+func (f *AtomicFile) Name() string {
+	return f.path
+}
+
+// TempName returns the path of the temp file.
+func (f *AtomicFile) TempName() string {
+	return f.temp.Name()
+}
+
+func (f *AtomicFile) Write(p []byte) (int, error) {
+	n, err := f.temp.Write(p)
+	f.hash.Write(p[:n])
+	return n, err
+}
+
+// Close fsyncs and closes the temp file without renaming it.
+// It is called by Commit and Abort and is safe to call more than once.
+func (f *AtomicFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	err := f.temp.Sync()
+	if errClose := f.temp.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// Commit renames the temp file to the final path
+// and writes the sidecar files.
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	if err := f.Close(); err != nil {
+		os.Remove(f.temp.Name())
+		return err
+	}
+	// The temp file is created with the permission 0600.
+	if err := os.Chmod(f.temp.Name(), 0644); err != nil {
+		os.Remove(f.temp.Name())
+		return err
+	}
+	if err := os.Rename(f.temp.Name(), f.path); err != nil {
+		os.Remove(f.temp.Name())
+		return err
+	}
+	syncDir(filepath.Dir(f.path))
+
+	if f.opts.Checksum {
+		sum := hex.EncodeToString(f.hash.Sum(nil)) + "  " + filepath.Base(f.path) + "\n"
+		if err := os.WriteFile(f.path+ChecksumSuffix, []byte(sum), 0644); err != nil {
+			return err
+		}
+	}
+	if f.opts.DoneMarker {
+		if err := os.WriteFile(f.path+DoneMarkerSuffix, nil, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Abort closes and deletes the temp file.
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.Close()
+	return os.Remove(f.temp.Name())
+}
+
+// syncDir fsyncs the directory so that the rename is durable.
+// Errors are ignored because some platforms do not support it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,55 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	t.Run("Commit with sidecars", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		f, err := CreateAtomic(path, &AtomicOptions{DoneMarker: true, Checksum: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, filepath.Dir(path), filepath.Dir(f.TempName()))
+
+		_, err = f.Write([]byte("id\n1\n"))
+		assert.NoError(t, err)
+		assert.NoFileExists(t, path)
+
+		assert.NoError(t, f.Commit())
+		assert.NoFileExists(t, f.TempName())
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "id\n1\n", string(b))
+		assert.FileExists(t, path+DoneMarkerSuffix)
+
+		sum := sha256.Sum256(b)
+		checksum, err := os.ReadFile(path + ChecksumSuffix)
+		assert.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(sum[:])+"  out.csv\n", string(checksum))
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		f, err := CreateAtomic(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write([]byte("id\n"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, f.Abort())
+		assert.NoFileExists(t, f.TempName())
+		assert.NoFileExists(t, path)
+		assert.NoFileExists(t, path+DoneMarkerSuffix)
+		// Commit after Abort does nothing.
+		assert.NoError(t, f.Commit())
+		assert.NoFileExists(t, path)
+	})
+}
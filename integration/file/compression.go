@@ -0,0 +1,206 @@
+package file
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Compression is the compression format of files.
+type Compression int
+
+const (
+	// CompressionAuto detects the format from the file extension,
+	// and also from the magic bytes of gzip and bzip2 at reading.
+	// zlib is detected only from the extension,
+	// since its 2-byte header is found at the head of text, e.g. "x^".
+	CompressionAuto Compression = iota
+	CompressionNone
+	CompressionGzip
+
+	// CompressionBzip2 is supported only at reading.
+	CompressionBzip2
+	CompressionZlib
+)
+
+var compressionNames = map[Compression]string{
+	CompressionAuto:  "auto",
+	CompressionNone:  "none",
+	CompressionGzip:  "gzip",
+	CompressionBzip2: "bzip2",
+	CompressionZlib:  "zlib",
+}
+
+func (c Compression) String() string {
+	return compressionNames[c]
+}
+
+// ErrNotSupportedCompression is returned when writing in bzip2,
+// which the standard library can only decompress.
+var ErrNotSupportedCompression = errors.New("Not supported compression for writing")
+
+// CompressionFromExt returns the compression format of the file extension.
+func CompressionFromExt(path string) Compression {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".gzip":
+		return CompressionGzip
+	case ".bz2", ".bzip2":
+		return CompressionBzip2
+	case ".zz", ".zlib":
+		return CompressionZlib
+	}
+	return CompressionNone
+}
+
+// detectCompression returns the compression format of the magic bytes.
+// zlib is not detected, since its header is not distinguishable from text.
+func detectCompression(r *bufio.Reader) Compression {
+	magic, _ := r.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		return CompressionGzip
+	case len(magic) >= 4 && string(magic[:3]) == "BZh" && magic[3] >= '1' && magic[3] <= '9':
+		return CompressionBzip2
+	}
+	return CompressionNone
+}
+
+// Open opens the file of path and returns the reader decompressing it.
+// Closing the returned reader closes the file.
+func Open(path string, c Compression) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := NewDecompressReader(f, c, path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+// NewDecompressReader returns the reader decompressing r.
+// If c is CompressionAuto, the format is detected from the extension
+// of name and the magic bytes of r.
+// Closing the returned reader closes r if it is io.Closer.
+func NewDecompressReader(r io.Reader, c Compression, name string) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if c == CompressionAuto {
+		if c = CompressionFromExt(name); c == CompressionNone {
+			c = detectCompression(br)
+		}
+	}
+
+	var cs closers
+	if closer, ok := r.(io.Closer); ok {
+		cs = append(cs, closer)
+	}
+	var reader io.Reader
+	switch c {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		reader = gr
+		cs = append(closers{gr}, cs...)
+	case CompressionBzip2:
+		reader = bzip2.NewReader(br)
+	case CompressionZlib:
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		reader = zr
+		cs = append(closers{zr}, cs...)
+	default:
+		reader = br
+	}
+	return &readCloser{Reader: reader, closers: cs, compression: c}, nil
+}
+
+// CompressionOf returns the compression format of the reader
+// returned by Open or NewDecompressReader.
+// It returns CompressionNone for the other readers.
+func CompressionOf(r io.Reader) Compression {
+	if rc, ok := r.(*readCloser); ok {
+		return rc.compression
+	}
+	return CompressionNone
+}
+
+// Create creates the file of path and returns the writer compressing to it.
+// If c is CompressionAuto, the format is detected from the extension of path.
+// Closing the returned writer flushes the compressor and closes the file.
+func Create(path string, c Compression) (io.WriteCloser, error) {
+	if c == CompressionAuto {
+		c = CompressionFromExt(path)
+	}
+	if c == CompressionBzip2 {
+		return nil, ErrNotSupportedCompression
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCompressWriter(f, c)
+}
+
+// NewCompressWriter returns the writer compressing to w.
+// CompressionAuto is regarded as CompressionNone.
+// Closing the returned writer closes w if it is io.Closer.
+func NewCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	var cs closers
+	if closer, ok := w.(io.Closer); ok {
+		cs = append(cs, closer)
+	}
+
+	var writer io.Writer
+	switch c {
+	case CompressionGzip:
+		gw := gzip.NewWriter(w)
+		writer = gw
+		cs = append(closers{gw}, cs...)
+	case CompressionZlib:
+		zw := zlib.NewWriter(w)
+		writer = zw
+		cs = append(closers{zw}, cs...)
+	case CompressionBzip2:
+		return nil, ErrNotSupportedCompression
+	default:
+		writer = w
+	}
+	return &writeCloser{Writer: writer, closers: cs}, nil
+}
+
+// closers closes all in order and returns the first error.
+type closers []io.Closer
+
+func (cs closers) Close() error {
+	var err error
+	for _, c := range cs {
+		if errClose := c.Close(); err == nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+type readCloser struct {
+	io.Reader
+	closers
+	compression Compression
+}
+
+type writeCloser struct {
+	io.Writer
+	closers
+}
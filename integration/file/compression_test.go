@@ -0,0 +1,77 @@
+package file
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("id,name\n0,John\n")
+
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZlib} {
+		t.Run(c.String(), func(t *testing.T) {
+			path := filepath.Join(dir, "out_"+c.String())
+			if c == CompressionZlib {
+				// zlib is detected only from the extension.
+				path += ".zz"
+			}
+			w, err := Create(path, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, c == CompressionNone, bytes.Equal(data, raw))
+
+			// The format is detected from the magic bytes or the extension.
+			r, err := Open(path, CompressionAuto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			assert.Equal(t, c, CompressionOf(r))
+			got, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, data, got)
+		})
+	}
+}
+
+func TestCompressionFromExt(t *testing.T) {
+	assert.Equal(t, CompressionGzip, CompressionFromExt("sales.csv.gz"))
+	assert.Equal(t, CompressionBzip2, CompressionFromExt("sales.csv.BZ2"))
+	assert.Equal(t, CompressionZlib, CompressionFromExt("sales.csv.zz"))
+	assert.Equal(t, CompressionNone, CompressionFromExt("sales.csv"))
+}
+
+func TestDetectCompression(t *testing.T) {
+	for _, text := range []string{"x^2,y\n", "x\x9c", "x\xda"} {
+		r, err := NewDecompressReader(bytes.NewReader([]byte(text)), CompressionAuto, "input.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, CompressionNone, CompressionOf(r))
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, text, string(got))
+	}
+}
+
+func TestCreateBzip2(t *testing.T) {
+	_, err := Create(filepath.Join(t.TempDir(), "out.csv.bz2"), CompressionAuto)
+	assert.ErrorIs(t, err, ErrNotSupportedCompression)
+}
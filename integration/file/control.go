@@ -0,0 +1,148 @@
+package file
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// decimalPattern is the format of the decimals summed,
+// which excludes the fractions and the other bases accepted by big.Rat.
+var decimalPattern = regexp.MustCompile(`^[+-]?\d+(\.\d+)?$`)
+
+// ControlTotals are the totals of the detail records of a file
+// declared by its trailer.
+type ControlTotals struct {
+	// Count is the number of the detail records.
+	// If it is negative, it is not checked.
+	Count int64
+
+	// Sums are the sums of the columns in decimal, e.g. "12345.67".
+	// Key of map is the column name, which must be in SumColumns.
+	Sums map[string]string
+}
+
+// controlSums are the exact sums of the columns.
+type controlSums struct {
+	columns []string
+	sums    map[string]*decimalSum
+}
+
+func newControlSums(columns []string) *controlSums {
+	c := &controlSums{
+		columns: columns,
+		sums:    make(map[string]*decimalSum, len(columns)),
+	}
+	for _, col := range columns {
+		c.sums[col] = new(decimalSum)
+	}
+	return c
+}
+
+// add adds the values of the columns in order.
+// Nothing is added if any value is invalid.
+// Arg line is the line number used in the error messages.
+func (c *controlSums) add(values []string, line int) error {
+	rats := make([]*big.Rat, len(c.columns))
+	for i, col := range c.columns {
+		r, err := parseDecimal(values[i])
+		if err != nil {
+			return fmt.Errorf("Invalid number of %s at line %d: %q", col, line, values[i])
+		}
+		rats[i] = r
+	}
+	for i, col := range c.columns {
+		c.sums[col].add(rats[i], values[i])
+	}
+	return nil
+}
+
+// totals returns the totals with count.
+func (c *controlSums) totals(count int64) *ControlTotals {
+	totals := &ControlTotals{
+		Count: count,
+		Sums:  make(map[string]string, len(c.columns)),
+	}
+	for _, col := range c.columns {
+		totals.Sums[col] = c.sums[col].String()
+	}
+	return totals
+}
+
+// check compares the totals declared by the trailer with the actual count.
+func (c *controlSums) check(declared *ControlTotals, count int64) error {
+	if declared.Count >= 0 && declared.Count != count {
+		return fmt.Errorf("Mismatched record count: %d, expected %d by trailer", count, declared.Count)
+	}
+
+	columns := make([]string, 0, len(declared.Sums))
+	for col := range declared.Sums {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	for _, col := range columns {
+		sum, ok := c.sums[col]
+		if !ok {
+			return fmt.Errorf("Not found %s in SumColumns", col)
+		}
+		want, ok := decimalRat(strings.TrimSpace(declared.Sums[col]))
+		if !ok {
+			return fmt.Errorf("Invalid sum of %s in trailer: %q", col, declared.Sums[col])
+		}
+		if sum.rat().Cmp(want) != 0 {
+			return fmt.Errorf("Mismatched sum of %s: %s, expected %s by trailer",
+				col, sum.String(), declared.Sums[col])
+		}
+	}
+	return nil
+}
+
+// decimalSum is the exact sum of decimals
+// formatted with the largest number of decimal places added.
+type decimalSum struct {
+	sum   big.Rat
+	scale int
+}
+
+// parseDecimal parses value as a decimal.
+// The empty value is regarded as 0.
+func parseDecimal(value string) (*big.Rat, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return new(big.Rat), nil
+	}
+	r, ok := decimalRat(value)
+	if !ok {
+		return nil, fmt.Errorf("Invalid number: %q", value)
+	}
+	return r, nil
+}
+
+// decimalRat parses value matching decimalPattern.
+func decimalRat(value string) (*big.Rat, bool) {
+	if !decimalPattern.MatchString(value) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(value)
+}
+
+// add adds r parsed from value.
+func (d *decimalSum) add(r *big.Rat, value string) {
+	d.sum.Add(&d.sum, r)
+	value = strings.TrimSpace(value)
+	if idx := strings.IndexByte(value, '.'); idx >= 0 {
+		if scale := len(value) - idx - 1; scale > d.scale {
+			d.scale = scale
+		}
+	}
+}
+
+func (d *decimalSum) rat() *big.Rat {
+	return &d.sum
+}
+
+func (d *decimalSum) String() string {
+	return d.sum.FloatString(d.scale)
+}
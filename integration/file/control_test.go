@@ -0,0 +1,198 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const controlInput = `H,20261018,BATCH01
+id,amount
+1,100.50
+2,200.25
+3,0.25
+T,3,301.00
+`
+
+func readControl(ctx context.Context, input string, chunkSize uint,
+	trailerFunc func(ctx context.Context, record []string) (*ControlTotals, error)) ([]middleware.MapMapperType, error) {
+
+	csvReader := csv.NewReader(strings.NewReader(input))
+	csvReader.FieldsPerRecord = -1
+	reader := NewReader(&ReaderConfig{
+		Reader:    csvReader,
+		HasHeader: true,
+		ChunkSize: chunkSize,
+		HeaderFunc: func(ctx context.Context, record []string) error {
+			if record[0] != "H" {
+				return errors.New("Invalid header record")
+			}
+			execCtx := middleware.ExecutionContextFromContext(ctx)
+			execCtx.Put("file_date", record[1])
+			execCtx.Put("batch_id", record[2])
+			return nil
+		},
+		TrailerFunc: trailerFunc,
+		SumColumns:  []string{"amount"},
+	})
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(ctx, ch)
+	}()
+	var items []middleware.MapMapperType
+	for chunk := range ch {
+		items = append(items, chunk.([]middleware.MapMapperType)...)
+	}
+	return items, <-errCh
+}
+
+func parseTrailer(ctx context.Context, record []string) (*ControlTotals, error) {
+	count, err := strconv.ParseInt(record[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &ControlTotals{
+		Count: count,
+		Sums:  map[string]string{"amount": record[2]},
+	}, nil
+}
+
+func TestReaderWithControlTotals(t *testing.T) {
+	for _, chunkSize := range []uint{0, 1, 2, 3} {
+		t.Run("ChunkSize "+strconv.Itoa(int(chunkSize)), func(t *testing.T) {
+			se := middleware.NewStepExecution(nil, "ReadStep")
+			ctx := middleware.WithStepExecution(context.TODO(), se)
+
+			items, err := readControl(ctx, controlInput, chunkSize, parseTrailer)
+			assert.NoError(t, err)
+			assert.Equal(t, []middleware.MapMapperType{
+				{"id": "1", "amount": "100.50"},
+				{"id": "2", "amount": "200.25"},
+				{"id": "3", "amount": "0.25"},
+			}, items)
+			assert.Equal(t, "BATCH01", se.ExecutionContext().GetString("batch_id"))
+			assert.Equal(t, map[string]interface{}{
+				"file_date": "20261018",
+				"batch_id":  "BATCH01",
+			}, se.Record().Context)
+		})
+	}
+
+	t.Run("Mismatched count", func(t *testing.T) {
+		input := strings.Replace(controlInput, "T,3,", "T,4,", 1)
+		_, err := readControl(context.TODO(), input, 2, parseTrailer)
+		assert.EqualError(t, err, "Mismatched record count: 3, expected 4 by trailer")
+	})
+
+	t.Run("Mismatched sum", func(t *testing.T) {
+		input := strings.Replace(controlInput, "301.00", "301.01", 1)
+		_, err := readControl(context.TODO(), input, 0, parseTrailer)
+		assert.EqualError(t, err, "Mismatched sum of amount: 301.00, expected 301.01 by trailer")
+	})
+
+	t.Run("Invalid number", func(t *testing.T) {
+		input := strings.Replace(controlInput, "200.25", "2OO.25", 1)
+		_, err := readControl(context.TODO(), input, 1, parseTrailer)
+		assert.EqualError(t, err, `Invalid number of amount at line 4: "2OO.25"`)
+
+		// The fractions and the other bases are not decimals.
+		for _, value := range []string{"3/4", "0x10", "1_000", "2026/10", "1e3", ".5"} {
+			input := strings.Replace(controlInput, "200.25", value, 1)
+			_, err := readControl(context.TODO(), input, 1, parseTrailer)
+			assert.EqualError(t, err, fmt.Sprintf("Invalid number of amount at line 4: %q", value))
+		}
+	})
+
+	t.Run("Invalid sum in trailer", func(t *testing.T) {
+		input := strings.Replace(controlInput, "301.00", "602/2", 1)
+		_, err := readControl(context.TODO(), input, 1, parseTrailer)
+		assert.EqualError(t, err, `Invalid sum of amount in trailer: "602/2"`)
+	})
+
+	t.Run("Not checked", func(t *testing.T) {
+		items, err := readControl(context.TODO(), controlInput, 1,
+			func(ctx context.Context, record []string) (*ControlTotals, error) {
+				return nil, nil
+			})
+		assert.NoError(t, err)
+		assert.Len(t, items, 3)
+	})
+
+	t.Run("Not found trailer", func(t *testing.T) {
+		_, err := readControl(context.TODO(), "H,20261018,BATCH01\nid,amount\n", 1, parseTrailer)
+		assert.EqualError(t, err, "Not found trailer record")
+	})
+}
+
+func TestWriterWithControlTotals(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&WriterConfig{
+		Writer:            csv.NewWriter(&buf),
+		PropsBindPosition: middleware.PropsBindPosition{"id": 0, "amount": 1},
+		HeaderFunc: func(ctx context.Context) ([]string, error) {
+			return []string{"H", "20261018", "BATCH01"}, nil
+		},
+		TrailerFunc: func(ctx context.Context, totals *ControlTotals) ([]string, error) {
+			return []string{"T", strconv.FormatInt(totals.Count, 10), totals.Sums["amount"]}, nil
+		},
+		SumColumns: []string{"amount"},
+	})
+
+	ch := make(chan interface{}, 2)
+	ch <- []middleware.MapMapperType{
+		{"id": "1", "amount": "100.50"},
+		{"id": "2", "amount": "200.25"},
+	}
+	ch <- []middleware.MapMapperType{
+		{"id": "3", "amount": "0.25"},
+	}
+	close(ch)
+
+	assert.NoError(t, writer.Write(context.TODO(), ch))
+	assert.Equal(t, controlInput, buf.String())
+}
+
+func TestWriterWithControlTotalsMissingColumn(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&WriterConfig{
+		Writer:            csv.NewWriter(&buf),
+		PropsBindPosition: middleware.PropsBindPosition{"id": 0, "amount": 1, "memo": 2},
+		NoHeader:          true,
+		TrailerFunc: func(ctx context.Context, totals *ControlTotals) ([]string, error) {
+			return []string{"T", strconv.FormatInt(totals.Count, 10), totals.Sums["amount"]}, nil
+		},
+		SumColumns: []string{"amount"},
+	})
+
+	ch := make(chan interface{}, 1)
+	// The amount missing is regarded as 0.
+	ch <- []middleware.MapMapperType{
+		{"id": "1", "memo": "first"},
+		{"id": "2", "amount": "200.25"},
+	}
+	close(ch)
+
+	assert.NoError(t, writer.Write(context.TODO(), ch))
+	assert.True(t, strings.HasSuffix(buf.String(), "\nT,2,200.25\n"), buf.String())
+}
+
+func TestWriterWithUnknownSumColumn(t *testing.T) {
+	writer := NewWriter(&WriterConfig{
+		Writer:            csv.NewWriter(new(bytes.Buffer)),
+		PropsBindPosition: middleware.PropsBindPosition{"id": 0},
+		SumColumns:        []string{"amount"},
+	})
+	ch := make(chan interface{})
+	close(ch)
+
+	assert.EqualError(t, writer.Write(context.TODO(), ch), "Not found amount in PropsBindPosition")
+}
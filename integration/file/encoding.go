@@ -0,0 +1,79 @@
+package file
+
+import (
+	"fmt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io"
+	"strings"
+)
+
+// bom is the byte order mark.
+const bom = "\uFEFF"
+
+// LookupEncoding returns the character encoding of name,
+// e.g. "Shift_JIS", "windows-1252" and "UTF-8".
+// The names are the labels of the WHATWG Encoding Standard.
+// It returns nil for the empty name, which means UTF-8 as it is.
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("Not supported encoding: %s", name)
+	}
+	return enc, nil
+}
+
+// isUTF8 reports whether the encoding of name is UTF-8
+// so that the bytes are not transformed.
+func isUTF8(name string) bool {
+	return name == "" || strings.EqualFold(strings.ReplaceAll(name, "-", ""), "utf8")
+}
+
+// NewDecodeReader returns the reader decoding r from the encoding of name to UTF-8.
+// The BOM of UTF-8 or UTF-16 at the start of r is stripped,
+// and it overrides the encoding of name.
+func NewDecodeReader(r io.Reader, name string) (io.Reader, error) {
+	enc, err := LookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	var fallback transform.Transformer = transform.Nop
+	if enc != nil && !isUTF8(name) {
+		fallback = enc.NewDecoder()
+	}
+	return transform.NewReader(r, unicode.BOMOverride(fallback)), nil
+}
+
+// NewEncodeWriter returns the writer encoding UTF-8 to the encoding of name.
+// If withBOM is true, the BOM is written firstly.
+// The runes not supported by the encoding cause an error.
+// Closing the returned writer flushes the encoder and closes w if it is io.Closer.
+func NewEncodeWriter(w io.Writer, name string, withBOM bool) (io.WriteCloser, error) {
+	enc, err := LookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var cs closers
+	var writer io.Writer = w
+	if enc != nil && !isUTF8(name) {
+		tw := transform.NewWriter(w, enc.NewEncoder())
+		writer = tw
+		cs = append(cs, tw)
+	}
+	if closer, ok := w.(io.Closer); ok {
+		cs = append(cs, closer)
+	}
+
+	if withBOM {
+		if _, err := io.WriteString(writer, bom); err != nil {
+			return nil, fmt.Errorf("Not supported BOM in encoding %s: %w", name, err)
+		}
+	}
+	return &writeCloser{Writer: writer, closers: cs}, nil
+}
@@ -0,0 +1,219 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewDecodeReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    string
+		want     string
+	}{
+		{"Shift_JIS", "Shift_JIS", "\x96\xbc\x91\x4f\n", "名前\n"},
+		{"windows-1252", "windows-1252", "caf\xe9\n", "café\n"},
+		{"UTF-8 with BOM", "", "\xef\xbb\xbfid,name\n", "id,name\n"},
+		{"BOM overrides encoding", "Shift_JIS", "\xef\xbb\xbf名前\n", "名前\n"},
+		{"UTF-16LE with BOM", "", "\xff\xfei\x00d\x00\n\x00", "id\n"},
+		{"UTF-8", "utf-8", "名前\n", "名前\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewDecodeReader(bytes.NewBufferString(tt.input), tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(b))
+		})
+	}
+
+	_, err := NewDecodeReader(new(bytes.Buffer), "EBCDIC-XX")
+	assert.EqualError(t, err, "Not supported encoding: EBCDIC-XX")
+}
+
+func TestNewEncodeWriter(t *testing.T) {
+	t.Run("Shift_JIS", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewEncodeWriter(&buf, "Shift_JIS", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(w, "名前\n")
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		assert.Equal(t, "\x96\xbc\x91\x4f\n", buf.String())
+	})
+
+	t.Run("UTF-8 with BOM", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewEncodeWriter(&buf, "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(w, "id\n")
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		assert.Equal(t, "\xef\xbb\xbfid\n", buf.String())
+	})
+
+	t.Run("Not supported rune", func(t *testing.T) {
+		w, err := NewEncodeWriter(new(bytes.Buffer), "windows-1252", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(w, "名前\n")
+		if err == nil {
+			err = w.Close()
+		}
+		assert.Error(t, err)
+	})
+}
+
+func TestReaderTrimsBOM(t *testing.T) {
+	read := func(conf *ReaderConfig) []middleware.MapMapperType {
+		ch := make(chan interface{})
+		go func() {
+			if err := NewReader(conf).Read(context.TODO(), ch); err != nil {
+				t.Error(err)
+			}
+		}()
+		var items []middleware.MapMapperType
+		for chunk := range ch {
+			items = append(items, chunk.([]middleware.MapMapperType)...)
+		}
+		return items
+	}
+
+	t.Run("Header", func(t *testing.T) {
+		items := read(&ReaderConfig{
+			Reader:    csv.NewReader(bytes.NewBufferString("\xef\xbb\xbfid,name\n1,Alice\n")),
+			HasHeader: true,
+		})
+		assert.Equal(t, []middleware.MapMapperType{{"id": "1", "name": "Alice"}}, items)
+	})
+
+	t.Run("No header", func(t *testing.T) {
+		items := read(&ReaderConfig{
+			Reader: csv.NewReader(bytes.NewBufferString("\xef\xbb\xbf1,Alice\n2,Bob\n")),
+		})
+		assert.Equal(t, []middleware.MapMapperType{
+			{"0": "1", "1": "Alice"},
+			{"0": "2", "1": "Bob"},
+		}, items)
+	})
+
+	t.Run("No header by chunk", func(t *testing.T) {
+		items := read(&ReaderConfig{
+			Reader:    csv.NewReader(bytes.NewBufferString("\xef\xbb\xbf1,Alice\n2,Bob\n")),
+			ChunkSize: 1,
+		})
+		assert.Equal(t, []middleware.MapMapperType{
+			{"0": "1", "1": "Alice"},
+			{"0": "2", "1": "Bob"},
+		}, items)
+	})
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.csv")
+	writer, err := CreateWriter(path, &WriterOptions{
+		WriterConfig: WriterConfig{
+			PropsBindPosition: middleware.PropsBindPosition{"id": 0, "名前": 1},
+		},
+		Encoding: "Shift_JIS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan interface{}, 1)
+	ch <- []middleware.MapMapperType{{"id": "1", "名前": "山田"}}
+	close(ch)
+	if err := writer.Write(context.TODO(), ch); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "id,\x96\xbc\x91\x4f\n1,\x8e\x52\x93\x63\n", string(b))
+
+	reader, err := OpenReader(path, &ReaderOptions{
+		ReaderConfig: ReaderConfig{HasHeader: true},
+		Encoding:     "Shift_JIS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch2 := make(chan interface{})
+	go func() {
+		if err := reader.Read(context.TODO(), ch2); err != nil {
+			t.Error(err)
+		}
+	}()
+	chunk := (<-ch2).([]middleware.MapMapperType)
+	for range ch2 {
+	}
+	assert.Equal(t, []middleware.MapMapperType{{"id": "1", "名前": "山田"}}, chunk)
+}
+
+func TestCreateWriterWithBOM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "excel.csv")
+	writer, err := CreateWriter(path, &WriterOptions{
+		WriterConfig: WriterConfig{
+			PropsBindPosition: middleware.PropsBindPosition{"id": 0},
+		},
+		BOM: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan interface{}, 1)
+	ch <- []middleware.MapMapperType{{"id": "1"}}
+	close(ch)
+	if err := writer.Write(context.TODO(), ch); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "\xef\xbb\xbfid\n1\n", string(b))
+
+	_, err = CreateWriter(filepath.Join(t.TempDir(), "x.csv"), &WriterOptions{Encoding: "unknown"})
+	assert.EqualError(t, err, "Not supported encoding: unknown")
+}
+
+func TestRollingWriterWithEncoding(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewRollingWriter(&RollingWriterConfig{
+		Template:          filepath.Join(dir, "out_{{.Index}}.csv"),
+		PropsBindPosition: middleware.PropsBindPosition{"name": 0},
+		Encoding:          "windows-1252",
+	})
+	ch := make(chan interface{}, 1)
+	ch <- []middleware.MapMapperType{{"name": "café"}}
+	close(ch)
+	if err := writer.Write(context.TODO(), ch); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "out_1.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "name\ncaf\xe9\n", string(b))
+	assert.Equal(t, int64(10), writer.Manifest()[0].Bytes)
+}
@@ -0,0 +1,143 @@
+package file
+
+import (
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"strings"
+)
+
+// Alignment is the alignment of a value in a fixed-width column.
+type Alignment int
+
+const (
+	// AlignLeft places the value on the left and pads on the right.
+	AlignLeft Alignment = iota
+
+	// AlignRight places the value on the right and pads on the left.
+	AlignRight
+)
+
+// TrimRule is the rule to trim a value read from a fixed-width column.
+type TrimRule int
+
+const (
+	// TrimPadding trims the padding character on the padded side.
+	// The value of only '0' padding is read as "0".
+	TrimPadding TrimRule = iota
+
+	// TrimSpace trims white spaces on both sides.
+	TrimSpace
+
+	// TrimNone keeps the value as it is.
+	TrimNone
+)
+
+// FixedWidthColumn is the layout of a column of fixed-width records.
+// Start and Length are in bytes.
+type FixedWidthColumn struct {
+	// Name is the key of MapMapperType.
+	Name string
+
+	// Start is the position of the column starting from 0.
+	Start int
+
+	// Length is the width of the column.
+	Length int
+
+	Align Alignment
+
+	// Padding is the character filling the rest of the column.
+	// If specify 0, a space is used.
+	Padding byte
+
+	// Trim is the rule to trim the value at reading.
+	Trim TrimRule
+}
+
+// FixedWidthRecord is the layout of a record type.
+type FixedWidthRecord struct {
+	// Prefix is the record type at the beginning of the line.
+	// Reader identifies the record type by it and Writer outputs it
+	// at the beginning of the line.
+	Prefix string
+
+	Columns []FixedWidthColumn
+}
+
+func (c *FixedWidthColumn) padding() byte {
+	if c.Padding == 0 {
+		return ' '
+	}
+	return c.Padding
+}
+
+// match reports whether line is the record type.
+func (r *FixedWidthRecord) match(line string) bool {
+	return r.Prefix != "" && strings.HasPrefix(line, r.Prefix)
+}
+
+// parse slices line into the columns.
+// A line shorter than the layout is read as if it was padded.
+func (r *FixedWidthRecord) parse(line string) middleware.MapMapperType {
+	resultSet := make(middleware.MapMapperType, len(r.Columns))
+	for _, col := range r.Columns {
+		var val string
+		if col.Start < len(line) {
+			end := col.Start + col.Length
+			if end > len(line) {
+				end = len(line)
+			}
+			val = line[col.Start:end]
+		}
+
+		switch col.Trim {
+		case TrimPadding:
+			pad := string(col.padding())
+			trimmed := strings.TrimLeft(val, pad)
+			if col.Align != AlignRight {
+				trimmed = strings.TrimRight(val, pad)
+			}
+			// The zero padded number keeps its last digit, e.g. "000" is "0".
+			if trimmed == "" && val != "" && pad == "0" {
+				trimmed = "0"
+			}
+			val = trimmed
+		case TrimSpace:
+			val = strings.TrimSpace(val)
+		}
+		resultSet[col.Name] = val
+	}
+	return resultSet
+}
+
+// format builds the line of the record from resultSet.
+func (r *FixedWidthRecord) format(resultSet middleware.MapMapperType) (string, error) {
+	width := len(r.Prefix)
+	for _, col := range r.Columns {
+		if end := col.Start + col.Length; end > width {
+			width = end
+		}
+	}
+
+	line := []byte(strings.Repeat(" ", width))
+	copy(line, r.Prefix)
+	for _, col := range r.Columns {
+		val := resultSet[col.Name]
+		if len(val) > col.Length {
+			return "", fmt.Errorf("Exceeded the length of column %s: %d > %d",
+				col.Name, len(val), col.Length)
+		}
+
+		field := line[col.Start : col.Start+col.Length]
+		pad := col.padding()
+		for i := range field {
+			field[i] = pad
+		}
+		if col.Align == AlignRight {
+			copy(field[col.Length-len(val):], val)
+		} else {
+			copy(field, val)
+		}
+	}
+	return string(line), nil
+}
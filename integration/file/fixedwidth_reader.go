@@ -0,0 +1,158 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"strings"
+)
+
+var _ middleware.Reader = new(FixedWidthReader)
+
+// FixedWidthReader is an implementation of middleware.Reader.
+// It is used to read fixed-width format files
+// according to the layout of columns.
+type FixedWidthReader struct {
+	conf *FixedWidthReaderConfig
+}
+
+// FixedWidthReaderConfig is the configuration of FixedWidthReader.
+type FixedWidthReaderConfig struct {
+	Reader io.Reader
+
+	// Detail is the layout of the records sent to channel.
+	// If Detail.Prefix is not empty, lines of other record types
+	// cause an error.
+	Detail FixedWidthRecord
+
+	// Header is the layout of the header record.
+	// If Header.Prefix is empty, the 1st line is the header.
+	Header *FixedWidthRecord
+
+	// HeaderFunc is called with the header record.
+	HeaderFunc func(ctx context.Context, header middleware.MapMapperType) error
+
+	// Trailer is the layout of the trailer record.
+	// If Trailer.Prefix is empty, the last non-empty line is the trailer.
+	Trailer *FixedWidthRecord
+
+	// TrailerFunc is called with the trailer record
+	// after all detail records are sent.
+	TrailerFunc func(ctx context.Context, trailer middleware.MapMapperType) error
+
+	// ChunkSize is the number of records to be sent at once.
+	// If specify 0, FixedWidthReader will send all records at once.
+	ChunkSize uint
+
+	// Size is the size of the file in bytes.
+	// If it is greater than 0, FixedWidthReader reports it as the total
+	// of the step so that the completion time can be estimated.
+	Size int64
+
+	// RowMapperFunc is the mapping function.
+	// If it is nil, FixedWidthReader will send data as the type
+	// of MapMapperType to channel.
+	// If not nil, FixedWidthReader will send data as the type that user defined.
+	RowMapperFunc middleware.RowMapper
+}
+
+func NewFixedWidthReader(conf *FixedWidthReaderConfig) *FixedWidthReader {
+	return &FixedWidthReader{
+		conf: conf,
+	}
+}
+
+func (r *FixedWidthReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressTotal(ctx, uint64(r.conf.Size), middleware.ProgressBytes)
+	}
+
+	reader := bufio.NewReader(r.conf.Reader)
+	var offset int64
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	// readNext skips the empty lines, e.g. at the end of the file,
+	// and reports the number of them as skipped.
+	readNext := func() (line string, skipped int, err error) {
+		for {
+			line, err = readLine()
+			if err != nil || line != "" {
+				return line, skipped, err
+			}
+			skipped++
+		}
+	}
+
+	var (
+		chunk   []middleware.MapMapperType
+		trailer middleware.MapMapperType
+		lineNo  int
+	)
+	// The trailer without prefix is identified by reading the next line.
+	lookahead := r.conf.Trailer != nil && r.conf.Trailer.Prefix == ""
+	next, skipped, err := readNext()
+	for err == nil {
+		line := next
+		lineNo += skipped + 1
+		next, skipped, err = readNext()
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		switch {
+		case r.conf.Header != nil && (r.conf.Header.match(line) ||
+			r.conf.Header.Prefix == "" && lineNo == 1):
+			if r.conf.HeaderFunc != nil {
+				if err := r.conf.HeaderFunc(ctx, r.conf.Header.parse(line)); err != nil {
+					return err
+				}
+			}
+		case r.conf.Trailer != nil && (r.conf.Trailer.match(line) ||
+			lookahead && err == io.EOF):
+			trailer = r.conf.Trailer.parse(line)
+		case r.conf.Detail.Prefix != "" && !r.conf.Detail.match(line):
+			return fmt.Errorf("Unknown record type at line %d: %s", lineNo, line)
+		default:
+			chunk = append(chunk, r.conf.Detail.parse(line))
+		}
+
+		if r.conf.ChunkSize > 0 && len(chunk) >= int(r.conf.ChunkSize) {
+			if r.conf.Size > 0 {
+				middleware.SetProgressPosition(ctx, uint64(offset))
+			}
+			if err := sendChunk(ctx, ch, chunk, r.conf.RowMapperFunc); err != nil {
+				return err
+			}
+			chunk = nil
+		}
+	}
+	if err != io.EOF {
+		return err
+	}
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressPosition(ctx, uint64(offset))
+	}
+	if len(chunk) != 0 || r.conf.ChunkSize == 0 {
+		if err := sendChunk(ctx, ch, chunk, r.conf.RowMapperFunc); err != nil {
+			return err
+		}
+	}
+	if trailer != nil && r.conf.TrailerFunc != nil {
+		if err := r.conf.TrailerFunc(ctx, trailer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package file
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"strings"
+	"testing"
+)
+
+var (
+	fixedWidthDetail = FixedWidthRecord{
+		Prefix: "D",
+		Columns: []FixedWidthColumn{
+			{Name: "id", Start: 1, Length: 10, Align: AlignRight, Padding: '0'},
+			{Name: "name", Start: 11, Length: 10},
+			{Name: "amount", Start: 21, Length: 10, Align: AlignRight, Padding: '0'},
+		},
+	}
+	fixedWidthHeader = &FixedWidthRecord{
+		Prefix: "H",
+		Columns: []FixedWidthColumn{
+			{Name: "date", Start: 1, Length: 8},
+			{Name: "sender", Start: 9, Length: 10},
+		},
+	}
+	fixedWidthTrailer = &FixedWidthRecord{
+		Prefix: "T",
+		Columns: []FixedWidthColumn{
+			{Name: "count", Start: 1, Length: 10, Align: AlignRight, Padding: '0'},
+		},
+	}
+)
+
+func readAll(reader middleware.Reader) ([]middleware.MapMapperType, error) {
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+
+	var items []middleware.MapMapperType
+	for chunk := range ch {
+		items = append(items, chunk.([]middleware.MapMapperType)...)
+	}
+	return items, <-errCh
+}
+
+func TestFixedWidthReader(t *testing.T) {
+	t.Run("With header and trailer", func(t *testing.T) {
+		file, err := os.Open("testdata/fixedwidth.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		var header, trailer middleware.MapMapperType
+		reader := NewFixedWidthReader(&FixedWidthReaderConfig{
+			Reader:  file,
+			Detail:  fixedWidthDetail,
+			Header:  fixedWidthHeader,
+			Trailer: fixedWidthTrailer,
+			HeaderFunc: func(ctx context.Context, h middleware.MapMapperType) error {
+				header = h
+				return nil
+			},
+			TrailerFunc: func(ctx context.Context, t middleware.MapMapperType) error {
+				trailer = t
+				return nil
+			},
+			ChunkSize: 1,
+		})
+
+		items, err := readAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"id": "1", "name": "John", "amount": "12345"},
+			{"id": "2", "name": "Andy", "amount": "500"},
+		}, items)
+		assert.Equal(t, middleware.MapMapperType{"date": "20220101", "sender": "BANK"}, header)
+		assert.Equal(t, middleware.MapMapperType{"count": "2"}, trailer)
+	})
+
+	t.Run("Header and trailer without prefix", func(t *testing.T) {
+		var trailer middleware.MapMapperType
+		reader := NewFixedWidthReader(&FixedWidthReaderConfig{
+			Reader: strings.NewReader("HEAD\r\n001 a\r\n002  b\r\nTAIL\r\n\r\n\n"),
+			Detail: FixedWidthRecord{
+				Columns: []FixedWidthColumn{
+					{Name: "id", Start: 0, Length: 3},
+					{Name: "name", Start: 3, Length: 3, Trim: TrimSpace},
+				},
+			},
+			Header: &FixedWidthRecord{},
+			Trailer: &FixedWidthRecord{
+				Columns: []FixedWidthColumn{{Name: "mark", Start: 0, Length: 4}},
+			},
+			TrailerFunc: func(ctx context.Context, t middleware.MapMapperType) error {
+				trailer = t
+				return nil
+			},
+		})
+
+		items, err := readAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"id": "001", "name": "a"},
+			{"id": "002", "name": "b"},
+		}, items)
+		assert.Equal(t, middleware.MapMapperType{"mark": "TAIL"}, trailer)
+	})
+
+	t.Run("Zero padded zero", func(t *testing.T) {
+		reader := NewFixedWidthReader(&FixedWidthReaderConfig{
+			Reader: strings.NewReader("D0000000000Zero      0000000000\n"),
+			Detail: fixedWidthDetail,
+		})
+
+		items, err := readAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"id": "0", "name": "Zero", "amount": "0"},
+		}, items)
+	})
+
+	t.Run("Unknown record type", func(t *testing.T) {
+		reader := NewFixedWidthReader(&FixedWidthReaderConfig{
+			Reader: strings.NewReader("D0000000001John      0000012345\n\nX\n"),
+			Detail: fixedWidthDetail,
+		})
+
+		_, err := readAll(reader)
+		assert.EqualError(t, err, "Unknown record type at line 3: X")
+	})
+}
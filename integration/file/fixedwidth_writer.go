@@ -0,0 +1,136 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"io"
+)
+
+var _ middleware.Writer = new(FixedWidthWriter)
+
+// FixedWidthWriter is an implementation of middleware.Writer.
+// It is used to write fixed-width format files
+// according to the layout of columns.
+type FixedWidthWriter struct {
+	conf *FixedWidthWriterConfig
+}
+
+// FixedWidthWriterConfig is the configuration of FixedWidthWriter.
+type FixedWidthWriterConfig struct {
+	Writer io.Writer
+
+	// Detail is the layout of the records received from channel.
+	Detail FixedWidthRecord
+
+	// Header is the layout of the header record.
+	// If it is not nil, FixedWidthWriter firstly outputs the header record.
+	Header *FixedWidthRecord
+
+	// HeaderFunc returns the values of the header record.
+	// If it is nil, the header record has only the prefix and paddings.
+	HeaderFunc func(ctx context.Context) (middleware.MapMapperType, error)
+
+	// Trailer is the layout of the trailer record.
+	// If it is not nil, FixedWidthWriter lastly outputs the trailer record.
+	Trailer *FixedWidthRecord
+
+	// TrailerFunc returns the values of the trailer record.
+	// Arg count is the number of the detail records written.
+	// If it is nil, the trailer record has only the prefix and paddings.
+	TrailerFunc func(ctx context.Context, count uint64) (middleware.MapMapperType, error)
+
+	// If UseCRLF is true, FixedWidthWriter uses \r\n as the line terminator.
+	UseCRLF bool
+}
+
+func NewFixedWidthWriter(conf *FixedWidthWriterConfig) *FixedWidthWriter {
+	return &FixedWidthWriter{
+		conf: conf,
+	}
+}
+
+func (w *FixedWidthWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	writer := bufio.NewWriter(w.conf.Writer)
+
+	if w.conf.Header != nil {
+		header := make(middleware.MapMapperType)
+		if w.conf.HeaderFunc != nil {
+			var err error
+			if header, err = w.conf.HeaderFunc(ctx); err != nil {
+				return err
+			}
+		}
+		if err := w.writeRecord(writer, w.conf.Header, header); err != nil {
+			return err
+		}
+	}
+
+	var count uint64
+	for chunk := range ch {
+		ctx := middleware.NextChunkContext(ctx)
+		items, err := middleware.ToMapMapperChunk(chunk)
+		if err != nil {
+			return err
+		}
+		if err := w.flush(ctx, writer, items); err != nil {
+			return err
+		}
+		count += uint64(len(items))
+	}
+
+	if w.conf.Trailer != nil {
+		trailer := make(middleware.MapMapperType)
+		if w.conf.TrailerFunc != nil {
+			var err error
+			if trailer, err = w.conf.TrailerFunc(ctx, count); err != nil {
+				return err
+			}
+		}
+		if err := w.writeRecord(writer, w.conf.Trailer, trailer); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func (w *FixedWidthWriter) flush(ctx context.Context, writer *bufio.Writer,
+	items []middleware.MapMapperType) error {
+
+	_, span := tracing.StartSpan(ctx, "file.FixedWidthWriter.Flush",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+	defer span.End()
+
+	for _, item := range items {
+		if err := w.writeRecord(writer, &w.conf.Detail, item); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+func (w *FixedWidthWriter) writeRecord(writer *bufio.Writer, record *FixedWidthRecord,
+	resultSet middleware.MapMapperType) error {
+
+	line, err := record.format(resultSet)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.WriteString(line); err != nil {
+		return err
+	}
+	if w.conf.UseCRLF {
+		_, err = writer.WriteString("\r\n")
+	} else {
+		err = writer.WriteByte('\n')
+	}
+	return err
+}
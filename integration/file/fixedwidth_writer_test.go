@@ -0,0 +1,65 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"testing"
+)
+
+func TestFixedWidthWriter(t *testing.T) {
+	t.Run("With header and trailer", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := NewFixedWidthWriter(&FixedWidthWriterConfig{
+			Writer:  &buf,
+			Detail:  fixedWidthDetail,
+			Header:  fixedWidthHeader,
+			Trailer: fixedWidthTrailer,
+			HeaderFunc: func(ctx context.Context) (middleware.MapMapperType, error) {
+				return middleware.MapMapperType{"date": "20220101", "sender": "BANK"}, nil
+			},
+			TrailerFunc: func(ctx context.Context, count uint64) (middleware.MapMapperType, error) {
+				return middleware.MapMapperType{"count": "2"}, nil
+			},
+		})
+
+		ch := make(chan interface{}, 2)
+		ch <- []middleware.MapMapperType{{"id": "1", "name": "John", "amount": "12345"}}
+		ch <- []middleware.CustomMapperType{{Props: TestFixedWidthType{
+			Id:     "2",
+			Name:   "Andy",
+			Amount: "500",
+		}}}
+		close(ch)
+		assert.NoError(t, writer.Write(context.TODO(), ch))
+
+		expected, err := os.ReadFile("testdata/fixedwidth.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(expected), buf.String())
+	})
+
+	t.Run("Exceeded length", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := NewFixedWidthWriter(&FixedWidthWriterConfig{
+			Writer:  &buf,
+			Detail:  fixedWidthDetail,
+			UseCRLF: true,
+		})
+
+		ch := make(chan interface{}, 1)
+		ch <- []middleware.MapMapperType{{"id": "1", "name": "Christopher"}}
+		close(ch)
+		assert.EqualError(t, writer.Write(context.TODO(), ch),
+			"Exceeded the length of column name: 11 > 10")
+	})
+}
+
+type TestFixedWidthType struct {
+	Id     string `prop:"id"`
+	Name   string `prop:"name"`
+	Amount string `prop:"amount"`
+}
@@ -0,0 +1,364 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ middleware.Reader = new(MultiResourceReader)
+
+// Keys of the metadata added to items by MultiResourceReader.
+const (
+	MetaFileKey = "_file"
+	MetaLineKey = "_line"
+)
+
+// ResourcePositionKey is the key of the execution context of the step
+// where MultiResourceReader puts the position of the last item
+// processed by the writer.
+const ResourcePositionKey = "file.MultiResourceReader.position"
+
+// ResourceOrder is the order in which MultiResourceReader reads files.
+type ResourceOrder int
+
+const (
+	// OrderByName reads files in ascending order of path.
+	OrderByName ResourceOrder = iota
+
+	// OrderByModTime reads files in ascending order of modification time.
+	OrderByModTime
+)
+
+// Resource is a file read by MultiResourceReader.
+type Resource struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// ResourcePosition is the position to restart MultiResourceReader.
+type ResourcePosition struct {
+	// File is the path of the file.
+	File string
+
+	// Record is the number of the records of File already read,
+	// excluding the header.
+	Record int64
+}
+
+// String returns the position in the form of "path:record",
+// which is parsed by ParseResourcePosition.
+func (p ResourcePosition) String() string {
+	return p.File + ":" + strconv.FormatInt(p.Record, 10)
+}
+
+// ParseResourcePosition parses the position in the form of "path:record",
+// e.g. given by job parameters.
+func ParseResourcePosition(s string) (*ResourcePosition, error) {
+	idx := strings.LastIndex(s, ":")
+	if idx < 0 {
+		return nil, fmt.Errorf("Invalid resource position: %s", s)
+	}
+	record, err := strconv.ParseInt(s[idx+1:], 10, 64)
+	if err != nil || record < 0 {
+		return nil, fmt.Errorf("Invalid resource position: %s", s)
+	}
+	return &ResourcePosition{File: s[:idx], Record: record}, nil
+}
+
+// MultiResourceReader is an implementation of middleware.Reader.
+// It reads csv files matching a glob pattern or listed as one input
+// in sequence.
+type MultiResourceReader struct {
+	conf *MultiResourceReaderConfig
+
+	mu       sync.Mutex
+	position ResourcePosition
+}
+
+// MultiResourceReaderConfig is the configuration of MultiResourceReader.
+type MultiResourceReaderConfig struct {
+	// Pattern is the glob pattern of the files, e.g. "sales_2026-10-*.csv".
+	Pattern string
+
+	// Paths are the files read in addition to the files matching Pattern.
+	Paths []string
+
+	// Order is the order of the files.
+	Order ResourceOrder
+
+	// Less is the custom comparator of the files.
+	// If it is not nil, Order is ignored.
+	Less func(a, b Resource) bool
+
+	// Options are the options to open each file.
+	// HasHeader, ChunkSize and RowMapperFunc of ReaderConfig
+	// apply to the whole input.
+	// HeaderFunc, TrailerFunc, SumColumns and RejectWriter are not supported.
+	Options ReaderOptions
+
+	// Header is the expected header of each file if HasHeader is true.
+	// If it is nil, the header of the 1st file is expected.
+	// It is ignored if Options.Schema is specified.
+	Header []string
+
+	// If WithMetadata is true, the path and the line number of each item
+	// are added to MapMapperType with the keys MetaFileKey and MetaLineKey.
+	WithMetadata bool
+
+	// Restart is the position to restart reading from.
+	// The files before Restart.File are skipped
+	// and so are the first Restart.Record records of it.
+	Restart *ResourcePosition
+}
+
+func NewMultiResourceReader(conf *MultiResourceReaderConfig) *MultiResourceReader {
+	return &MultiResourceReader{
+		conf: conf,
+	}
+}
+
+// Position returns the position of the last item processed by the writer.
+// It is also put to the execution context of the step by ResourcePositionKey.
+// It advances when the writer has processed a chunk,
+// so the items sent to the failed writer are read again at the restart.
+// If the reader runs outside a step, it advances when a chunk is sent.
+// It is saved to restart reading by MultiResourceReaderConfig.Restart.
+func (r *MultiResourceReader) Position() ResourcePosition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.position
+}
+
+// Resources returns the files to read in order.
+func (r *MultiResourceReader) Resources() ([]Resource, error) {
+	paths := append([]string{}, r.conf.Paths...)
+	if r.conf.Pattern != "" {
+		matches, err := filepath.Glob(r.conf.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	seen := make(map[string]bool, len(paths))
+	resources := make([]Resource, 0, len(paths))
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, Resource{
+			Path:    path,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+	}
+
+	less := r.conf.Less
+	if less == nil {
+		switch r.conf.Order {
+		case OrderByModTime:
+			less = func(a, b Resource) bool {
+				if a.ModTime.Equal(b.ModTime) {
+					return a.Path < b.Path
+				}
+				return a.ModTime.Before(b.ModTime)
+			}
+		default:
+			less = func(a, b Resource) bool {
+				return a.Path < b.Path
+			}
+		}
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return less(resources[i], resources[j])
+	})
+
+	return resources, nil
+}
+
+func (r *MultiResourceReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	opts := &r.conf.Options
+	if opts.HeaderFunc != nil || opts.TrailerFunc != nil || len(opts.SumColumns) != 0 ||
+		opts.RejectWriter != nil {
+		return errors.New("Not supported HeaderFunc, TrailerFunc, SumColumns and RejectWriter in MultiResourceReader")
+	}
+
+	resources, err := r.Resources()
+	if err != nil {
+		return err
+	}
+
+	restart := r.conf.Restart
+	if restart != nil {
+		idx := -1
+		for i, res := range resources {
+			if res.Path == restart.File {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("Not found restart file: %s", restart.File)
+		}
+		resources = resources[idx:]
+	}
+
+	s := &multiResourceState{
+		ctx:    ctx,
+		ch:     ch,
+		reader: r,
+		header: r.conf.Header,
+	}
+	for i, res := range resources {
+		var skip int64
+		if i == 0 && restart != nil {
+			skip = restart.Record
+		}
+		if err := s.readResource(res.Path, skip); err != nil {
+			return err
+		}
+	}
+
+	if len(s.chunk) != 0 || r.conf.Options.ChunkSize == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+// multiResourceState is the state of MultiResourceReader.Read.
+type multiResourceState struct {
+	ctx    context.Context
+	ch     chan<- interface{}
+	reader *MultiResourceReader
+	header []string
+
+	chunk    []middleware.MapMapperType
+	position ResourcePosition
+}
+
+func (s *multiResourceState) readResource(path string, skip int64) error {
+	opts := &s.reader.conf.Options
+	rc, err := Open(path, opts.Compression)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	reader, err := newCSVReader(rc, opts, nil)
+	if err != nil {
+		return err
+	}
+
+	var header []string
+	if opts.HasHeader {
+		if header, err = reader.Read(); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("Not found header of %s", path)
+			}
+			return err
+		}
+		// If Schema is specified, it validates the header of each file instead.
+		if opts.Schema == nil {
+			if s.header == nil {
+				s.header = header
+			}
+			if !equalHeader(header, s.header) {
+				return fmt.Errorf("Mismatched header of %s: %v, expected %v", path, header, s.header)
+			}
+		}
+	}
+	var skipped int
+	if opts.HasHeader {
+		skipped = 1
+	}
+	line := newLineCounter(reader, skipped)
+	mapping, err := newFieldMapping(opts.Schema, header, line.header())
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+
+	var record int64
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		record++
+		lineNum := line.next()
+		if record <= skip {
+			continue
+		}
+
+		resultSet, err := mapping.resultSet(fields, lineNum)
+		if err != nil {
+			return fmt.Errorf("%w in %s", err, path)
+		}
+		if s.reader.conf.WithMetadata {
+			resultSet[MetaFileKey] = path
+			resultSet[MetaLineKey] = strconv.Itoa(lineNum)
+		}
+		s.chunk = append(s.chunk, resultSet)
+		s.position = ResourcePosition{File: path, Record: record}
+
+		if chunkSize := int(opts.ChunkSize); chunkSize > 0 && len(s.chunk) >= chunkSize {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *multiResourceState) flush() error {
+	position := s.position
+	commit := func() {
+		s.reader.mu.Lock()
+		s.reader.position = position
+		s.reader.mu.Unlock()
+		middleware.ExecutionContextFromContext(s.ctx).Put(ResourcePositionKey, position.String())
+	}
+	written := middleware.OnChunkWritten(s.ctx, commit)
+
+	if err := sendChunk(s.ctx, s.ch, s.chunk, s.reader.conf.Options.RowMapperFunc); err != nil {
+		return err
+	}
+	s.chunk = nil
+
+	if !written {
+		commit()
+	}
+	return nil
+}
+
+func equalHeader(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
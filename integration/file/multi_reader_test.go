@@ -0,0 +1,212 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"testing"
+	"time"
+)
+
+func readMulti(reader *MultiResourceReader) ([][]middleware.MapMapperType, error) {
+	return readMultiContext(context.TODO(), reader)
+}
+
+func readMultiContext(ctx context.Context, reader *MultiResourceReader) ([][]middleware.MapMapperType, error) {
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(ctx, ch)
+	}()
+	var chunks [][]middleware.MapMapperType
+	for chunk := range ch {
+		chunks = append(chunks, chunk.([]middleware.MapMapperType))
+	}
+	return chunks, <-errCh
+}
+
+func ids(chunks [][]middleware.MapMapperType) []string {
+	var result []string
+	for _, chunk := range chunks {
+		for _, item := range chunk {
+			result = append(result, item["id"])
+		}
+	}
+	return result
+}
+
+// failingWriter fails when it receives the chunk of failAt counting from 1.
+type failingWriter struct {
+	failAt int
+}
+
+func (w *failingWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	var n int
+	for range ch {
+		n++
+		if n == w.failAt {
+			return errors.New("Write error")
+		}
+	}
+	return nil
+}
+
+func TestMultiResourceReader(t *testing.T) {
+	t.Run("Glob with metadata", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: "testdata/multi/sales_2026-10-*.csv*",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, ChunkSize: 2},
+			},
+			WithMetadata: true,
+		})
+
+		se := middleware.NewStepExecution(nil, "SyncStep")
+		chunks, err := readMultiContext(middleware.WithStepExecution(context.TODO(), se), reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3", "4", "5", "6"}, ids(chunks))
+		assert.Len(t, chunks, 3)
+		assert.Equal(t, middleware.MapMapperType{
+			"id":        "3",
+			"name":      "Emma",
+			MetaFileKey: "testdata/multi/sales_2026-10-02.csv",
+			MetaLineKey: "2",
+		}, chunks[1][0])
+		assert.Equal(t, ResourcePosition{
+			File:   "testdata/multi/sales_2026-10-03.csv.gz",
+			Record: 3,
+		}, reader.Position())
+		assert.Equal(t, reader.Position().String(), se.ExecutionContext().GetString(ResourcePositionKey))
+	})
+
+	t.Run("Position processed by the failed writer", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: "testdata/multi/sales_2026-10-*.csv*",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, ChunkSize: 2},
+			},
+		})
+
+		err := wolfx.NewStepBuilder(context.TODO()).
+			SetReader(reader).
+			SetWriter(&failingWriter{failAt: 2}).
+			Build()
+		assert.EqualError(t, err, "Write error")
+		// The 2nd chunk is read again at the restart.
+		assert.Equal(t, ResourcePosition{
+			File:   "testdata/multi/sales_2026-10-01.csv",
+			Record: 2,
+		}, reader.Position())
+	})
+
+	t.Run("Restart at file and record", func(t *testing.T) {
+		pos, err := ParseResourcePosition("testdata/multi/sales_2026-10-01.csv:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: "testdata/multi/sales_2026-10-*.csv*",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true},
+			},
+			Restart: pos,
+		})
+
+		chunks, err := readMulti(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2", "3", "4", "5", "6"}, ids(chunks))
+	})
+
+	t.Run("Custom order", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Paths: []string{
+				"testdata/multi/sales_2026-10-01.csv",
+				"testdata/multi/sales_2026-10-02.csv",
+			},
+			Less: func(a, b Resource) bool {
+				return a.Path > b.Path
+			},
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true},
+			},
+		})
+
+		chunks, err := readMulti(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3", "1", "2"}, ids(chunks))
+	})
+
+	t.Run("Order by modification time", func(t *testing.T) {
+		dir := t.TempDir()
+		now := time.Now()
+		for i, name := range []string{"b.csv", "a.csv"} {
+			path := dir + "/" + name
+			if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			modTime := now.Add(time.Duration(i) * time.Hour)
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: dir + "/*.csv",
+			Order:   OrderByModTime,
+		})
+
+		chunks, err := readMulti(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{{"0": "b.csv"}, {"0": "a.csv"}}, chunks[0])
+	})
+
+	t.Run("Mismatched header", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: "testdata/multi/*.csv",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true},
+			},
+		})
+
+		_, err := readMulti(reader)
+		assert.EqualError(t, err, "Mismatched header of testdata/multi/sales_2026-10-01.csv: "+
+			"[id name], expected [name id]")
+	})
+
+	t.Run("Not supported control totals", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: "testdata/multi/*.csv",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, SumColumns: []string{"amount"}},
+			},
+		})
+
+		_, err := readMulti(reader)
+		assert.EqualError(t, err, "Not supported HeaderFunc, TrailerFunc, SumColumns and RejectWriter in MultiResourceReader")
+	})
+
+	t.Run("Not supported RejectWriter", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Pattern: "testdata/multi/*.csv",
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, RejectWriter: NewRejectWriter(&RejectWriterConfig{Writer: new(bytes.Buffer)})},
+			},
+		})
+
+		_, err := readMulti(reader)
+		assert.EqualError(t, err, "Not supported HeaderFunc, TrailerFunc, SumColumns and RejectWriter in MultiResourceReader")
+	})
+}
+
+func TestParseResourcePosition(t *testing.T) {
+	pos, err := ParseResourcePosition("C:/data/a.csv:10")
+	assert.NoError(t, err)
+	assert.Equal(t, &ResourcePosition{File: "C:/data/a.csv", Record: 10}, pos)
+	assert.Equal(t, "C:/data/a.csv:10", pos.String())
+
+	_, err = ParseResourcePosition("a.csv")
+	assert.EqualError(t, err, "Invalid resource position: a.csv")
+}
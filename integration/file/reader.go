@@ -2,9 +2,14 @@ package file
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
 	"io"
-	"strconv"
+	"math"
+	"os"
+	"strings"
 )
 
 var _ middleware.Reader = new(Reader)
@@ -14,6 +19,18 @@ var _ middleware.Reader = new(Reader)
 // and is used to read csv format files.
 type Reader struct {
 	conf *ReaderConfig
+
+	// closer is the file opened by OpenReader.
+	closer io.Closer
+
+	// path is the file opened by OpenReader.
+	path string
+
+	// raw records the input for the raw lines of the rejected records.
+	raw *rawRecorder
+
+	// size is the size of the file reported as the total of the step.
+	size int64
 }
 
 // ReaderConfig is the configuration of Reader.
@@ -28,6 +45,46 @@ type ReaderConfig struct {
 	// If specify 0, Reader will read all lines of file at once.
 	ChunkSize uint
 
+	// Size is the size of the file in bytes.
+	// If it is greater than 0, Reader reports it as the total of the step
+	// so that the completion time can be estimated.
+	// The position is reported only if the CSVReader has the method
+	// InputOffset like csv.Reader.
+	Size int64
+
+	// File is the file read by the CSVReader.
+	// If Size is 0, the size of File is reported as the total of the step.
+	File *os.File
+
+	// Schema is the expected columns.
+	// If it is nil, the keys of items are the header as it is,
+	// or the positions starting 0 if HasHeader is false.
+	Schema *Schema
+
+	// HeaderFunc is called with the header record, the 1st line of file
+	// before the column header, e.g. the file date and the batch ID.
+	// It is used to put them into the execution context of the step
+	// by middleware.ExecutionContextFromContext.
+	HeaderFunc func(ctx context.Context, record []string) error
+
+	// TrailerFunc is called with the trailer record, the last line of file,
+	// after all other records are sent.
+	// It returns the control totals declared by the trailer
+	// and Reader checks them against the records read.
+	// If it returns nil, nothing is checked.
+	TrailerFunc func(ctx context.Context, record []string) (*ControlTotals, error)
+
+	// SumColumns are the columns summed exactly as decimals, e.g. "-12.34",
+	// to be checked against ControlTotals.Sums.
+	// The empty value is regarded as 0.
+	SumColumns []string
+
+	// RejectWriter receives the records failed to parse or map,
+	// e.g. with the wrong number of fields or against Schema,
+	// instead of failing the step.
+	// The raw lines of the records are available only with OpenReader.
+	RejectWriter middleware.RejectWriter
+
 	// RowMapperFunc is the mapping function.
 	// If it is nil, Reader will send data as the type
 	// of MapMapperType to channel.
@@ -48,23 +105,180 @@ func NewReader(conf *ReaderConfig) *Reader {
 	}
 }
 
+// ReaderOptions is the options of OpenReader.
+type ReaderOptions struct {
+	// ReaderConfig is the configuration of Reader.
+	// Its Reader is set by OpenReader.
+	ReaderConfig
+
+	// Compression is the compression format of the file.
+	// If specify CompressionAuto, it is detected from the extension
+	// or the magic bytes.
+	Compression Compression
+
+	// Comma is the field delimiter of csv.Reader.
+	// If specify 0, a comma is used.
+	Comma rune
+
+	// Encoding is the character encoding of the file,
+	// e.g. "Shift_JIS" and "windows-1252". See LookupEncoding.
+	// The file is decoded to UTF-8 and the BOM is stripped.
+	// If it is empty, UTF-8 is expected.
+	Encoding string
+}
+
+// OpenReader opens the csv file of path and returns the Reader of it.
+// The file is closed when Read returns.
+func OpenReader(path string, opts *ReaderOptions) (*Reader, error) {
+	if opts == nil {
+		opts = new(ReaderOptions)
+	}
+	rc, err := Open(path, opts.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw *rawRecorder
+	if opts.RejectWriter != nil {
+		raw = new(rawRecorder)
+	}
+	csvReader, err := newCSVReader(rc, opts, raw)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	conf := opts.ReaderConfig
+	conf.Reader = csvReader
+	// The position is not comparable with the size of the transcoded file.
+	if conf.Size == 0 && CompressionOf(rc) == CompressionNone && isUTF8(opts.Encoding) {
+		if info, err := os.Stat(path); err == nil {
+			conf.Size = info.Size()
+		}
+	}
+
+	return &Reader{
+		conf:   &conf,
+		closer: rc,
+		path:   path,
+		raw:    raw,
+	}, nil
+}
+
+// newCSVReader returns csv.Reader of r according to opts.
+// If raw is not nil, it records the decoded input.
+func newCSVReader(r io.Reader, opts *ReaderOptions, raw *rawRecorder) (*csv.Reader, error) {
+	r, err := NewDecodeReader(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		raw.r = r
+		r = raw
+	}
+	csvReader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		csvReader.Comma = opts.Comma
+	}
+	if opts.Schema != nil || opts.HeaderFunc != nil || opts.TrailerFunc != nil {
+		// The number of fields is checked by Schema
+		// and differs in the header and trailer records.
+		csvReader.FieldsPerRecord = -1
+	}
+	return csvReader, nil
+}
+
+// Close closes the file opened by OpenReader.
+// It is needed only if Read is not called.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	return err
+}
+
 func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
 	defer close(ch)
+	defer r.Close()
 
 	reader := r.conf.Reader
+	r.size = r.conf.Size
+	if r.size == 0 && r.conf.File != nil {
+		if info, err := r.conf.File.Stat(); err == nil && info.Mode().IsRegular() {
+			r.size = info.Size()
+		}
+	}
+	if r.size > 0 {
+		middleware.SetProgressTotal(ctx, uint64(r.size), middleware.ProgressBytes)
+	}
+
+	var skipped int
+	if r.conf.HeaderFunc != nil {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("Not found header record")
+			}
+			return err
+		}
+		skipped++
+		trimBOM(record)
+		if err := r.conf.HeaderFunc(ctx, record); err != nil {
+			return err
+		}
+	}
+
 	var header []string
 	if r.conf.HasHeader {
 		var err error
 		if header, err = reader.Read(); err != nil {
 			return err
 		}
+		if skipped == 0 {
+			trimBOM(header)
+		}
+		skipped++
+	}
+	line := newLineCounter(reader, skipped)
+	mapping, err := newFieldMapping(r.conf.Schema, header, line.header())
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	sums := newControlSums(r.conf.SumColumns)
+	values := make([]string, len(r.conf.SumColumns))
+	createItem := func(record []string, lineNum int) (middleware.MapMapperType, error) {
+		resultSet, err := mapping.resultSet(record, lineNum)
+		if err != nil {
+			return nil, err
+		}
+		for i, col := range r.conf.SumColumns {
+			values[i] = resultSet[col]
+		}
+		if err := sums.add(values, lineNum); err != nil {
+			return nil, err
+		}
+		count++
+		return resultSet, nil
 	}
 
-	if r.conf.ChunkSize > 0 {
+	var trailer *csvRecord
+	// ReadAll cannot continue after the rejected records.
+	if r.conf.ChunkSize > 0 || r.conf.RejectWriter != nil {
+		chunkSize := int(r.conf.ChunkSize)
+		if chunkSize == 0 {
+			chunkSize = math.MaxInt
+		}
+		records, err := newLookaheadReader(reader, line, r.conf.TrailerFunc != nil)
+		if err != nil {
+			return err
+		}
 		for {
 			var chunk []middleware.MapMapperType
-			for i := 0; i < int(r.conf.ChunkSize); i++ {
-				record, err := reader.Read()
+			for len(chunk) < chunkSize {
+				record, err := records.read()
 				if err == io.EOF {
 					if err := r.sendChunk(ctx, ch, chunk); err != nil {
 						return err
@@ -74,8 +288,13 @@ func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
 				if err != nil {
 					return err
 				}
-				resultSet := createResultSet(header, record)
-				chunk = append(chunk, resultSet)
+				resultSet, err := r.createItem(ctx, record, createItem)
+				if err != nil {
+					return err
+				}
+				if resultSet != nil {
+					chunk = append(chunk, resultSet)
+				}
 			}
 
 			if err := r.sendChunk(ctx, ch, chunk); err != nil {
@@ -83,14 +302,27 @@ func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
 			}
 		}
 	Exit:
+		trailer = records.last
 	} else {
 		records, err := reader.ReadAll()
 		if err != nil {
 			return err
 		}
+		if skipped == 0 && len(records) > 0 {
+			trimBOM(records[0])
+		}
+		if r.conf.TrailerFunc != nil && len(records) > 0 {
+			trailer = &csvRecord{fields: records[len(records)-1]}
+			records = records[:len(records)-1]
+		}
+		// The positions of fields are not available after ReadAll.
+		line.pos = nil
 		var chunk []middleware.MapMapperType
 		for _, record := range records {
-			resultSet := createResultSet(header, record)
+			resultSet, err := createItem(record, line.next())
+			if err != nil {
+				return err
+			}
 			chunk = append(chunk, resultSet)
 		}
 		if err := r.sendChunk(ctx, ch, chunk); err != nil {
@@ -98,35 +330,242 @@ func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
 		}
 	}
 
+	if r.conf.TrailerFunc != nil {
+		if trailer == nil {
+			return errors.New("Not found trailer record")
+		}
+		if trailer.err != nil {
+			return trailer.err
+		}
+		declared, err := r.conf.TrailerFunc(ctx, trailer.fields)
+		if err != nil {
+			return err
+		}
+		if declared != nil {
+			return sums.check(declared, count)
+		}
+	}
+
 	return nil
 }
 
+// createItem returns the item of record created by f.
+// If the record is invalid and RejectWriter is specified,
+// it sends the record to RejectWriter and returns nil without error.
+func (r *Reader) createItem(ctx context.Context, record *csvRecord,
+	f func(record []string, lineNum int) (middleware.MapMapperType, error)) (middleware.MapMapperType, error) {
+
+	err := record.err
+	if err == nil {
+		var resultSet middleware.MapMapperType
+		if resultSet, err = f(record.fields, record.line); err == nil {
+			r.raw.release(record.end)
+			return resultSet, nil
+		}
+	}
+	if r.conf.RejectWriter == nil {
+		return nil, err
+	}
+
+	reject := &middleware.Reject{
+		Source: r.path,
+		Line:   record.line,
+		Raw:    r.raw.slice(record.start, record.end),
+		Values: record.fields,
+		Err:    err,
+	}
+	r.raw.release(record.end)
+	return nil, middleware.WriteReject(ctx, r.conf.RejectWriter, reject)
+}
+
+// trimBOM strips the BOM glued to the 1st field of the 1st record
+// if the CSVReader reads the file as it is.
+// The 1st record is the header record, the header or the 1st item.
+func trimBOM(record []string) {
+	if len(record) > 0 {
+		record[0] = strings.TrimPrefix(record[0], bom)
+	}
+}
+
+// csvRecord is a record read by lookaheadReader.
+type csvRecord struct {
+	fields []string
+	line   int
+
+	// start and end are the offsets of the record in the input
+	// if the CSVReader has the method InputOffset.
+	start, end int64
+
+	// err is the error of parsing the record.
+	err error
+}
+
+// lookaheadReader reads records one ahead
+// to hold the last record back as the trailer.
+type lookaheadReader struct {
+	reader   CSVReader
+	line     *lineCounter
+	holdLast bool
+	offset   int64
+
+	// bom is true until the 1st record of the input is read,
+	// from which the BOM is stripped.
+	bom bool
+
+	next *csvRecord
+
+	// last is the record held back after io.EOF.
+	last *csvRecord
+}
+
+func newLookaheadReader(reader CSVReader, line *lineCounter, holdLast bool) (*lookaheadReader, error) {
+	l := &lookaheadReader{reader: reader, line: line, holdLast: holdLast, bom: line.records == 0}
+	if o, ok := reader.(inputOffset); ok {
+		l.offset = o.InputOffset()
+	}
+	if holdLast {
+		var err error
+		if l.next, err = l.fetch(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// fetch reads the next record.
+// It returns nil at the end of the input.
+// The error of parsing the record is not returned but held by csvRecord.
+func (l *lookaheadReader) fetch() (*csvRecord, error) {
+	fields, err := l.reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if l.bom {
+		trimBOM(fields)
+		l.bom = false
+	}
+	record := &csvRecord{fields: fields, start: l.offset}
+	if o, ok := l.reader.(inputOffset); ok {
+		l.offset = o.InputOffset()
+		record.end = l.offset
+	}
+
+	var parseErr *csv.ParseError
+	switch {
+	case err == nil:
+		record.line = l.line.next()
+	case errors.As(err, &parseErr):
+		record.line = l.line.skip(parseErr.StartLine)
+		record.err = err
+	default:
+		return nil, err
+	}
+	return record, nil
+}
+
+// read returns the next record.
+func (l *lookaheadReader) read() (*csvRecord, error) {
+	if !l.holdLast {
+		record, err := l.fetch()
+		if err == nil && record == nil {
+			return nil, io.EOF
+		}
+		return record, err
+	}
+	if l.next == nil {
+		return nil, io.EOF
+	}
+
+	record, err := l.fetch()
+	if err != nil {
+		return nil, err
+	}
+	current := l.next
+	l.next = record
+	if record == nil {
+		l.last = current
+		return nil, io.EOF
+	}
+	return current, nil
+}
+
+// fieldPos is implemented by csv.Reader.
+type fieldPos interface {
+	FieldPos(field int) (line, column int)
+}
+
+// lineCounter returns the line numbers of the records for the error messages.
+// They are given by csv.Reader if available,
+// otherwise counted on the assumption that a record is a line.
+type lineCounter struct {
+	pos     fieldPos
+	records int
+}
+
+// Arg skipped is the number of the records read before, e.g. the header.
+func newLineCounter(reader CSVReader, skipped int) *lineCounter {
+	c := &lineCounter{records: skipped}
+	c.pos, _ = reader.(fieldPos)
+	return c
+}
+
+// header returns the line number of the header.
+func (c *lineCounter) header() int {
+	if c.pos != nil && c.records > 0 {
+		line, _ := c.pos.FieldPos(0)
+		return line
+	}
+	return 1
+}
+
+// skip counts the record failed to read at line.
+func (c *lineCounter) skip(line int) int {
+	c.records++
+	return line
+}
+
+// next returns the line number of the record just read.
+func (c *lineCounter) next() int {
+	c.records++
+	if c.pos != nil {
+		line, _ := c.pos.FieldPos(0)
+		return line
+	}
+	return c.records
+}
+
+// inputOffset is implemented by csv.Reader.
+type inputOffset interface {
+	InputOffset() int64
+}
+
 func (r *Reader) sendChunk(ctx context.Context, ch chan<- interface{},
 	chunk []middleware.MapMapperType) error {
 
-	if r.conf.RowMapperFunc == nil {
-		ch <- chunk
-	} else {
-		if err := r.conf.RowMapperFunc(ctx, ch, chunk); err != nil {
-			return err
-		}
+	if o, ok := r.conf.Reader.(inputOffset); ok && r.size > 0 {
+		middleware.SetProgressPosition(ctx, uint64(o.InputOffset()))
 	}
 
-	return nil
+	return sendChunk(ctx, ch, chunk, r.conf.RowMapperFunc)
 }
 
-func createResultSet(header []string, record []string) middleware.MapMapperType {
-	resultSet := make(middleware.MapMapperType)
+// sendChunk sends chunk to ch through mapper if it is not nil.
+func sendChunk(ctx context.Context, ch chan<- interface{},
+	chunk []middleware.MapMapperType, mapper middleware.RowMapper) error {
 
-	for idx, val := range record {
-		var key string
-		if header == nil {
-			key = strconv.Itoa(idx)
-		} else {
-			key = header[idx]
+	ctx = middleware.NextChunkContext(ctx)
+	if mapper == nil {
+		ch <- chunk
+	} else {
+		ctx, span := tracing.StartSpan(ctx, "RowMapper",
+			tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(chunk)))))
+		err := mapper(ctx, ch, chunk)
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			return err
 		}
-		resultSet[key] = val
 	}
 
-	return resultSet
+	return nil
 }
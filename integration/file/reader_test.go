@@ -291,3 +291,68 @@ func CSVMapper(ctx context.Context, ch chan<- interface{},
 
 	return nil
 }
+
+func TestReaderReportsProgress(t *testing.T) {
+	file, err := os.Open("testdata/test_with_header.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReader(&ReaderConfig{
+		Reader:    csv.NewReader(file),
+		HasHeader: true,
+		ChunkSize: 1,
+		File:      file,
+	})
+
+	se := middleware.NewStepExecution(nil, "ReadStep")
+	ctx := middleware.WithStepExecution(context.TODO(), se)
+	ch := make(chan interface{})
+	go func() {
+		if err := reader.Read(ctx, ch); err != nil {
+			t.Error(err)
+		}
+	}()
+	for range ch {
+	}
+
+	p := se.Progress()
+	assert.Equal(t, middleware.ProgressBytes, p.Unit)
+	assert.Equal(t, uint64(info.Size()), p.Total)
+	assert.Equal(t, uint64(info.Size()), p.Done)
+}
+
+func TestOpenReader(t *testing.T) {
+	for _, path := range []string{
+		"testdata/test_with_header.csv",
+		"testdata/test_with_header.csv.bz2",
+		"testdata/test_with_header.gz.bin",
+	} {
+		t.Run(path, func(t *testing.T) {
+			reader, err := OpenReader(path, &ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ch := make(chan interface{})
+			go func() {
+				if err := reader.Read(context.TODO(), ch); err != nil {
+					t.Error(err)
+				}
+			}()
+			chunk := (<-ch).([]middleware.MapMapperType)
+			for range ch {
+			}
+
+			assertionFileReader(t, chunk, "id", "name", "created_at")
+			assert.Nil(t, reader.closer)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var _ middleware.RejectWriter = new(RejectWriter)
+
+// RejectHeader is the header of the file written by RejectWriter.
+// The column raw is the original line of the record,
+// or the values in csv format if the line is not available,
+// so that the records can be fixed and replayed.
+var RejectHeader = []string{"step", "source", "line", "error", "raw"}
+
+// RejectWriter is an implementation of middleware.RejectWriter.
+// It writes the rejected records in csv format with the columns of RejectHeader.
+// It is safe for concurrent use.
+type RejectWriter struct {
+	conf *RejectWriterConfig
+
+	mu      sync.Mutex
+	writer  *csv.Writer
+	started bool
+}
+
+// RejectWriterConfig is the configuration of RejectWriter.
+type RejectWriterConfig struct {
+	Writer io.Writer
+
+	// If NoHeader is true, RejectWriter does not output RejectHeader
+	// before the 1st record.
+	NoHeader bool
+}
+
+func NewRejectWriter(conf *RejectWriterConfig) *RejectWriter {
+	return &RejectWriter{
+		conf:   conf,
+		writer: csv.NewWriter(conf.Writer),
+	}
+}
+
+// WriteReject writes reject and flushes it.
+func (w *RejectWriter) WriteReject(ctx context.Context, reject *middleware.Reject) error {
+	raw := reject.Raw
+	if raw == "" && reject.Values != nil {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write(reject.Values)
+		writer.Flush()
+		raw = strings.TrimRight(buf.String(), "\n")
+	}
+	var errMsg string
+	if reject.Err != nil {
+		errMsg = reject.Err.Error()
+	}
+	var line string
+	if reject.Line > 0 {
+		line = strconv.Itoa(reject.Line)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started && !w.conf.NoHeader {
+		if err := w.writer.Write(RejectHeader); err != nil {
+			return err
+		}
+	}
+	w.started = true
+	if err := w.writer.Write([]string{reject.StepName, reject.Source, line, errMsg, raw}); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// rawRecorder records the input read through it
+// to take the raw lines of the records by the offsets.
+// The nil value records nothing.
+type rawRecorder struct {
+	r io.Reader
+
+	buf []byte
+
+	// base is the offset of buf[0].
+	base int64
+}
+
+func (rr *rawRecorder) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// slice returns the input between the offsets without the line terminator.
+func (rr *rawRecorder) slice(start, end int64) string {
+	if rr == nil || start < rr.base || end <= start || end-rr.base > int64(len(rr.buf)) {
+		return ""
+	}
+	return strings.TrimRight(string(rr.buf[start-rr.base:end-rr.base]), "\r\n")
+}
+
+// release discards the input before offset.
+func (rr *rawRecorder) release(offset int64) {
+	if rr == nil || offset <= rr.base {
+		return
+	}
+	n := offset - rr.base
+	if n > int64(len(rr.buf)) {
+		n = int64(len(rr.buf))
+	}
+	rr.buf = rr.buf[:copy(rr.buf, rr.buf[n:])]
+	rr.base += n
+}
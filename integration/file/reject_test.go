@@ -0,0 +1,121 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const rejectInput = `id,name
+1,Alice
+2,"Bo"b
+3,Carol,x
+4,Dave
+5
+6,Frank
+`
+
+func TestReaderWithRejectWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(path, []byte(rejectInput), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunkSize := range []uint{0, 2} {
+		t.Run("ChunkSize "+strconv.Itoa(int(chunkSize)), func(t *testing.T) {
+			var buf bytes.Buffer
+			reader, err := OpenReader(path, &ReaderOptions{
+				ReaderConfig: ReaderConfig{
+					HasHeader:    true,
+					ChunkSize:    chunkSize,
+					RejectWriter: NewRejectWriter(&RejectWriterConfig{Writer: &buf}),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			se := middleware.NewStepExecution(nil, "ReadStep")
+			ctx := middleware.WithStepExecution(context.TODO(), se)
+			ch := make(chan interface{})
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- reader.Read(ctx, ch)
+			}()
+			var ids []string
+			for chunk := range ch {
+				for _, item := range chunk.([]middleware.MapMapperType) {
+					ids = append(ids, item["id"])
+				}
+			}
+			assert.NoError(t, <-errCh)
+			assert.Equal(t, []string{"1", "4", "6"}, ids)
+			assert.Equal(t, uint64(3), se.SkipCount())
+
+			rejects, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, rejects, 4) {
+				assert.Equal(t, RejectHeader, rejects[0])
+				assert.Equal(t, []string{"ReadStep", path, "3", `parse error on line 3, column 6: extraneous or missing " in quoted-field`, `2,"Bo"b`}, rejects[1])
+				assert.Equal(t, []string{"ReadStep", path, "4", "record on line 4: wrong number of fields", "3,Carol,x"}, rejects[2])
+				assert.Equal(t, []string{"ReadStep", path, "6", "record on line 6: wrong number of fields", "5"}, rejects[3])
+			}
+		})
+	}
+}
+
+func TestReaderRejectsWithSchema(t *testing.T) {
+	var rejects []*middleware.Reject
+	csvReader := csv.NewReader(strings.NewReader("id,name\n1,Alice\n2\n3,Carol\n"))
+	csvReader.FieldsPerRecord = -1
+	reader := NewReader(&ReaderConfig{
+		Reader:    csvReader,
+		HasHeader: true,
+		Schema: &Schema{Columns: []Column{
+			{Name: "id", Required: true},
+			{Name: "name", Required: true},
+		}},
+		RejectWriter: middleware.RejectWriterFunc(func(ctx context.Context, reject *middleware.Reject) error {
+			rejects = append(rejects, reject)
+			return nil
+		}),
+	})
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+	var items []middleware.MapMapperType
+	for chunk := range ch {
+		items = append(items, chunk.([]middleware.MapMapperType)...)
+	}
+	assert.NoError(t, <-errCh)
+	assert.Len(t, items, 2)
+	if assert.Len(t, rejects, 1) {
+		assert.Equal(t, 3, rejects[0].Line)
+		assert.Equal(t, []string{"2"}, rejects[0].Values)
+		assert.EqualError(t, rejects[0].Err, "Unexpected number of fields at line 3: 1, expected 2")
+	}
+}
+
+func TestRejectWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewRejectWriter(&RejectWriterConfig{Writer: &buf, NoHeader: true})
+	err := writer.WriteReject(context.TODO(), &middleware.Reject{
+		StepName: "LoadStep",
+		Values:   []string{"1", "a,b"},
+		Err:      assert.AnError,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "LoadStep,,,"+assert.AnError.Error()+",\"1,\"\"a,b\"\"\"\n", buf.String())
+}
@@ -0,0 +1,437 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"golang.org/x/text/encoding"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var _ middleware.Writer = new(RollingWriter)
+
+// DefaultDateFormat is the format of .Date in the template of RollingWriter.
+const DefaultDateFormat = "20060102"
+
+// RollingWriter is an implementation of middleware.Writer.
+// It writes csv files, opening a new file when the current file reaches
+// the number of rows, the number of bytes or a change in the partition key.
+type RollingWriter struct {
+	conf *RollingWriterConfig
+
+	mu       sync.Mutex
+	manifest []ManifestEntry
+}
+
+// RollingWriterConfig is the configuration of RollingWriter.
+type RollingWriterConfig struct {
+	// Template is the text/template of the path of each file.
+	// The following fields are available.
+	//
+	//	.Index  the sequence number of the file starting from 1
+	//	.Date   the date the writing started, formatted by DateFormat
+	//	.Key    the value of PartitionKey of the rows in the file
+	//
+	// Example: "out/sales_{{.Date}}_{{.Key}}_{{printf \"%03d\" .Index}}.csv.gz"
+	Template string
+
+	// DateFormat is the layout of .Date.
+	// If it is empty, DefaultDateFormat is used.
+	DateFormat string
+
+	// MaxRows is the maximum number of rows per file excluding the header.
+	// If specify 0, the number of rows is unlimited.
+	MaxRows uint64
+
+	// MaxBytes is the maximum size per file before compression.
+	// A row is not split, so a file has at least one row.
+	// If specify 0, the size is unlimited.
+	MaxBytes int64
+
+	// PartitionKey is the column whose change opens a new file.
+	// The rows are expected to be sorted by it.
+	PartitionKey string
+
+	// PropsBindPosition is the position mapping of header's columns.
+	// Key of map is property (column) name and value is position with starting 0.
+	PropsBindPosition middleware.PropsBindPosition
+
+	// If NoHeader is true, the header is not written to the files.
+	// Otherwise every file begins with the header.
+	NoHeader bool
+
+	// Compression is the compression format of the files.
+	// If specify CompressionAuto, it is detected from the extension.
+	Compression Compression
+
+	// Comma is the field delimiter of csv.Writer.
+	// If specify 0, a comma is used.
+	Comma rune
+
+	// If UseCRLF is true, csv.Writer uses \r\n as the line terminator.
+	UseCRLF bool
+
+	// Encoding is the character encoding of the files,
+	// e.g. "Shift_JIS" and "windows-1252". See LookupEncoding.
+	// If it is empty, the files are written in UTF-8.
+	// MaxBytes is compared with the size after encoding.
+	Encoding string
+
+	// If BOM is true, the BOM is written at the start of every file.
+	BOM bool
+
+	// ManifestPath is the path of the manifest listing the files.
+	// If it is empty, no manifest is written.
+	// The manifest is written as the JSON array of ManifestEntry
+	// when all files are written successfully.
+	ManifestPath string
+}
+
+// ManifestEntry is a file written by RollingWriter.
+type ManifestEntry struct {
+	Path string `json:"path"`
+	Key  string `json:"key,omitempty"`
+
+	// Rows is the number of rows excluding the header.
+	Rows uint64 `json:"rows"`
+
+	// Bytes is the size of the file.
+	Bytes int64 `json:"bytes"`
+
+	// SHA256 is the hex encoded checksum of the file.
+	SHA256 string `json:"sha256"`
+}
+
+// rollingTemplateData is the data of RollingWriterConfig.Template.
+type rollingTemplateData struct {
+	Index int
+	Date  string
+	Key   string
+}
+
+func NewRollingWriter(conf *RollingWriterConfig) *RollingWriter {
+	return &RollingWriter{
+		conf: conf,
+	}
+}
+
+// Manifest returns the files written so far by the last Write.
+func (w *RollingWriter) Manifest() []ManifestEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]ManifestEntry{}, w.manifest...)
+}
+
+func (w *RollingWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	if err := w.write(ctx, ch, new(fileOutput)); err != nil {
+		return err
+	}
+	return w.writeManifest()
+}
+
+// write writes the rows of ch to the files opened by output.
+func (w *RollingWriter) write(ctx context.Context, ch <-chan interface{}, output rollingOutput) (err error) {
+	if w.conf.Template == "" {
+		return errors.New("Not specified template of RollingWriter")
+	}
+	w.mu.Lock()
+	w.manifest = nil
+	w.mu.Unlock()
+	tmpl, err := template.New("path").Parse(w.conf.Template)
+	if err != nil {
+		return err
+	}
+	dateFormat := w.conf.DateFormat
+	if dateFormat == "" {
+		dateFormat = DefaultDateFormat
+	}
+	enc, err := LookupEncoding(w.conf.Encoding)
+	if err != nil {
+		return err
+	}
+	s := &rollingState{
+		writer: w,
+		output: output,
+		tmpl:   tmpl,
+		date:   time.Now().Format(dateFormat),
+		paths:  make(map[string]bool),
+	}
+	if enc != nil && !isUTF8(w.conf.Encoding) {
+		s.encoder = enc.NewEncoder()
+	}
+	if w.conf.BOM {
+		if s.bom, err = s.encode([]byte(bom)); err != nil {
+			return fmt.Errorf("Not supported BOM in encoding %s: %w", w.conf.Encoding, err)
+		}
+	}
+	if !w.conf.NoHeader {
+		if s.header, err = s.formatRow(generateHeader(w.conf.PropsBindPosition)); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if errClose := s.close(); err == nil {
+			err = errClose
+		}
+	}()
+
+	for chunk := range ch {
+		ctx := middleware.NextChunkContext(ctx)
+		items, err := middleware.ToMapMapperChunk(chunk)
+		if err != nil {
+			return err
+		}
+		if err := s.writeChunk(ctx, items); err != nil {
+			return err
+		}
+	}
+
+	return s.close()
+}
+
+func (w *RollingWriter) writeManifest() error {
+	if w.conf.ManifestPath == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(w.Manifest(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(w.conf.ManifestPath, append(b, '\n'), 0644)
+}
+
+// rollingState is the state of RollingWriter.Write.
+type rollingState struct {
+	writer *RollingWriter
+	output rollingOutput
+	tmpl   *template.Template
+	date   string
+	header []byte
+	paths  map[string]bool
+
+	// encoder is nil if the files are written in UTF-8.
+	encoder *encoding.Encoder
+	bom     []byte
+
+	// The current file.
+	dst     io.Writer
+	buf     *bufio.Writer
+	out     io.WriteCloser
+	counter *countingWriter
+	hash    hash.Hash
+	entry   ManifestEntry
+	size    int64
+}
+
+func (s *rollingState) writeChunk(ctx context.Context, items []middleware.MapMapperType) error {
+	_, span := tracing.StartSpan(ctx, "file.RollingWriter.Flush",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+	defer span.End()
+
+	conf := s.writer.conf
+	for _, item := range items {
+		row, err := s.formatRow(middleware.MapMapperToFlatItems(
+			[]middleware.MapMapperType{item}, conf.PropsBindPosition)[0])
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		key := item[conf.PartitionKey]
+		if s.needsRoll(key, int64(len(row))) {
+			if err := s.roll(key); err != nil {
+				span.RecordError(err)
+				return err
+			}
+		}
+		if _, err := s.out.Write(row); err != nil {
+			span.RecordError(err)
+			return err
+		}
+		s.entry.Rows++
+		s.size += int64(len(row))
+	}
+	return nil
+}
+
+func (s *rollingState) needsRoll(key string, rowSize int64) bool {
+	conf := s.writer.conf
+	switch {
+	case s.dst == nil:
+		return true
+	case s.entry.Rows == 0:
+		return false
+	case conf.PartitionKey != "" && key != s.entry.Key:
+		return true
+	case conf.MaxRows > 0 && s.entry.Rows >= conf.MaxRows:
+		return true
+	case conf.MaxBytes > 0 && s.size+rowSize > conf.MaxBytes:
+		return true
+	}
+	return false
+}
+
+func (s *rollingState) roll(key string) error {
+	if err := s.close(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	data := rollingTemplateData{
+		Index: len(s.paths) + 1,
+		Date:  s.date,
+		Key:   key,
+	}
+	if err := s.tmpl.Execute(&b, data); err != nil {
+		return err
+	}
+	path := b.String()
+	if s.paths[path] {
+		return fmt.Errorf("Duplicated output file: %s", path)
+	}
+	s.paths[path] = true
+
+	c := s.writer.conf.Compression
+	if c == CompressionAuto {
+		c = CompressionFromExt(path)
+	}
+	if c == CompressionBzip2 {
+		return ErrNotSupportedCompression
+	}
+	dst, err := s.output.create(path)
+	if err != nil {
+		return err
+	}
+	s.dst = dst
+	s.hash = sha256.New()
+	s.counter = &countingWriter{w: io.MultiWriter(dst, s.hash)}
+	s.buf = bufio.NewWriter(s.counter)
+	if s.out, err = NewCompressWriter(s.buf, c); err != nil {
+		return err
+	}
+	s.entry = ManifestEntry{Path: path, Key: key}
+	s.size = 0
+
+	if s.bom != nil {
+		if _, err := s.out.Write(s.bom); err != nil {
+			return err
+		}
+		s.size += int64(len(s.bom))
+	}
+	if s.header != nil {
+		if _, err := s.out.Write(s.header); err != nil {
+			return err
+		}
+		s.size += int64(len(s.header))
+	}
+	return nil
+}
+
+// close closes the current file and adds it to the manifest.
+func (s *rollingState) close() error {
+	if s.dst == nil {
+		return nil
+	}
+	err := s.out.Close()
+	if errFlush := s.buf.Flush(); err == nil {
+		err = errFlush
+	}
+	if errClose := s.output.close(); err == nil {
+		err = errClose
+	}
+	s.dst = nil
+	if err != nil {
+		return err
+	}
+
+	s.entry.Bytes = s.counter.n
+	s.entry.SHA256 = hex.EncodeToString(s.hash.Sum(nil))
+	s.writer.mu.Lock()
+	s.writer.manifest = append(s.writer.manifest, s.entry)
+	s.writer.mu.Unlock()
+	return nil
+}
+
+// formatRow encodes the row in csv format and the encoding.
+func (s *rollingState) formatRow(row []string) ([]byte, error) {
+	b, err := formatRow(s.writer.conf, row)
+	if err != nil {
+		return nil, err
+	}
+	return s.encode(b)
+}
+
+func (s *rollingState) encode(b []byte) ([]byte, error) {
+	if s.encoder == nil {
+		return b, nil
+	}
+	return s.encoder.Bytes(b)
+}
+
+// formatRow encodes the row in csv format.
+func formatRow(conf *RollingWriterConfig, row []string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if conf.Comma != 0 {
+		writer.Comma = conf.Comma
+	}
+	writer.UseCRLF = conf.UseCRLF
+	if err := writer.Write(row); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// rollingOutput opens the files of RollingWriter one at a time.
+type rollingOutput interface {
+	// create opens the file of path.
+	create(path string) (io.Writer, error)
+
+	// close closes the file opened by create.
+	close() error
+}
+
+// fileOutput is rollingOutput of the files in the file system.
+type fileOutput struct {
+	file *os.File
+}
+
+func (o *fileOutput) create(path string) (io.Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	o.file = f
+	return f, nil
+}
+
+func (o *fileOutput) close() error {
+	return o.file.Close()
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,164 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRolling(t *testing.T, writer *RollingWriter, chunks ...[]middleware.MapMapperType) error {
+	ch := make(chan interface{}, len(chunks))
+	for _, chunk := range chunks {
+		ch <- chunk
+	}
+	close(ch)
+	return writer.Write(context.TODO(), ch)
+}
+
+func readFile(t *testing.T, path string) string {
+	r, err := Open(path, CompressionAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+var rollingProps = middleware.PropsBindPosition{"id": 0, "region": 1}
+
+func TestRollingWriter(t *testing.T) {
+	t.Run("MaxRows with manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		writer := NewRollingWriter(&RollingWriterConfig{
+			Template:          filepath.Join(dir, `out_{{.Date}}_{{printf "%02d" .Index}}.csv.gz`),
+			MaxRows:           2,
+			PropsBindPosition: rollingProps,
+			ManifestPath:      filepath.Join(dir, "manifest.json"),
+		})
+
+		err := writeRolling(t, writer,
+			[]middleware.MapMapperType{
+				{"id": "1", "region": "east"},
+				{"id": "2", "region": "east"},
+			},
+			[]middleware.MapMapperType{
+				{"id": "3", "region": "west"},
+			})
+		assert.NoError(t, err)
+
+		date := time.Now().Format(DefaultDateFormat)
+		manifest := writer.Manifest()
+		assert.Len(t, manifest, 2)
+		assert.Equal(t, filepath.Join(dir, "out_"+date+"_01.csv.gz"), manifest[0].Path)
+		assert.Equal(t, filepath.Join(dir, "out_"+date+"_02.csv.gz"), manifest[1].Path)
+		assert.Equal(t, uint64(2), manifest[0].Rows)
+		assert.Equal(t, uint64(1), manifest[1].Rows)
+		assert.Equal(t, "id,region\n1,east\n2,east\n", readFile(t, manifest[0].Path))
+		assert.Equal(t, "id,region\n3,west\n", readFile(t, manifest[1].Path))
+
+		raw, err := os.ReadFile(manifest[1].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(raw)
+		assert.Equal(t, hex.EncodeToString(sum[:]), manifest[1].SHA256)
+		assert.Equal(t, int64(len(raw)), manifest[1].Bytes)
+
+		b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved []ManifestEntry
+		assert.NoError(t, json.Unmarshal(b, &saved))
+		assert.Equal(t, manifest, saved)
+	})
+
+	t.Run("PartitionKey and MaxBytes", func(t *testing.T) {
+		dir := t.TempDir()
+		writer := NewRollingWriter(&RollingWriterConfig{
+			Template:          filepath.Join(dir, "{{.Key}}_{{.Index}}.csv"),
+			PartitionKey:      "region",
+			MaxBytes:          25,
+			PropsBindPosition: rollingProps,
+		})
+
+		err := writeRolling(t, writer, []middleware.MapMapperType{
+			{"id": "1", "region": "east"},
+			{"id": "2", "region": "east"},
+			{"id": "3", "region": "east"},
+			{"id": "4", "region": "west"},
+		})
+		assert.NoError(t, err)
+
+		var paths []string
+		for _, entry := range writer.Manifest() {
+			paths = append(paths, filepath.Base(entry.Path))
+		}
+		// The header is 10 bytes and each row is 7 bytes.
+		assert.Equal(t, []string{"east_1.csv", "east_2.csv", "west_3.csv"}, paths)
+		assert.Equal(t, "id,region\n1,east\n2,east\n", readFile(t, filepath.Join(dir, "east_1.csv")))
+		assert.Equal(t, "id,region\n4,west\n", readFile(t, filepath.Join(dir, "west_3.csv")))
+	})
+
+	t.Run("Duplicated output file", func(t *testing.T) {
+		writer := NewRollingWriter(&RollingWriterConfig{
+			Template:          filepath.Join(t.TempDir(), "out.csv"),
+			MaxRows:           1,
+			PropsBindPosition: rollingProps,
+		})
+
+		err := writeRolling(t, writer, []middleware.MapMapperType{
+			{"id": "1", "region": "east"},
+			{"id": "2", "region": "east"},
+		})
+		assert.ErrorContains(t, err, "Duplicated output file: ")
+		assert.Len(t, writer.Manifest(), 1)
+	})
+	t.Run("Write twice", func(t *testing.T) {
+		dir := t.TempDir()
+		writer := NewRollingWriter(&RollingWriterConfig{
+			Template:          filepath.Join(dir, "out_{{.Index}}.csv"),
+			MaxRows:           2,
+			PropsBindPosition: rollingProps,
+			ManifestPath:      filepath.Join(dir, "manifest.json"),
+		})
+
+		err := writeRolling(t, writer, []middleware.MapMapperType{
+			{"id": "1", "region": "east"},
+			{"id": "2", "region": "east"},
+			{"id": "3", "region": "west"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, writer.Manifest(), 2)
+
+		// The manifest of the 2nd Write lists only the files written by it.
+		err = writeRolling(t, writer, []middleware.MapMapperType{
+			{"id": "4", "region": "west"},
+		})
+		assert.NoError(t, err)
+		manifest := writer.Manifest()
+		assert.Len(t, manifest, 1)
+		assert.Equal(t, filepath.Join(dir, "out_1.csv"), manifest[0].Path)
+		assert.Equal(t, uint64(1), manifest[0].Rows)
+
+		b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved []ManifestEntry
+		assert.NoError(t, json.Unmarshal(b, &saved))
+		assert.Equal(t, manifest, saved)
+	})
+}
@@ -0,0 +1,180 @@
+package file
+
+import (
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"strconv"
+	"strings"
+)
+
+// SchemaMode is how Schema handles the columns not matching it.
+type SchemaMode int
+
+const (
+	// SchemaStrict returns an error.
+	SchemaStrict SchemaMode = iota
+
+	// SchemaLenient ignores the extra columns or the missing columns.
+	SchemaLenient
+)
+
+// Column is an expected column of Schema.
+type Column struct {
+	// Name is the key of MapMapperType.
+	Name string
+
+	// Aliases are the other names of the column in the header,
+	// e.g. "Customer ID" for "customer_id".
+	Aliases []string
+
+	// If Required is true, the header must have the column
+	// unless Missing is SchemaLenient.
+	Required bool
+}
+
+// Schema is the expected columns of csv files.
+//
+// If the file has the header, the columns are matched by the header
+// regardless of the order, and items have the keys of Column.Name.
+// Otherwise the columns are matched by the position in the order of Columns.
+type Schema struct {
+	Columns []Column
+
+	// Extra is the mode for the columns not in Columns
+	// and the fields exceeding the columns.
+	// SchemaLenient drops them.
+	Extra SchemaMode
+
+	// Missing is the mode for the required columns not in the header
+	// and the records with less fields than the columns.
+	// SchemaLenient leaves them out of items.
+	Missing SchemaMode
+
+	// If IgnoreCase is true, the names in the header are matched
+	// case-insensitively.
+	IgnoreCase bool
+}
+
+// names returns the names of the columns in order.
+func (s *Schema) names() []string {
+	names := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// resolve returns the keys of the fields of header.
+// The key of a dropped field is empty.
+// Arg line is the line number of header used in the error messages.
+func (s *Schema) resolve(header []string, line int) ([]string, error) {
+	normalize := strings.TrimSpace
+	if s.IgnoreCase {
+		normalize = func(name string) string {
+			return strings.ToLower(strings.TrimSpace(name))
+		}
+	}
+	lookup := make(map[string]int)
+	for i, col := range s.Columns {
+		lookup[normalize(col.Name)] = i
+		for _, alias := range col.Aliases {
+			lookup[normalize(alias)] = i
+		}
+	}
+
+	keys := make([]string, len(header))
+	found := make([]bool, len(s.Columns))
+	for idx, name := range header {
+		i, ok := lookup[normalize(name)]
+		if !ok {
+			if s.Extra == SchemaLenient {
+				continue
+			}
+			return nil, fmt.Errorf("Unexpected column %q at line %d, column %d", name, line, idx+1)
+		}
+		if found[i] {
+			return nil, fmt.Errorf("Duplicated column %s at line %d, column %d", s.Columns[i].Name, line, idx+1)
+		}
+		found[i] = true
+		keys[idx] = s.Columns[i].Name
+	}
+
+	if s.Missing != SchemaLenient {
+		var missing []string
+		for i, col := range s.Columns {
+			if col.Required && !found[i] {
+				missing = append(missing, col.Name)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("Not found required columns at line %d: %s", line, strings.Join(missing, ", "))
+		}
+	}
+
+	return keys, nil
+}
+
+// fieldMapping maps the fields of records to the keys of MapMapperType.
+type fieldMapping struct {
+	schema *Schema
+	keys   []string
+}
+
+// newFieldMapping returns the mapping for header.
+// If header is nil, the fields are mapped by the position.
+func newFieldMapping(schema *Schema, header []string, line int) (*fieldMapping, error) {
+	m := &fieldMapping{schema: schema, keys: header}
+	if schema == nil {
+		return m, nil
+	}
+	if header == nil {
+		m.keys = schema.names()
+		return m, nil
+	}
+	var err error
+	m.keys, err = schema.resolve(header, line)
+	return m, err
+}
+
+// resultSet returns the item of record.
+// Arg line is the line number of record used in the error messages.
+func (m *fieldMapping) resultSet(record []string, line int) (middleware.MapMapperType, error) {
+	if m.schema == nil {
+		return createResultSet(m.keys, record), nil
+	}
+
+	switch {
+	case len(record) > len(m.keys) && m.schema.Extra != SchemaLenient,
+		len(record) < len(m.keys) && m.schema.Missing != SchemaLenient:
+		return nil, fmt.Errorf("Unexpected number of fields at line %d: %d, expected %d",
+			line, len(record), len(m.keys))
+	}
+
+	resultSet := make(middleware.MapMapperType, len(m.keys))
+	for idx, val := range record {
+		if idx >= len(m.keys) {
+			break
+		}
+		if key := m.keys[idx]; key != "" {
+			resultSet[key] = val
+		}
+	}
+	return resultSet, nil
+}
+
+func createResultSet(header []string, record []string) middleware.MapMapperType {
+	resultSet := make(middleware.MapMapperType)
+
+	for idx, val := range record {
+		var key string
+		if idx < len(header) {
+			key = header[idx]
+		} else {
+			// The fields exceeding the header are keyed by the position.
+			key = strconv.Itoa(idx)
+		}
+		resultSet[key] = val
+	}
+
+	return resultSet
+}
@@ -0,0 +1,162 @@
+package file
+
+import (
+	"context"
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"strings"
+	"testing"
+)
+
+func readSchema(input string, hasHeader bool, chunkSize uint, schema *Schema) ([]middleware.MapMapperType, error) {
+	csvReader := csv.NewReader(strings.NewReader(input))
+	csvReader.FieldsPerRecord = -1
+	reader := NewReader(&ReaderConfig{
+		Reader:    csvReader,
+		HasHeader: hasHeader,
+		ChunkSize: chunkSize,
+		Schema:    schema,
+	})
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+	var items []middleware.MapMapperType
+	for chunk := range ch {
+		items = append(items, chunk.([]middleware.MapMapperType)...)
+	}
+	return items, <-errCh
+}
+
+var customerSchema = Schema{
+	Columns: []Column{
+		{Name: "customer_id", Aliases: []string{"Customer ID"}, Required: true},
+		{Name: "name", Required: true},
+		{Name: "email"},
+	},
+}
+
+func TestReaderWithSchema(t *testing.T) {
+	t.Run("Reordered columns with alias", func(t *testing.T) {
+		items, err := readSchema("name,Customer ID\nAlice,1\nBob,2\n", true, 1, &customerSchema)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"customer_id": "1", "name": "Alice"},
+			{"customer_id": "2", "name": "Bob"},
+		}, items)
+	})
+
+	t.Run("Ignore case", func(t *testing.T) {
+		schema := customerSchema
+		schema.IgnoreCase = true
+		items, err := readSchema(" CUSTOMER_ID ,Name,EMAIL\n1,Alice,a@example.com\n", true, 0, &schema)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"customer_id": "1", "name": "Alice", "email": "a@example.com"},
+		}, items)
+	})
+
+	t.Run("Extra column in strict mode", func(t *testing.T) {
+		_, err := readSchema("customer_id,name,phone\n1,Alice,000\n", true, 1, &customerSchema)
+		assert.EqualError(t, err, `Unexpected column "phone" at line 1, column 3`)
+	})
+
+	t.Run("Extra column in lenient mode", func(t *testing.T) {
+		schema := customerSchema
+		schema.Extra = SchemaLenient
+		items, err := readSchema("customer_id,phone,name\n1,000,Alice\n", true, 1, &schema)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"customer_id": "1", "name": "Alice"},
+		}, items)
+	})
+
+	t.Run("Missing column in strict mode", func(t *testing.T) {
+		_, err := readSchema("email\na@example.com\n", true, 1, &customerSchema)
+		assert.EqualError(t, err, "Not found required columns at line 1: customer_id, name")
+	})
+
+	t.Run("Missing column in lenient mode", func(t *testing.T) {
+		schema := customerSchema
+		schema.Missing = SchemaLenient
+		items, err := readSchema("customer_id\n1\n", true, 1, &schema)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{{"customer_id": "1"}}, items)
+	})
+
+	t.Run("Duplicated column", func(t *testing.T) {
+		_, err := readSchema("customer_id,name,Customer ID\n1,Alice,1\n", true, 1, &customerSchema)
+		assert.EqualError(t, err, "Duplicated column customer_id at line 1, column 3")
+	})
+
+	t.Run("Extra field", func(t *testing.T) {
+		_, err := readSchema("customer_id,name\n1,Alice\n\"2\nx\",Bob\n3,Carol,x\n", true, 1, &customerSchema)
+		assert.EqualError(t, err, "Unexpected number of fields at line 5: 3, expected 2")
+	})
+
+	t.Run("Extra field with ReadAll", func(t *testing.T) {
+		_, err := readSchema("customer_id,name\n1,Alice\n2,Bob,x\n", true, 0, &customerSchema)
+		assert.EqualError(t, err, "Unexpected number of fields at line 3: 3, expected 2")
+	})
+
+	t.Run("Missing field in lenient mode", func(t *testing.T) {
+		schema := customerSchema
+		schema.Missing = SchemaLenient
+		items, err := readSchema("1,Alice,a@example.com\n2,Bob\n", false, 1, &schema)
+		assert.NoError(t, err)
+		assert.Equal(t, []middleware.MapMapperType{
+			{"customer_id": "1", "name": "Alice", "email": "a@example.com"},
+			{"customer_id": "2", "name": "Bob"},
+		}, items)
+	})
+}
+
+func TestReaderExtraFieldWithoutSchema(t *testing.T) {
+	items, err := readSchema("id,name\n1,Alice,x\n", true, 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []middleware.MapMapperType{
+		{"id": "1", "name": "Alice", "2": "x"},
+	}, items)
+}
+
+func TestMultiResourceReaderWithSchema(t *testing.T) {
+	schema := &Schema{Columns: []Column{
+		{Name: "id", Required: true},
+		{Name: "name", Required: true},
+	}}
+
+	t.Run("Reordered header", func(t *testing.T) {
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Paths: []string{
+				"testdata/multi/sales_2026-10-01.csv",
+				"testdata/multi/bad_header.csv",
+			},
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, Schema: schema},
+			},
+		})
+
+		chunks, err := readMulti(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"7", "1", "2"}, ids(chunks))
+		assert.Equal(t, "Bob", chunks[0][0]["name"])
+	})
+
+	t.Run("Missing column", func(t *testing.T) {
+		amount := *schema
+		amount.Columns = append(amount.Columns, Column{Name: "amount", Required: true})
+		reader := NewMultiResourceReader(&MultiResourceReaderConfig{
+			Paths: []string{"testdata/multi/sales_2026-10-01.csv"},
+			Options: ReaderOptions{
+				ReaderConfig: ReaderConfig{HasHeader: true, Schema: &amount},
+			},
+		})
+
+		_, err := readMulti(reader)
+		assert.EqualError(t, err,
+			"Not found required columns at line 1: amount in testdata/multi/sales_2026-10-01.csv")
+	})
+}
@@ -2,9 +2,11 @@ package file
 
 import (
 	"context"
+	"encoding/csv"
 	"fmt"
 	"github.com/yackrru/wolfx/middleware"
-	"reflect"
+	"github.com/yackrru/wolfx/tracing"
+	"io"
 	"sort"
 )
 
@@ -15,6 +17,12 @@ var _ middleware.Writer = new(Writer)
 // and is used to write csv format files.
 type Writer struct {
 	conf *WriterConfig
+
+	// closer is the file created by CreateWriter.
+	closer io.Closer
+
+	// atomic is the file created by CreateWriter with WriterOptions.Atomic.
+	atomic *AtomicFile
 }
 
 // WriterConfig is the configuration of Writer.
@@ -27,6 +35,20 @@ type WriterConfig struct {
 
 	// If NoHeader is true, Writer firstly outputs header string to csv.
 	NoHeader bool
+
+	// HeaderFunc returns the header record written firstly
+	// before the column header, e.g. the file date and the batch ID.
+	HeaderFunc func(ctx context.Context) ([]string, error)
+
+	// TrailerFunc returns the trailer record written lastly.
+	// Arg totals are the control totals of the items written
+	// with the sums of SumColumns.
+	TrailerFunc func(ctx context.Context, totals *ControlTotals) ([]string, error)
+
+	// SumColumns are the columns summed exactly as decimals, e.g. "-12.34",
+	// for TrailerFunc.
+	// They must be in PropsBindPosition and the missing values are regarded as 0.
+	SumColumns []string
 }
 
 // CSVWriter is the interface that wraps methods Write and WriteAll.
@@ -45,30 +67,232 @@ func NewWriter(conf *WriterConfig) *Writer {
 	}
 }
 
-func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) error {
+// WriterOptions is the options of CreateWriter.
+type WriterOptions struct {
+	// WriterConfig is the configuration of Writer.
+	// Its Writer is set by CreateWriter.
+	WriterConfig
+
+	// Compression is the compression format of the file.
+	// If specify CompressionAuto, it is detected from the extension.
+	Compression Compression
+
+	// Comma is the field delimiter of csv.Writer.
+	// If specify 0, a comma is used.
+	Comma rune
+
+	// If UseCRLF is true, csv.Writer uses \r\n as the line terminator.
+	UseCRLF bool
+
+	// Encoding is the character encoding of the file,
+	// e.g. "Shift_JIS" and "windows-1252". See LookupEncoding.
+	// If it is empty, the file is written in UTF-8.
+	Encoding string
+
+	// If BOM is true, the BOM is written at the start of the file,
+	// e.g. for Excel to read UTF-8.
+	BOM bool
+
+	// Atomic is the options of the atomic output.
+	// If it is not nil, Writer writes to a temp file in the same directory
+	// and renames it to path only when the step completes successfully.
+	// The temp file is deleted when the step fails.
+	Atomic *AtomicOptions
+}
+
+// CreateWriter creates the csv file of path and returns the Writer of it.
+// The file is flushed and closed when Write returns.
+func CreateWriter(path string, opts *WriterOptions) (*Writer, error) {
+	if opts == nil {
+		opts = new(WriterOptions)
+	}
+	if _, err := LookupEncoding(opts.Encoding); err != nil {
+		return nil, err
+	}
+	var (
+		wc     io.WriteCloser
+		atomic *AtomicFile
+		err    error
+	)
+	if opts.Atomic == nil {
+		wc, err = Create(path, opts.Compression)
+	} else {
+		wc, atomic, err = createAtomic(path, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ew, err := NewEncodeWriter(wc, opts.Encoding, opts.BOM)
+	if err != nil {
+		wc.Close()
+		if atomic != nil {
+			atomic.Abort()
+		}
+		return nil, err
+	}
+
+	csvWriter := csv.NewWriter(ew)
+	if opts.Comma != 0 {
+		csvWriter.Comma = opts.Comma
+	}
+	csvWriter.UseCRLF = opts.UseCRLF
+	conf := opts.WriterConfig
+	conf.Writer = csvWriter
+
+	return &Writer{
+		conf:   &conf,
+		closer: ew,
+		atomic: atomic,
+	}, nil
+}
+
+func createAtomic(path string, opts *WriterOptions) (io.WriteCloser, *AtomicFile, error) {
+	c := opts.Compression
+	if c == CompressionAuto {
+		c = CompressionFromExt(path)
+	}
+	if c == CompressionBzip2 {
+		return nil, nil, ErrNotSupportedCompression
+	}
+	atomic, err := CreateAtomic(path, opts.Atomic)
+	if err != nil {
+		return nil, nil, err
+	}
+	wc, err := NewCompressWriter(atomic, c)
+	if err != nil {
+		atomic.Abort()
+		return nil, nil, err
+	}
+	return wc, atomic, nil
+}
+
+// Close closes the file created by CreateWriter.
+// It is needed only if Write is not called.
+// The temp file of the atomic output is deleted.
+func (w *Writer) Close() error {
+	err := w.closeFile()
+	if w.atomic != nil {
+		if errAbort := w.atomic.Abort(); err == nil {
+			err = errAbort
+		}
+	}
+	return err
+}
+
+// closeFile flushes the records buffered by the CSVWriter,
+// e.g. the header without items, and closes the file.
+func (w *Writer) closeFile() error {
+	if w.closer == nil {
+		return nil
+	}
+	w.conf.Writer.Flush()
+	err := w.conf.Writer.Error()
+	if errClose := w.closer.Close(); err == nil {
+		err = errClose
+	}
+	w.closer = nil
+	return err
+}
+
+// finishAtomic commits the atomic output when the step completes successfully.
+// If ctx has no step, it commits at once.
+// If committed is not nil, it is called after the commit.
+func finishAtomic(ctx context.Context, atomic *AtomicFile, err error, committed func() error) error {
+	if err != nil {
+		atomic.Abort()
+		return err
+	}
+	commit := func() error {
+		if err := atomic.Commit(); err != nil {
+			return err
+		}
+		if committed != nil {
+			return committed()
+		}
+		return nil
+	}
+	registered := middleware.OnStepEnd(ctx, func(stepErr error) error {
+		if stepErr != nil {
+			return atomic.Abort()
+		}
+		return commit()
+	})
+	if !registered {
+		return commit()
+	}
+	return nil
+}
+
+func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) (err error) {
+	defer func() {
+		if errClose := w.closeFile(); err == nil {
+			err = errClose
+		}
+		if w.atomic != nil {
+			err = finishAtomic(ctx, w.atomic, err, nil)
+		}
+	}()
+
 	writer := w.conf.Writer
 
+	sums := newControlSums(w.conf.SumColumns)
+	values := make([]string, len(w.conf.SumColumns))
+	for _, col := range w.conf.SumColumns {
+		if _, ok := w.conf.PropsBindPosition[col]; !ok {
+			return fmt.Errorf("Not found %s in PropsBindPosition", col)
+		}
+	}
+
+	// line is the line number of the last record written.
+	var line int
+	if w.conf.HeaderFunc != nil {
+		record, err := w.conf.HeaderFunc(ctx)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		line++
+	}
+
 	if !w.conf.NoHeader {
 		header := generateHeader(w.conf.PropsBindPosition)
 		if err := writer.Write(header); err != nil {
 			return err
 		}
+		line++
 	}
 
+	var count int64
 	for chunk := range ch {
-		var items [][]string
-		switch chunk.(type) {
-		case []middleware.MapMapperType:
-			items = middleware.MapMapperToFlatItems(chunk.([]middleware.MapMapperType),
-				w.conf.PropsBindPosition)
-		case []middleware.CustomMapperType:
-			items = middleware.CustomMapperToFlatItems(chunk.([]middleware.CustomMapperType),
-				w.conf.PropsBindPosition)
-		default:
-			v := reflect.ValueOf(chunk)
-			return fmt.Errorf("Not supported such a chunk type: %s", v.Type())
-		}
-		if err := writer.WriteAll(items); err != nil {
+		ctx := middleware.NextChunkContext(ctx)
+		mapMapperChunk, err := middleware.ToMapMapperChunk(chunk)
+		if err != nil {
+			return err
+		}
+		items := middleware.MapMapperToFlatItems(mapMapperChunk, w.conf.PropsBindPosition)
+		if err := w.flush(ctx, items); err != nil {
+			return err
+		}
+		for _, item := range mapMapperChunk {
+			count++
+			line++
+			for i, col := range w.conf.SumColumns {
+				values[i] = item[col]
+			}
+			if err := sums.add(values, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w.conf.TrailerFunc != nil {
+		record, err := w.conf.TrailerFunc(ctx, sums.totals(count))
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(record); err != nil {
 			return err
 		}
 		writer.Flush()
@@ -80,6 +304,25 @@ func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) error {
 	return nil
 }
 
+func (w *Writer) flush(ctx context.Context, items [][]string) error {
+	_, span := tracing.StartSpan(ctx, "file.Writer.Flush",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+	defer span.End()
+
+	writer := w.conf.Writer
+	if err := writer.WriteAll(items); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
 func generateHeader(propsBindPosition map[string]uint) []string {
 	reverted := make(map[int]string, len(propsBindPosition))
 
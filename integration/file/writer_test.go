@@ -2,8 +2,12 @@ package file
 
 import (
 	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 )
@@ -213,3 +217,95 @@ func TestGenerateHeader(t *testing.T) {
 		assert.Equal(t, int(propsBindPosition[property]), idx)
 	}
 }
+
+func TestCreateWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv.gz")
+	writer, err := CreateWriter(path, &WriterOptions{
+		WriterConfig: WriterConfig{
+			PropsBindPosition: middleware.PropsBindPosition{"id": 0, "name": 1},
+		},
+		Comma: ';',
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 1)
+	ch <- []middleware.MapMapperType{{"id": "0", "name": "name0"}}
+	close(ch)
+	assert.NoError(t, writer.Write(context.TODO(), ch))
+
+	r, err := Open(path, CompressionAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	assert.Equal(t, CompressionGzip, CompressionOf(r))
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "id;name\n0;name0\n", string(got))
+}
+
+func TestCreateWriterWithEmptyInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	writer, err := CreateWriter(path, &WriterOptions{
+		WriterConfig: WriterConfig{
+			PropsBindPosition: middleware.PropsBindPosition{"id": 0, "name": 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{})
+	close(ch)
+	assert.NoError(t, writer.Write(context.TODO(), ch))
+
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name\n", string(got))
+}
+
+func TestCreateWriterAtomic(t *testing.T) {
+	write := func(t *testing.T, stepErr error) (string, *Writer) {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		writer, err := CreateWriter(path, &WriterOptions{
+			WriterConfig: WriterConfig{
+				PropsBindPosition: middleware.PropsBindPosition{"id": 0},
+			},
+			Atomic: &AtomicOptions{DoneMarker: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		se := middleware.NewStepExecution(nil, "WriteStep")
+		ctx := middleware.WithStepExecution(context.TODO(), se)
+		ch := make(chan interface{}, 1)
+		ch <- []middleware.MapMapperType{{"id": "0"}}
+		close(ch)
+		assert.NoError(t, writer.Write(ctx, ch))
+
+		// The output is published when the step ends.
+		assert.NoFileExists(t, path)
+		assert.FileExists(t, writer.atomic.TempName())
+		se.Finish(stepErr)
+		return path, writer
+	}
+
+	t.Run("Step completed", func(t *testing.T) {
+		path, writer := write(t, nil)
+		assert.NoFileExists(t, writer.atomic.TempName())
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "id\n0\n", string(b))
+		assert.FileExists(t, path+DoneMarkerSuffix)
+	})
+
+	t.Run("Step failed", func(t *testing.T) {
+		path, writer := write(t, fmt.Errorf("Reader Error"))
+		assert.NoFileExists(t, writer.atomic.TempName())
+		assert.NoFileExists(t, path)
+		assert.NoFileExists(t, path+DoneMarkerSuffix)
+	})
+}
@@ -0,0 +1,178 @@
+package jsonl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var _ middleware.Reader = new(ArrayReader)
+
+// ArrayReader is an implementation of middleware.Reader.
+// It is used to read the elements of a JSON array as a stream of tokens,
+// so that a huge document is not loaded into memory at once.
+// The elements are sent to channel in the same way as Reader.
+type ArrayReader struct {
+	conf  *ArrayReaderConfig
+	items *Reader
+}
+
+// ArrayReaderConfig is the configuration of ArrayReader.
+type ArrayReaderConfig struct {
+	Reader io.Reader
+
+	// Path is the dotted keys to the array from the root object,
+	// e.g. "data.records".
+	// If it is empty, the root of the document must be the array.
+	Path string
+
+	// ChunkSize is the number of elements to be read at once.
+	// If specify 0, ArrayReader will read all elements at once.
+	ChunkSize uint
+
+	// ItemType is the struct type each element is decoded into.
+	// See ReaderConfig.ItemType.
+	ItemType reflect.Type
+
+	// If Flatten is true, nested objects and arrays are flattened
+	// to dotted keys. See ReaderConfig.Flatten.
+	Flatten bool
+
+	// Size is the size of the file in bytes.
+	// If it is greater than 0, ArrayReader reports it as the total
+	// of the step so that the completion time can be estimated.
+	Size int64
+
+	// RowMapperFunc is the mapping function.
+	// If it is nil, ArrayReader will send data as the type
+	// of MapMapperType to channel.
+	// If not nil, ArrayReader will send data as the type that user defined.
+	RowMapperFunc middleware.RowMapper
+}
+
+func NewArrayReader(conf *ArrayReaderConfig) *ArrayReader {
+	return &ArrayReader{
+		conf: conf,
+		items: NewReader(&ReaderConfig{
+			ChunkSize:     conf.ChunkSize,
+			ItemType:      conf.ItemType,
+			Flatten:       conf.Flatten,
+			Size:          conf.Size,
+			RowMapperFunc: conf.RowMapperFunc,
+		}),
+	}
+}
+
+func (r *ArrayReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressTotal(ctx, uint64(r.conf.Size), middleware.ProgressBytes)
+	}
+
+	dec := json.NewDecoder(r.conf.Reader)
+	if err := seekArray(dec, r.conf.Path); err != nil {
+		return err
+	}
+
+	chunk := r.items.newChunk()
+	for idx := 0; dec.More(); idx++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("Failed to decode element %d: %w", idx, err)
+		}
+		item, err := r.items.decode(raw)
+		if err != nil {
+			return fmt.Errorf("Failed to decode element %d: %w", idx, err)
+		}
+		chunk = reflect.Append(chunk, item)
+
+		if r.conf.ChunkSize > 0 && chunk.Len() >= int(r.conf.ChunkSize) {
+			if err := r.items.sendChunk(ctx, ch, chunk, dec.InputOffset()); err != nil {
+				return err
+			}
+			chunk = r.items.newChunk()
+		}
+	}
+	// Consume the end of the array.
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if chunk.Len() != 0 || r.conf.ChunkSize == 0 {
+		if err := r.items.sendChunk(ctx, ch, chunk, dec.InputOffset()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seekArray advances dec to the first element of the array at path.
+func seekArray(dec *json.Decoder, path string) error {
+	var keys []string
+	if path != "" {
+		keys = strings.Split(path, ".")
+	}
+
+	for _, key := range keys {
+		if err := expectDelim(dec, '{', path); err != nil {
+			return err
+		}
+		for {
+			if !dec.More() {
+				return fmt.Errorf("Not found JSON path: %s", path)
+			}
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if t == key {
+				break
+			}
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+		}
+	}
+
+	return expectDelim(dec, '[', path)
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim, path string) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		if delim == '[' {
+			return fmt.Errorf("Not an array at JSON path: %s", path)
+		}
+		return fmt.Errorf("Not found JSON path: %s", path)
+	}
+	return nil
+}
+
+// skipValue skips the next value by tokens without holding it in memory.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package jsonl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readArray(conf *ArrayReaderConfig) ([]interface{}, error) {
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewArrayReader(conf).Read(context.TODO(), ch)
+	}()
+	var chunks []interface{}
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, <-errCh
+}
+
+func openOrders(t *testing.T) io.Reader {
+	file, err := os.Open("testdata/orders.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestArrayReader(t *testing.T) {
+	t.Run("Flatten at JSON path", func(t *testing.T) {
+		chunks, err := readArray(&ArrayReaderConfig{
+			Reader:    openOrders(t),
+			Path:      "data.records",
+			ChunkSize: 2,
+			Flatten:   true,
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, chunks, 2)
+		assert.Len(t, chunks[0], 2)
+		assert.Equal(t, middleware.MapMapperType{
+			"id":            "3",
+			"customer.name": "Emma",
+			"customer.city": "Osaka",
+			"tags.0":        "vip",
+			"paid":          "true",
+		}, chunks[1].([]middleware.MapMapperType)[0])
+	})
+
+	t.Run("Typed structs", func(t *testing.T) {
+		chunks, err := readArray(&ArrayReaderConfig{
+			Reader:   openOrders(t),
+			Path:     "data.records",
+			ItemType: reflect.TypeOf(Order{}),
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, chunks, 1)
+		orders := chunks[0].([]Order)
+		assert.Len(t, orders, 3)
+		assert.Equal(t, Customer{Name: "Emma", City: "Osaka"}, orders[2].Customer)
+	})
+
+	t.Run("Root array", func(t *testing.T) {
+		chunks, err := readArray(&ArrayReaderConfig{
+			Reader: strings.NewReader(`[{"id":1},{"id":2}]`),
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			[]middleware.MapMapperType{{"id": "1"}, {"id": "2"}},
+		}, chunks)
+	})
+
+	t.Run("Not found JSON path", func(t *testing.T) {
+		_, err := readArray(&ArrayReaderConfig{
+			Reader: openOrders(t),
+			Path:   "data.items",
+		})
+		assert.EqualError(t, err, "Not found JSON path: data.items")
+
+		_, err = readArray(&ArrayReaderConfig{
+			Reader: openOrders(t),
+			Path:   "meta.count",
+		})
+		assert.EqualError(t, err, "Not an array at JSON path: meta.count")
+	})
+}
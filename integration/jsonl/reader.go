@@ -0,0 +1,241 @@
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+var _ middleware.Reader = new(Reader)
+
+// Reader is an implementation of middleware.Reader.
+// It is used to read JSON Lines (NDJSON) format files,
+// which have one JSON object per line.
+type Reader struct {
+	conf *ReaderConfig
+}
+
+// ReaderConfig is the configuration of Reader.
+type ReaderConfig struct {
+	Reader io.Reader
+
+	// ChunkSize is the number of lines to be read at once.
+	// If specify 0, Reader will read all lines of file at once.
+	ChunkSize uint
+
+	// ItemType is the struct type each line is decoded into,
+	// e.g. reflect.TypeOf(Order{}).
+	// If it is not nil, Reader will send the chunk as the slice of ItemType
+	// keeping nested objects as typed structs,
+	// and Flatten and RowMapperFunc are ignored.
+	ItemType reflect.Type
+
+	// If Flatten is true, nested objects and arrays are flattened
+	// to dotted keys such as "address.city" and "tags.0".
+	// The empty ones are kept as "{}" and "[]".
+	// If false, they are kept as JSON strings.
+	Flatten bool
+
+	// Size is the size of the file in bytes.
+	// If it is greater than 0, Reader reports it as the total
+	// of the step so that the completion time can be estimated.
+	Size int64
+
+	// RowMapperFunc is the mapping function.
+	// If it is nil, Reader will send data as the type
+	// of MapMapperType to channel.
+	// If not nil, Reader will send data as the type that user defined.
+	RowMapperFunc middleware.RowMapper
+}
+
+func NewReader(conf *ReaderConfig) *Reader {
+	return &Reader{
+		conf: conf,
+	}
+}
+
+func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressTotal(ctx, uint64(r.conf.Size), middleware.ProgressBytes)
+	}
+
+	reader := bufio.NewReader(r.conf.Reader)
+	var (
+		offset int64
+		lineNo int
+	)
+	chunk := r.newChunk()
+	for {
+		line, err := reader.ReadBytes('\n')
+		offset += int64(len(line))
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		lineNo++
+
+		if line = bytes.TrimSpace(line); len(line) != 0 {
+			item, errDecode := r.decode(line)
+			if errDecode != nil {
+				return fmt.Errorf("Failed to decode line %d: %w", lineNo, errDecode)
+			}
+			chunk = reflect.Append(chunk, item)
+		}
+
+		if r.conf.ChunkSize > 0 && chunk.Len() >= int(r.conf.ChunkSize) {
+			if err := r.sendChunk(ctx, ch, chunk, offset); err != nil {
+				return err
+			}
+			chunk = r.newChunk()
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if chunk.Len() != 0 || r.conf.ChunkSize == 0 {
+		if err := r.sendChunk(ctx, ch, chunk, offset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) newChunk() reflect.Value {
+	if r.conf.ItemType != nil {
+		return reflect.MakeSlice(reflect.SliceOf(r.conf.ItemType), 0, int(r.conf.ChunkSize))
+	}
+	return reflect.ValueOf(make([]middleware.MapMapperType, 0, r.conf.ChunkSize))
+}
+
+// decode decodes line of a JSON object.
+// The other values including null and the trailing data are errors.
+func (r *Reader) decode(line []byte) (reflect.Value, error) {
+	if line[0] != '{' {
+		return reflect.Value{}, fmt.Errorf("Not supported JSON value other than object: %s", line)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	var (
+		item reflect.Value
+		obj  map[string]interface{}
+	)
+	if r.conf.ItemType != nil {
+		item = reflect.New(r.conf.ItemType)
+	} else {
+		dec.UseNumber()
+		item = reflect.ValueOf(&obj)
+	}
+	if err := dec.Decode(item.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return reflect.Value{}, fmt.Errorf("Invalid data after JSON value at offset %d", dec.InputOffset())
+	}
+	if r.conf.ItemType != nil {
+		return item.Elem(), nil
+	}
+
+	resultSet := make(middleware.MapMapperType, len(obj))
+	for k, v := range obj {
+		if r.conf.Flatten {
+			if err := flatten(resultSet, k, v); err != nil {
+				return reflect.Value{}, err
+			}
+			continue
+		}
+		val, err := toString(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		resultSet[k] = val
+	}
+	return reflect.ValueOf(resultSet), nil
+}
+
+func (r *Reader) sendChunk(ctx context.Context, ch chan<- interface{},
+	chunk reflect.Value, offset int64) error {
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressPosition(ctx, uint64(offset))
+	}
+
+	ctx = middleware.NextChunkContext(ctx)
+	if r.conf.ItemType != nil || r.conf.RowMapperFunc == nil {
+		ch <- chunk.Interface()
+		return nil
+	}
+
+	items := chunk.Interface().([]middleware.MapMapperType)
+	ctx, span := tracing.StartSpan(ctx, "RowMapper",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+	err := r.conf.RowMapperFunc(ctx, ch, items)
+	span.RecordError(err)
+	span.End()
+	return err
+}
+
+// flatten sets v to resultSet with the dotted keys of nested objects and arrays.
+// The empty objects and arrays are set as "{}" and "[]".
+func flatten(resultSet middleware.MapMapperType, key string, v interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			resultSet[key] = "{}"
+			return nil
+		}
+		for k, child := range val {
+			if err := flatten(resultSet, key+"."+k, child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			resultSet[key] = "[]"
+			return nil
+		}
+		for i, child := range val {
+			if err := flatten(resultSet, key+"."+strconv.Itoa(i), child); err != nil {
+				return err
+			}
+		}
+	default:
+		str, err := toString(val)
+		if err != nil {
+			return err
+		}
+		resultSet[key] = str
+	}
+	return nil
+}
+
+// toString converts a decoded JSON value to string.
+// Strings are returned as they are, null is an empty string
+// and the others are JSON texts.
+func toString(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default:
+		b, err := json.Marshal(val)
+		return string(b), err
+	}
+}
@@ -0,0 +1,174 @@
+package jsonl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Order struct {
+	Id       int      `json:"id"`
+	Customer Customer `json:"customer"`
+	Tags     []string `json:"tags"`
+	Paid     bool     `json:"paid"`
+}
+
+type Customer struct {
+	Name string `json:"name"`
+	City string `json:"city"`
+}
+
+func readChunks(t *testing.T, conf *ReaderConfig) []interface{} {
+	file, err := os.Open("testdata/orders.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	conf.Reader = file
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewReader(conf).Read(context.TODO(), ch)
+	}()
+	var chunks []interface{}
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	assert.NoError(t, <-errCh)
+	return chunks
+}
+
+func TestReader(t *testing.T) {
+	t.Run("MapMapperType without ChunkSize", func(t *testing.T) {
+		chunks := readChunks(t, &ReaderConfig{})
+
+		assert.Len(t, chunks, 1)
+		chunk := chunks[0].([]middleware.MapMapperType)
+		assert.Len(t, chunk, 3)
+		assert.Equal(t, middleware.MapMapperType{
+			"id":       "1",
+			"customer": `{"city":"Tokyo","name":"John"}`,
+			"tags":     `["new","vip"]`,
+			"paid":     "true",
+		}, chunk[0])
+	})
+
+	t.Run("Flatten with ChunkSize", func(t *testing.T) {
+		chunks := readChunks(t, &ReaderConfig{
+			ChunkSize: 2,
+			Flatten:   true,
+		})
+
+		assert.Len(t, chunks, 2)
+		assert.Len(t, chunks[0], 2)
+		assert.Len(t, chunks[1], 1)
+		assert.Equal(t, middleware.MapMapperType{
+			"id":            "1",
+			"customer.name": "John",
+			"customer.city": "Tokyo",
+			"tags.0":        "new",
+			"tags.1":        "vip",
+			"paid":          "true",
+		}, chunks[0].([]middleware.MapMapperType)[0])
+		assert.Equal(t, middleware.MapMapperType{
+			"id":            "2",
+			"customer.name": "Andy",
+			"customer.city": "",
+			"tags":          "[]",
+			"paid":          "false",
+		}, chunks[0].([]middleware.MapMapperType)[1])
+	})
+
+	t.Run("Flatten empty objects and arrays", func(t *testing.T) {
+		reader := NewReader(&ReaderConfig{
+			Reader:  strings.NewReader(`{"id":1,"customer":{},"tags":[]}` + "\n"),
+			Flatten: true,
+		})
+		ch := make(chan interface{}, 1)
+		assert.NoError(t, reader.Read(context.TODO(), ch))
+		assert.Equal(t, []middleware.MapMapperType{
+			{"id": "1", "customer": "{}", "tags": "[]"},
+		}, <-ch)
+	})
+
+	t.Run("Typed structs", func(t *testing.T) {
+		chunks := readChunks(t, &ReaderConfig{
+			ChunkSize: 2,
+			ItemType:  reflect.TypeOf(Order{}),
+		})
+
+		assert.Equal(t, []interface{}{
+			[]Order{
+				{Id: 1, Customer: Customer{Name: "John", City: "Tokyo"},
+					Tags: []string{"new", "vip"}, Paid: true},
+				{Id: 2, Customer: Customer{Name: "Andy"}, Tags: []string{}},
+			},
+			[]Order{
+				{Id: 3, Customer: Customer{Name: "Emma", City: "Osaka"},
+					Tags: []string{"vip"}, Paid: true},
+			},
+		}, chunks)
+	})
+
+	t.Run("RowMapper", func(t *testing.T) {
+		chunks := readChunks(t, &ReaderConfig{
+			RowMapperFunc: func(ctx context.Context, ch chan<- interface{},
+				chunk []middleware.MapMapperType) error {
+
+				var ids []string
+				for _, item := range chunk {
+					ids = append(ids, item["id"])
+				}
+				ch <- ids
+				return nil
+			},
+		})
+
+		assert.Equal(t, []interface{}{[]string{"1", "2", "3"}}, chunks)
+	})
+}
+
+func TestReaderDecodeError(t *testing.T) {
+	reader := NewReader(&ReaderConfig{
+		Reader: strings.NewReader("{\"id\":1}\n{\"id\":2\n"),
+	})
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+	for range ch {
+	}
+	assert.EqualError(t, <-errCh, "Failed to decode line 2: unexpected EOF")
+
+	for _, tt := range []struct {
+		line string
+		err  string
+	}{
+		{`{"id":1}xyz`, "Failed to decode line 1: Invalid data after JSON value at offset 8"},
+		{`{"id":1}}`, "Failed to decode line 1: Invalid data after JSON value at offset 8"},
+		{`null`, "Failed to decode line 1: Not supported JSON value other than object: null"},
+		{`[1]`, "Failed to decode line 1: Not supported JSON value other than object: [1]"},
+	} {
+		for _, itemType := range []reflect.Type{nil, reflect.TypeOf(struct{ ID int }{})} {
+			reader := NewReader(&ReaderConfig{
+				Reader:   strings.NewReader(tt.line + "\n"),
+				ItemType: itemType,
+			})
+			ch := make(chan interface{})
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- reader.Read(context.TODO(), ch)
+			}()
+			for range ch {
+			}
+			assert.EqualError(t, <-errCh, tt.err)
+		}
+	}
+}
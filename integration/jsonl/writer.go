@@ -0,0 +1,135 @@
+package jsonl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var _ middleware.Writer = new(Writer)
+
+// Writer is an implementation of middleware.Writer.
+// It is used to write JSON Lines (NDJSON) format files.
+//
+// Chunks of MapMapperType and CustomMapperType are written as objects
+// of string values. Chunks of any other slice are written by encoding/json,
+// so that typed structs keep their nested objects.
+type Writer struct {
+	conf *WriterConfig
+}
+
+// WriterConfig is the configuration of Writer.
+type WriterConfig struct {
+	Writer io.Writer
+
+	// If Unflatten is true, dotted keys of MapMapperType such as
+	// "address.city" are written as nested objects.
+	Unflatten bool
+}
+
+func NewWriter(conf *WriterConfig) *Writer {
+	return &Writer{
+		conf: conf,
+	}
+}
+
+func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) error {
+	writer := bufio.NewWriter(w.conf.Writer)
+
+	for chunk := range ch {
+		ctx := middleware.NextChunkContext(ctx)
+		var items []interface{}
+		switch chunk.(type) {
+		case []middleware.MapMapperType, []middleware.CustomMapperType:
+			mapMapperChunk, err := middleware.ToMapMapperChunk(chunk)
+			if err != nil {
+				return err
+			}
+			for _, item := range mapMapperChunk {
+				if w.conf.Unflatten {
+					items = append(items, unflatten(item))
+				} else {
+					items = append(items, item)
+				}
+			}
+		default:
+			v := reflect.ValueOf(chunk)
+			if v.Kind() != reflect.Slice {
+				return fmt.Errorf("Not supported such a chunk type: %s", v.Type())
+			}
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, v.Index(i).Interface())
+			}
+		}
+		if err := w.flush(ctx, writer, items); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) flush(ctx context.Context, writer *bufio.Writer, items []interface{}) error {
+	_, span := tracing.StartSpan(ctx, "jsonl.Writer.Flush",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+	defer span.End()
+
+	// Encoder terminates each value with a newline.
+	enc := json.NewEncoder(writer)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	return nil
+}
+
+// unflatten converts dotted keys to nested objects.
+// Flattened arrays become objects keyed by the indexes.
+// If a key conflicts with the value of its parent key, the value is
+// kept with the dotted key.
+func unflatten(item middleware.MapMapperType) map[string]interface{} {
+	keys := make([]string, 0, len(item))
+	for k := range item {
+		keys = append(keys, k)
+	}
+	// Parents precede their children.
+	sort.Strings(keys)
+
+	obj := make(map[string]interface{}, len(item))
+	for _, k := range keys {
+		v := item[k]
+		parts := strings.Split(k, ".")
+		parent := obj
+		ok := true
+		for _, p := range parts[:len(parts)-1] {
+			child, exists := parent[p]
+			if !exists {
+				child = make(map[string]interface{})
+				parent[p] = child
+			}
+			if parent, ok = child.(map[string]interface{}); !ok {
+				break
+			}
+		}
+		if ok {
+			parent[parts[len(parts)-1]] = v
+		} else {
+			obj[k] = v
+		}
+	}
+	return obj
+}
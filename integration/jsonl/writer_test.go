@@ -0,0 +1,66 @@
+package jsonl
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"testing"
+)
+
+type TestChunkType struct {
+	Id   string `prop:"id"`
+	Name string `prop:"name"`
+}
+
+func writeChunks(t *testing.T, conf *WriterConfig, chunks ...interface{}) string {
+	var buf bytes.Buffer
+	conf.Writer = &buf
+
+	ch := make(chan interface{}, len(chunks))
+	for _, chunk := range chunks {
+		ch <- chunk
+	}
+	close(ch)
+	if err := NewWriter(conf).Write(context.TODO(), ch); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("MapMapperType and CustomMapperType", func(t *testing.T) {
+		out := writeChunks(t, &WriterConfig{},
+			[]middleware.MapMapperType{{"id": "0", "name": "name0"}},
+			[]middleware.CustomMapperType{{Props: TestChunkType{Id: "1", Name: "name1"}}})
+
+		assert.Equal(t, `{"id":"0","name":"name0"}`+"\n"+
+			`{"id":"1","name":"name1"}`+"\n", out)
+	})
+
+	t.Run("Unflatten", func(t *testing.T) {
+		out := writeChunks(t, &WriterConfig{Unflatten: true},
+			[]middleware.MapMapperType{{
+				"id":            "1",
+				"customer.name": "John",
+				"customer.city": "Tokyo",
+			}})
+
+		assert.Equal(t, `{"customer":{"city":"Tokyo","name":"John"},"id":"1"}`+"\n", out)
+	})
+
+	t.Run("Typed structs", func(t *testing.T) {
+		out := writeChunks(t, &WriterConfig{},
+			[]Order{{Id: 1, Customer: Customer{Name: "John"}, Tags: []string{"vip"}}})
+
+		assert.Equal(t, `{"id":1,"customer":{"name":"John","city":""},"tags":["vip"],"paid":false}`+"\n", out)
+	})
+
+	t.Run("Not supported chunk type", func(t *testing.T) {
+		ch := make(chan interface{}, 1)
+		ch <- "foo"
+		close(ch)
+		err := NewWriter(&WriterConfig{Writer: new(bytes.Buffer)}).Write(context.TODO(), ch)
+		assert.EqualError(t, err, "Not supported such a chunk type: string")
+	})
+}
@@ -0,0 +1,189 @@
+package xml
+
+import (
+	"context"
+	"encoding/xml"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var _ middleware.Reader = new(Reader)
+
+// Reader is an implementation of middleware.Reader.
+// It is used to read the repeated elements of a XML document
+// as a stream of tokens, so that a huge document is not loaded
+// into memory at once.
+type Reader struct {
+	conf *ReaderConfig
+}
+
+// ReaderConfig is the configuration of Reader.
+type ReaderConfig struct {
+	Reader io.Reader
+
+	// Element is the local name of the repeated element, e.g. "record".
+	Element string
+
+	// ChunkSize is the number of elements to be read at once.
+	// If specify 0, Reader will read all elements at once.
+	ChunkSize uint
+
+	// ItemType is the struct type each element is decoded into
+	// by encoding/xml, e.g. reflect.TypeOf(Record{}).
+	// If it is not nil, Reader will send the chunk as the slice of ItemType
+	// and RowMapperFunc is ignored.
+	//
+	// If it is nil, the element is mapped to MapMapperType.
+	// The texts of the child elements are keyed by their dotted paths
+	// such as "address.city", attributes by "@" and their names
+	// such as "@id", and repeated children by their indexes such as "tag.0".
+	ItemType reflect.Type
+
+	// Size is the size of the file in bytes.
+	// If it is greater than 0, Reader reports it as the total
+	// of the step so that the completion time can be estimated.
+	Size int64
+
+	// RowMapperFunc is the mapping function.
+	// If it is nil, Reader will send data as the type
+	// of MapMapperType to channel.
+	// If not nil, Reader will send data as the type that user defined.
+	RowMapperFunc middleware.RowMapper
+}
+
+func NewReader(conf *ReaderConfig) *Reader {
+	return &Reader{
+		conf: conf,
+	}
+}
+
+// node is the generic form of an element.
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []node     `xml:",any"`
+}
+
+func (r *Reader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressTotal(ctx, uint64(r.conf.Size), middleware.ProgressBytes)
+	}
+
+	dec := xml.NewDecoder(r.conf.Reader)
+	chunk := r.newChunk()
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok || start.Name.Local != r.conf.Element {
+			continue
+		}
+
+		item, err := r.decode(dec, &start)
+		if err != nil {
+			return err
+		}
+		chunk = reflect.Append(chunk, item)
+
+		if r.conf.ChunkSize > 0 && chunk.Len() >= int(r.conf.ChunkSize) {
+			if err := r.sendChunk(ctx, ch, chunk, dec.InputOffset()); err != nil {
+				return err
+			}
+			chunk = r.newChunk()
+		}
+	}
+
+	if chunk.Len() != 0 || r.conf.ChunkSize == 0 {
+		if err := r.sendChunk(ctx, ch, chunk, dec.InputOffset()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) newChunk() reflect.Value {
+	if r.conf.ItemType != nil {
+		return reflect.MakeSlice(reflect.SliceOf(r.conf.ItemType), 0, int(r.conf.ChunkSize))
+	}
+	return reflect.ValueOf(make([]middleware.MapMapperType, 0, r.conf.ChunkSize))
+}
+
+func (r *Reader) decode(dec *xml.Decoder, start *xml.StartElement) (reflect.Value, error) {
+	if r.conf.ItemType != nil {
+		item := reflect.New(r.conf.ItemType)
+		if err := dec.DecodeElement(item.Interface(), start); err != nil {
+			return reflect.Value{}, err
+		}
+		return item.Elem(), nil
+	}
+
+	var n node
+	if err := dec.DecodeElement(&n, start); err != nil {
+		return reflect.Value{}, err
+	}
+	resultSet := make(middleware.MapMapperType)
+	flatten(resultSet, "", &n)
+	return reflect.ValueOf(resultSet), nil
+}
+
+func (r *Reader) sendChunk(ctx context.Context, ch chan<- interface{},
+	chunk reflect.Value, offset int64) error {
+
+	if r.conf.Size > 0 {
+		middleware.SetProgressPosition(ctx, uint64(offset))
+	}
+
+	ctx = middleware.NextChunkContext(ctx)
+	if r.conf.ItemType != nil || r.conf.RowMapperFunc == nil {
+		ch <- chunk.Interface()
+		return nil
+	}
+
+	items := chunk.Interface().([]middleware.MapMapperType)
+	ctx, span := tracing.StartSpan(ctx, "RowMapper",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(len(items)))))
+	err := r.conf.RowMapperFunc(ctx, ch, items)
+	span.RecordError(err)
+	span.End()
+	return err
+}
+
+// flatten sets the attributes and the children of n to resultSet
+// with the keys prefixed by prefix.
+func flatten(resultSet middleware.MapMapperType, prefix string, n *node) {
+	for _, attr := range n.Attrs {
+		resultSet[prefix+"@"+attr.Name.Local] = attr.Value
+	}
+
+	counts := make(map[string]int)
+	for _, child := range n.Nodes {
+		counts[child.XMLName.Local]++
+	}
+	indexes := make(map[string]int)
+	for i := range n.Nodes {
+		child := &n.Nodes[i]
+		key := prefix + child.XMLName.Local
+		if counts[child.XMLName.Local] > 1 {
+			key += "." + strconv.Itoa(indexes[child.XMLName.Local])
+			indexes[child.XMLName.Local]++
+		}
+
+		if len(child.Nodes) == 0 {
+			resultSet[key] = strings.TrimSpace(child.Text)
+		}
+		flatten(resultSet, key+".", child)
+	}
+}
@@ -0,0 +1,107 @@
+package xml
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Order struct {
+	Id       string   `xml:"id,attr"`
+	Customer Customer `xml:"customer"`
+	Tags     []string `xml:"tag"`
+}
+
+type Customer struct {
+	Name string `xml:"name"`
+	City string `xml:"city"`
+}
+
+func readChunks(t *testing.T, conf *ReaderConfig) []interface{} {
+	file, err := os.Open("testdata/orders.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	conf.Reader = file
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewReader(conf).Read(context.TODO(), ch)
+	}()
+	var chunks []interface{}
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	assert.NoError(t, <-errCh)
+	return chunks
+}
+
+func TestReader(t *testing.T) {
+	t.Run("MapMapperType with ChunkSize", func(t *testing.T) {
+		chunks := readChunks(t, &ReaderConfig{
+			Element:   "record",
+			ChunkSize: 2,
+		})
+
+		assert.Len(t, chunks, 2)
+		assert.Equal(t, []middleware.MapMapperType{
+			{
+				"@id":           "1",
+				"customer.name": "John",
+				"customer.city": "Tokyo",
+				"tag.0":         "new",
+				"tag.1":         "vip",
+			},
+			{
+				"@id":           "2",
+				"customer.name": "Andy",
+				"customer.city": "",
+			},
+		}, chunks[0])
+		assert.Equal(t, []middleware.MapMapperType{
+			{
+				"@id":           "3",
+				"customer.name": "Emma",
+				"customer.city": "Osaka",
+				"tag":           "vip",
+			},
+		}, chunks[1])
+	})
+
+	t.Run("Typed structs", func(t *testing.T) {
+		chunks := readChunks(t, &ReaderConfig{
+			Element:  "record",
+			ItemType: reflect.TypeOf(Order{}),
+		})
+
+		assert.Equal(t, []interface{}{
+			[]Order{
+				{Id: "1", Customer: Customer{Name: "John", City: "Tokyo"}, Tags: []string{"new", "vip"}},
+				{Id: "2", Customer: Customer{Name: "Andy"}},
+				{Id: "3", Customer: Customer{Name: "Emma", City: "Osaka"}, Tags: []string{"vip"}},
+			},
+		}, chunks)
+	})
+}
+
+func TestReaderSyntaxError(t *testing.T) {
+	reader := NewReader(&ReaderConfig{
+		Reader:  strings.NewReader("<records><record><id>1</id></records>"),
+		Element: "record",
+	})
+
+	ch := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reader.Read(context.TODO(), ch)
+	}()
+	for range ch {
+	}
+	assert.Error(t, <-errCh)
+}
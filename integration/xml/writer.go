@@ -0,0 +1,170 @@
+package xml
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var _ middleware.Writer = new(Writer)
+
+const (
+	// DefaultRoot is the name of the root element when WriterConfig.Root is empty.
+	DefaultRoot = "records"
+
+	// DefaultElement is the name of the repeated element
+	// when WriterConfig.Element is empty.
+	DefaultElement = "record"
+)
+
+// Writer is an implementation of middleware.Writer.
+// It is used to write chunks as the repeated elements of a XML document.
+//
+// Items of MapMapperType and CustomMapperType are written as the elements
+// having a child element per key. Keys prefixed by "@" are written
+// as attributes. Items of any other slice are written by encoding/xml.
+type Writer struct {
+	conf *WriterConfig
+}
+
+// WriterConfig is the configuration of Writer.
+type WriterConfig struct {
+	Writer io.Writer
+
+	// Root is the name of the root element.
+	Root string
+
+	// Element is the name of the repeated element.
+	Element string
+
+	// PropsBindPosition is the order of the child elements.
+	// If it is nil, the child elements are sorted by their names.
+	PropsBindPosition middleware.PropsBindPosition
+
+	// Indent is the indent string of each nesting level.
+	// If it is empty, the document is written without indentation.
+	Indent string
+
+	// If NoDeclaration is true, Writer does not output the XML declaration.
+	NoDeclaration bool
+}
+
+func NewWriter(conf *WriterConfig) *Writer {
+	return &Writer{
+		conf: conf,
+	}
+}
+
+func (w *Writer) Write(ctx context.Context, ch <-chan interface{}) error {
+	if !w.conf.NoDeclaration {
+		if _, err := io.WriteString(w.conf.Writer, xml.Header); err != nil {
+			return err
+		}
+	}
+
+	enc := xml.NewEncoder(w.conf.Writer)
+	enc.Indent("", w.conf.Indent)
+	root := xml.StartElement{Name: xml.Name{Local: w.root()}}
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+
+	for chunk := range ch {
+		if err := w.flush(middleware.NextChunkContext(ctx), enc, chunk); err != nil {
+			return err
+		}
+	}
+
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func (w *Writer) flush(ctx context.Context, enc *xml.Encoder, chunk interface{}) error {
+	v := reflect.ValueOf(chunk)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("Not supported such a chunk type: %s", v.Type())
+	}
+
+	_, span := tracing.StartSpan(ctx, "xml.Writer.Flush",
+		tracing.WithAttributes(tracing.Int(tracing.AttrChunkItems, int64(v.Len()))))
+	defer span.End()
+
+	var err error
+	switch chunk.(type) {
+	case []middleware.MapMapperType, []middleware.CustomMapperType:
+		var items []middleware.MapMapperType
+		if items, err = middleware.ToMapMapperChunk(chunk); err != nil {
+			break
+		}
+		for _, item := range items {
+			if err = w.encodeMapMapper(enc, item); err != nil {
+				break
+			}
+		}
+	default:
+		start := xml.StartElement{Name: xml.Name{Local: w.element()}}
+		for i := 0; i < v.Len() && err == nil; i++ {
+			err = enc.EncodeElement(v.Index(i).Interface(), start)
+		}
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	span.RecordError(err)
+
+	return err
+}
+
+func (w *Writer) encodeMapMapper(enc *xml.Encoder, item middleware.MapMapperType) error {
+	start := xml.StartElement{Name: xml.Name{Local: w.element()}}
+	var keys []string
+	for k, v := range item {
+		if strings.HasPrefix(k, "@") {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: k[1:]}, Value: v})
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(start.Attr, func(i, j int) bool {
+		return start.Attr[i].Name.Local < start.Attr[j].Name.Local
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		if w.conf.PropsBindPosition == nil {
+			return keys[i] < keys[j]
+		}
+		return w.conf.PropsBindPosition[keys[i]] < w.conf.PropsBindPosition[keys[j]]
+	})
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		child := xml.StartElement{Name: xml.Name{Local: k}}
+		if err := enc.EncodeElement(item[k], child); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func (w *Writer) root() string {
+	if w.conf.Root == "" {
+		return DefaultRoot
+	}
+	return w.conf.Root
+}
+
+func (w *Writer) element() string {
+	if w.conf.Element == "" {
+		return DefaultElement
+	}
+	return w.conf.Element
+}
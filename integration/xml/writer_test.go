@@ -0,0 +1,70 @@
+package xml
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"testing"
+)
+
+func writeChunks(t *testing.T, conf *WriterConfig, chunks ...interface{}) string {
+	var buf bytes.Buffer
+	conf.Writer = &buf
+
+	ch := make(chan interface{}, len(chunks))
+	for _, chunk := range chunks {
+		ch <- chunk
+	}
+	close(ch)
+	if err := NewWriter(conf).Write(context.TODO(), ch); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("MapMapperType", func(t *testing.T) {
+		out := writeChunks(t, &WriterConfig{
+			Root:    "users",
+			Element: "user",
+			Indent:  "  ",
+			PropsBindPosition: middleware.PropsBindPosition{
+				"name": 0,
+				"id":   1,
+			},
+		}, []middleware.MapMapperType{
+			{"@type": "admin", "id": "0", "name": "name0"},
+			{"id": "1", "name": "a&b"},
+		})
+
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<users>
+  <user type="admin">
+    <name>name0</name>
+    <id>0</id>
+  </user>
+  <user>
+    <name>a&amp;b</name>
+    <id>1</id>
+  </user>
+</users>`, out)
+	})
+
+	t.Run("Typed structs", func(t *testing.T) {
+		out := writeChunks(t, &WriterConfig{NoDeclaration: true},
+			[]Order{{Id: "1", Customer: Customer{Name: "John"}, Tags: []string{"vip"}}},
+			[]Order{})
+
+		assert.Equal(t, `<records><record id="1"><customer><name>John</name><city></city></customer>`+
+			`<tag>vip</tag></record></records>`, out)
+	})
+
+	t.Run("Not supported chunk type", func(t *testing.T) {
+		ch := make(chan interface{}, 1)
+		ch <- "foo"
+		close(ch)
+		err := NewWriter(&WriterConfig{Writer: new(bytes.Buffer)}).Write(context.TODO(), ch)
+		assert.EqualError(t, err, "Not supported such a chunk type: string")
+	})
+}
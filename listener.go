@@ -0,0 +1,165 @@
+package wolfx
+
+import (
+	"context"
+	"github.com/yackrru/wolfx/middleware"
+)
+
+// Listener is notified of the lifecycle of jobs, flows, steps and chunks.
+// It must implement at least one of JobListener, FlowListener,
+// StepListener, ChunkListener, BeforeChunkListener and ProgressListener.
+type Listener interface{}
+
+// JobListener is the interface that wraps methods of BeforeJob and AfterJob.
+//
+// BeforeJob is called before JobExecutor.Run and returns the context
+// handed down to the flows of the job.
+// AfterJob is called after JobExecutor.Run.
+type JobListener interface {
+	BeforeJob(ctx context.Context, je *middleware.JobExecution) context.Context
+	AfterJob(ctx context.Context, je *middleware.JobExecution)
+}
+
+// FlowListener is the interface that wraps methods of BeforeFlow and AfterFlow.
+//
+// BeforeFlow is called before the steps of the flow start and returns
+// the context handed down to the steps.
+// AfterFlow is called after all steps of the flow finished.
+type FlowListener interface {
+	BeforeFlow(ctx context.Context, fe *middleware.FlowExecution) context.Context
+	AfterFlow(ctx context.Context, fe *middleware.FlowExecution)
+}
+
+// StepListener is the interface that wraps methods of BeforeStep and AfterStep.
+//
+// BeforeStep is called before Step and returns the context passed to Step.
+// AfterStep is called after Step.
+type StepListener interface {
+	BeforeStep(ctx context.Context, se *middleware.StepExecution) context.Context
+	AfterStep(ctx context.Context, se *middleware.StepExecution)
+}
+
+// ChunkListener is the interface that wraps the method of AfterChunk.
+//
+// AfterChunk is called each time the writer receives a chunk from the reader.
+type ChunkListener interface {
+	AfterChunk(ctx context.Context, ce *middleware.ChunkExecution)
+}
+
+// BeforeChunkListener is the interface that wraps the method of BeforeChunk.
+//
+// BeforeChunk is called when the reader begins reading a chunk
+// and returns the context of the chunk.
+// The reader and the writer process the chunk with the context
+// given by middleware.NextChunkContext, e.g. to start the spans as its children.
+// The context is also passed to AfterChunk of the chunk.
+// It may be called on the goroutine of the reader or the writer.
+type BeforeChunkListener interface {
+	BeforeChunk(ctx context.Context, ce *middleware.ChunkExecution) context.Context
+}
+
+// ProgressListener is the interface that wraps the method of OnProgress.
+//
+// OnProgress is called at the interval configured by WolfX.ProgressInterval
+// and WolfX.ProgressChunks while the step is running.
+// It may be called concurrently for the steps of a concurrent flow.
+type ProgressListener interface {
+	OnProgress(ctx context.Context, p *middleware.Progress)
+}
+
+type listeners []Listener
+
+type listenersKey struct{}
+
+func withListeners(ctx context.Context, ls listeners) context.Context {
+	return context.WithValue(ctx, listenersKey{}, ls)
+}
+
+func listenersFromContext(ctx context.Context) listeners {
+	ls, _ := ctx.Value(listenersKey{}).(listeners)
+	return ls
+}
+
+func (ls listeners) beforeJob(ctx context.Context, je *middleware.JobExecution) context.Context {
+	for _, l := range ls {
+		if jl, ok := l.(JobListener); ok {
+			if c := jl.BeforeJob(ctx, je); c != nil {
+				ctx = c
+			}
+		}
+	}
+	return ctx
+}
+
+func (ls listeners) afterJob(ctx context.Context, je *middleware.JobExecution) {
+	for i := len(ls) - 1; i >= 0; i-- {
+		if jl, ok := ls[i].(JobListener); ok {
+			jl.AfterJob(ctx, je)
+		}
+	}
+}
+
+func (ls listeners) beforeFlow(ctx context.Context, fe *middleware.FlowExecution) context.Context {
+	for _, l := range ls {
+		if fl, ok := l.(FlowListener); ok {
+			if c := fl.BeforeFlow(ctx, fe); c != nil {
+				ctx = c
+			}
+		}
+	}
+	return ctx
+}
+
+func (ls listeners) afterFlow(ctx context.Context, fe *middleware.FlowExecution) {
+	for i := len(ls) - 1; i >= 0; i-- {
+		if fl, ok := ls[i].(FlowListener); ok {
+			fl.AfterFlow(ctx, fe)
+		}
+	}
+}
+
+func (ls listeners) beforeStep(ctx context.Context, se *middleware.StepExecution) context.Context {
+	for _, l := range ls {
+		if sl, ok := l.(StepListener); ok {
+			if c := sl.BeforeStep(ctx, se); c != nil {
+				ctx = c
+			}
+		}
+	}
+	return ctx
+}
+
+func (ls listeners) afterStep(ctx context.Context, se *middleware.StepExecution) {
+	for i := len(ls) - 1; i >= 0; i-- {
+		if sl, ok := ls[i].(StepListener); ok {
+			sl.AfterStep(ctx, se)
+		}
+	}
+}
+
+func (ls listeners) beforeChunk(ctx context.Context, ce *middleware.ChunkExecution) context.Context {
+	for _, l := range ls {
+		if cl, ok := l.(BeforeChunkListener); ok {
+			if c := cl.BeforeChunk(ctx, ce); c != nil {
+				ctx = c
+			}
+		}
+	}
+	return ctx
+}
+
+func (ls listeners) afterChunk(ctx context.Context, ce *middleware.ChunkExecution) {
+	for _, l := range ls {
+		if cl, ok := l.(ChunkListener); ok {
+			cl.AfterChunk(ctx, ce)
+		}
+	}
+}
+
+func (ls listeners) progress(ctx context.Context, p *middleware.Progress) {
+	for _, l := range ls {
+		if pl, ok := l.(ProgressListener); ok {
+			pl.OnProgress(ctx, p)
+		}
+	}
+}
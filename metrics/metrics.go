@@ -0,0 +1,413 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	_ wolfx.JobListener   = new(Collector)
+	_ wolfx.StepListener  = new(Collector)
+	_ wolfx.ChunkListener = new(Collector)
+	_ http.Handler        = new(Collector)
+)
+
+// ContentType is the content type of Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// DefaultDurationBuckets are the histogram buckets in seconds
+	// for the durations of jobs and steps.
+	DefaultDurationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 14400}
+
+	// DefaultChunkBuckets are the histogram buckets in seconds
+	// for the latency of chunks.
+	DefaultChunkBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+// Collector records metrics of jobs, steps and chunks.
+// It is used as wolfx.Listener and exposes the metrics
+// in Prometheus text exposition format.
+type Collector struct {
+	conf *CollectorConfig
+
+	mu       sync.Mutex
+	families []*family
+	byName   map[string]*family
+
+	// counted are the counts of the running steps already added to the counters.
+	counted map[*middleware.StepExecution]*stepCounts
+}
+
+// stepCounts are the counts of a step added to the counters.
+type stepCounts struct {
+	written uint64
+	skipped uint64
+}
+
+// CollectorConfig is the configuration of Collector.
+type CollectorConfig struct {
+	// Namespace is the prefix of metric names.
+	// If it is empty, "wolfx" is used.
+	Namespace string
+
+	// DurationBuckets are the buckets of job and step duration histograms.
+	// If it is nil, DefaultDurationBuckets is used.
+	DurationBuckets []float64
+
+	// ChunkBuckets are the buckets of chunk latency histogram.
+	// If it is nil, DefaultChunkBuckets is used.
+	ChunkBuckets []float64
+
+	// TextfilePath is the output file for the textfile collector
+	// of node_exporter. If it is not empty, Collector writes
+	// all metrics to the file at the end of each job.
+	TextfilePath string
+}
+
+const (
+	jobDuration   = "job_duration_seconds"
+	stepDuration  = "step_duration_seconds"
+	chunkDuration = "chunk_duration_seconds"
+	itemsRead     = "items_read_total"
+	itemsWritten  = "items_written_total"
+	itemsSkipped  = "items_skipped_total"
+	stepsInFlight = "steps_in_flight"
+)
+
+func NewCollector(conf *CollectorConfig) *Collector {
+	if conf == nil {
+		conf = new(CollectorConfig)
+	}
+	c := &Collector{
+		conf:    conf,
+		byName:  make(map[string]*family),
+		counted: make(map[*middleware.StepExecution]*stepCounts),
+	}
+
+	durationBuckets := conf.DurationBuckets
+	if durationBuckets == nil {
+		durationBuckets = DefaultDurationBuckets
+	}
+	chunkBuckets := conf.ChunkBuckets
+	if chunkBuckets == nil {
+		chunkBuckets = DefaultChunkBuckets
+	}
+
+	c.register(jobDuration, typeHistogram, durationBuckets,
+		"Duration of jobs in seconds.")
+	c.register(stepDuration, typeHistogram, durationBuckets,
+		"Duration of steps in seconds.")
+	c.register(chunkDuration, typeHistogram, chunkBuckets,
+		"Latency of chunks passed from reader to writer in seconds.")
+	c.register(itemsRead, typeCounter, nil,
+		"Number of items sent by readers.")
+	c.register(itemsWritten, typeCounter, nil,
+		"Number of items processed by writers.")
+	c.register(itemsSkipped, typeCounter, nil,
+		"Number of items skipped by readers or writers.")
+	c.register(stepsInFlight, typeGauge, nil,
+		"Number of steps currently running.")
+
+	return c
+}
+
+func (c *Collector) register(name, typ string, buckets []float64, help string) {
+	namespace := c.conf.Namespace
+	if namespace == "" {
+		namespace = "wolfx"
+	}
+	f := &family{
+		name:    namespace + "_" + name,
+		typ:     typ,
+		help:    help,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	c.families = append(c.families, f)
+	c.byName[name] = f
+}
+
+func (c *Collector) BeforeJob(ctx context.Context, je *middleware.JobExecution) context.Context {
+	return ctx
+}
+
+func (c *Collector) AfterJob(ctx context.Context, je *middleware.JobExecution) {
+	c.mu.Lock()
+	c.byName[jobDuration].with("job", je.JobName, "status", status(je.Err)).
+		observe(je.EndTime.Sub(je.StartTime).Seconds())
+	c.mu.Unlock()
+
+	if c.conf.TextfilePath != "" {
+		if err := c.WriteTextfile(c.conf.TextfilePath); err != nil {
+			middleware.LoggerFromContext(ctx).Error("Failed to write metrics: ", err)
+		}
+	}
+}
+
+func (c *Collector) BeforeStep(ctx context.Context, se *middleware.StepExecution) context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byName[stepsInFlight].with("job", se.JobName()).add(1)
+	return ctx
+}
+
+func (c *Collector) AfterStep(ctx context.Context, se *middleware.StepExecution) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job, step := se.JobName(), se.StepName
+	c.byName[stepsInFlight].with("job", job).add(-1)
+	c.byName[stepDuration].with("job", job, "step", step, "status", status(se.Err)).
+		observe(se.EndTime.Sub(se.StartTime).Seconds())
+	c.addCounts(se)
+	delete(c.counted, se)
+}
+
+func (c *Collector) AfterChunk(ctx context.Context, ce *middleware.ChunkExecution) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job, step := ce.StepExecution.JobName(), ce.StepExecution.StepName
+	c.byName[chunkDuration].with("job", job, "step", step).
+		observe(ce.Duration().Seconds())
+	c.byName[itemsRead].with("job", job, "step", step).add(float64(ce.Items))
+	c.addCounts(ce.StepExecution)
+}
+
+// addCounts adds the items written and skipped since the last call for se.
+// It must be called with c.mu held.
+func (c *Collector) addCounts(se *middleware.StepExecution) {
+	counts, ok := c.counted[se]
+	if !ok {
+		counts = new(stepCounts)
+		c.counted[se] = counts
+	}
+	job, step := se.JobName(), se.StepName
+	written, skipped := se.WriteCount(), se.SkipCount()
+	c.byName[itemsWritten].with("job", job, "step", step).add(float64(written - counts.written))
+	c.byName[itemsSkipped].with("job", job, "step", step).add(float64(skipped - counts.skipped))
+	counts.written, counts.skipped = written, skipped
+}
+
+// WriteTo writes all metrics to w in Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, f := range c.families {
+		m, err := f.writeTo(bw)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ServeHTTP serves all metrics in Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if _, err := c.WriteTo(w); err != nil {
+		middleware.LoggerFromContext(r.Context()).Error("Failed to serve metrics: ", err)
+	}
+}
+
+// ListenAndServe serves all metrics on addr at the path of /metrics.
+func (c *Collector) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", c)
+	return http.ListenAndServe(addr, mux)
+}
+
+// WriteTextfile writes all metrics to the file at path.
+// The file is replaced atomically so that the textfile collector
+// never reads a partially written file.
+func (c *Collector) WriteTextfile(path string) error {
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func status(err error) string {
+	if errors.Is(err, middleware.ErrStopped) {
+		return "stopped"
+	}
+	if err != nil {
+		return "failed"
+	}
+	return "completed"
+}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// family is a metric with its series of each label set.
+type family struct {
+	name    string
+	typ     string
+	help    string
+	buckets []float64
+	series  map[string]*series
+}
+
+// series is the value of a metric for a label set.
+type series struct {
+	labels []string
+	value  float64
+
+	// Histogram only. counts are not cumulative.
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// with returns the series of the label pairs.
+func (f *family) with(labels ...string) *series {
+	key := strings.Join(labels, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		if f.typ == typeHistogram {
+			s.buckets = f.buckets
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (s *series) add(v float64) {
+	s.value += v
+}
+
+func (s *series) observe(v float64) {
+	for i := range s.counts {
+		if v <= s.buckets[i] {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (f *family) writeTo(w io.Writer) (int64, error) {
+	var n int64
+	write := func(format string, args ...interface{}) error {
+		m, err := fmt.Fprintf(w, format, args...)
+		n += int64(m)
+		return err
+	}
+
+	if err := write("# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ); err != nil {
+		return n, err
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.typ != typeHistogram {
+			if err := write("%s%s %s\n", f.name, formatLabels(s.labels),
+				formatValue(s.value)); err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		var cumulative uint64
+		for i, le := range f.buckets {
+			cumulative += s.counts[i]
+			labels := append(append([]string{}, s.labels...), "le", formatValue(le))
+			if err := write("%s_bucket%s %d\n", f.name, formatLabels(labels),
+				cumulative); err != nil {
+				return n, err
+			}
+		}
+		labels := append(append([]string{}, s.labels...), "le", "+Inf")
+		if err := write("%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			f.name, formatLabels(labels), s.count,
+			f.name, formatLabels(s.labels), formatValue(s.sum),
+			f.name, formatLabels(s.labels), s.count); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type TestJob struct {
+	fail bool
+}
+
+func (j *TestJob) Name() string {
+	return "TestJob"
+}
+
+// Run is invoked without the context of the job,
+// whose steps are still recorded.
+func (j *TestJob) Run() error {
+	return wolfx.NewJobBuilder().
+		Single(j.TestStep).
+		Build()
+}
+
+func (j *TestJob) TestStep(ctx context.Context) error {
+	return wolfx.NewStepBuilder(ctx).
+		SetReader(new(TestReader)).
+		SetWriter(&TestWriter{fail: j.fail}).
+		Build()
+}
+
+type TestReader struct{}
+
+func (r *TestReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+	ch <- []middleware.MapMapperType{{"id": "0"}, {"id": "1"}}
+	ch <- []middleware.MapMapperType{{"id": "2"}}
+	return nil
+}
+
+type TestWriter struct {
+	fail bool
+}
+
+func (w *TestWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	for range ch {
+		middleware.AddSkipCount(ctx, 1)
+	}
+	if w.fail {
+		return fmt.Errorf("TestWriter error")
+	}
+	return nil
+}
+
+func runJob(c *Collector, job *TestJob) error {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	wx.Add(job).AddListener(c)
+	return wx.Run(job.Name())
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(nil)
+	if err := runJob(c, new(TestJob)); err != nil {
+		t.Fatal(err)
+	}
+	if err := runJob(c, &TestJob{fail: true}); err == nil {
+		t.Fatal("Want error but got nil")
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE wolfx_job_duration_seconds histogram\n")
+	assert.Contains(t, out, `wolfx_job_duration_seconds_count{job="TestJob",status="completed"} 1`)
+	assert.Contains(t, out, `wolfx_job_duration_seconds_count{job="TestJob",status="failed"} 1`)
+	assert.Contains(t, out, `wolfx_step_duration_seconds_bucket{job="TestJob",step="TestStep",status="completed",le="+Inf"} 1`)
+	assert.Contains(t, out, `wolfx_chunk_duration_seconds_count{job="TestJob",step="TestStep"} 4`)
+	assert.Contains(t, out, `wolfx_items_read_total{job="TestJob",step="TestStep"} 6`)
+	// The last chunk of the failed job is not written.
+	assert.Contains(t, out, `wolfx_items_written_total{job="TestJob",step="TestStep"} 5`)
+	assert.Contains(t, out, `wolfx_items_skipped_total{job="TestJob",step="TestStep"} 4`)
+	assert.Contains(t, out, `wolfx_steps_in_flight{job="TestJob"} 0`)
+}
+
+func TestCollectorCountsPerChunk(t *testing.T) {
+	c := NewCollector(nil)
+	se := middleware.NewStepExecution(nil, "TestStep")
+	ctx := c.BeforeStep(context.TODO(), se)
+
+	se.AddWriteCount(2)
+	se.AddSkipCount(1)
+	c.AfterChunk(ctx, &middleware.ChunkExecution{StepExecution: se, Items: 3})
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// The counters are updated while the step is running.
+	assert.Contains(t, buf.String(), `wolfx_items_written_total{job="",step="TestStep"} 2`)
+	assert.Contains(t, buf.String(), `wolfx_items_skipped_total{job="",step="TestStep"} 1`)
+
+	se.AddWriteCount(3)
+	se.Finish(nil)
+	c.AfterStep(ctx, se)
+	buf.Reset()
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, buf.String(), `wolfx_items_written_total{job="",step="TestStep"} 5`)
+	assert.Contains(t, buf.String(), `wolfx_items_skipped_total{job="",step="TestStep"} 1`)
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, "completed", status(nil))
+	assert.Equal(t, "failed", status(fmt.Errorf("TestWriter error")))
+	assert.Equal(t, "stopped", status(fmt.Errorf("Step: %w", middleware.ErrStopped)))
+}
+
+func TestCollectorServeHTTP(t *testing.T) {
+	c := NewCollector(&CollectorConfig{Namespace: "batch"})
+	if err := runJob(c, new(TestJob)); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(c)
+	defer server.Close()
+	res, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ContentType, res.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `batch_items_read_total{job="TestJob",step="TestStep"} 3`)
+}
+
+func TestCollectorTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wolfx.prom")
+	c := NewCollector(&CollectorConfig{TextfilePath: path})
+	if err := runJob(c, new(TestJob)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	if _, err := c.WriteTo(&want); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want.String(), string(got))
+
+	matches, err := filepath.Glob(path + ".*.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, matches)
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", formatLabels(nil))
+	assert.Equal(t, `{job="a\"b\\c\nd",le="+Inf"}`,
+		formatLabels([]string{"job", "a\"b\\c\nd", "le", "+Inf"}))
+}
@@ -0,0 +1,714 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the status of a job or step execution.
+type Status string
+
+const (
+	StatusStarting  Status = "STARTING"
+	StatusStarted   Status = "STARTED"
+	StatusStopping  Status = "STOPPING"
+	StatusStopped   Status = "STOPPED"
+	StatusCompleted Status = "COMPLETED"
+	StatusFailed    Status = "FAILED"
+)
+
+// IsRunning reports whether the status is before the end of execution.
+func (s Status) IsRunning() bool {
+	return s == StatusStarting || s == StatusStarted || s == StatusStopping
+}
+
+// ErrStopped is returned from the job and steps stopped by JobExecution.Stop.
+var ErrStopped = errors.New("Execution stopped")
+
+// JobParameters are the parameters given to a job at launching.
+type JobParameters map[string]string
+
+// JobExecution is the runtime state of a job.
+// Status, EndTime, Err and step executions are guarded by the lock
+// so that Record can be called while the job is running.
+type JobExecution struct {
+	// ID is the identifier assigned by the job repository.
+	ID int64
+
+	// JobName is the name of JobExecutor.
+	JobName string
+
+	// Parameters are the parameters given at launching.
+	Parameters JobParameters
+
+	Status    Status
+	StartTime time.Time
+	EndTime   time.Time
+
+	// Err is the error returned from the job.
+	Err error
+
+	mu       sync.Mutex
+	steps    []*StepExecution
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{}
+}
+
+// NewJobExecution returns a JobExecution of the job.
+func NewJobExecution(jobName string, params JobParameters) *JobExecution {
+	if params == nil {
+		params = make(JobParameters)
+	}
+	return &JobExecution{
+		JobName:    jobName,
+		Parameters: params,
+		Status:     StatusStarting,
+		StartTime:  time.Now(),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+}
+
+// Start marks the job as started.
+func (e *JobExecution) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.StartTime = time.Now()
+	if e.Status == StatusStarting {
+		e.Status = StatusStarted
+	}
+}
+
+// Finish marks the job as finished with err.
+func (e *JobExecution) Finish(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.EndTime = time.Now()
+	e.Err = err
+	e.Status = finishedStatus(err)
+}
+
+// Close releases the callers of Wait.
+// It is called after all processing of the job including listeners.
+func (e *JobExecution) Close() {
+	close(e.doneCh)
+}
+
+// Done returns a channel closed when the job is closed.
+func (e *JobExecution) Done() <-chan struct{} {
+	return e.doneCh
+}
+
+// Wait waits until the job is closed and returns the error of the job.
+func (e *JobExecution) Wait() error {
+	<-e.doneCh
+	return e.Err
+}
+
+// Stop requests the job to stop gracefully.
+// Running steps stop after passing the current chunk to the writer
+// and the following flows are not executed.
+func (e *JobExecution) Stop() {
+	e.stopOnce.Do(func() {
+		e.mu.Lock()
+		if e.Status.IsRunning() {
+			e.Status = StatusStopping
+		}
+		e.mu.Unlock()
+		close(e.stopCh)
+	})
+}
+
+// Stopping returns a channel closed when Stop is called.
+func (e *JobExecution) Stopping() <-chan struct{} {
+	return e.stopCh
+}
+
+// IsStopping reports whether Stop has been called.
+func (e *JobExecution) IsStopping() bool {
+	select {
+	case <-e.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// AddStepExecution adds the StepExecution to the job.
+func (e *JobExecution) AddStepExecution(se *StepExecution) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.steps = append(e.steps, se)
+}
+
+// StepExecutions returns the step executions of the job.
+func (e *JobExecution) StepExecutions() []*StepExecution {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*StepExecution{}, e.steps...)
+}
+
+// Record returns the snapshot of the job and its steps.
+func (e *JobExecution) Record() JobExecutionRecord {
+	e.mu.Lock()
+	rec := JobExecutionRecord{
+		ID:         e.ID,
+		JobName:    e.JobName,
+		Parameters: e.Parameters,
+		Status:     e.Status,
+		StartTime:  e.StartTime,
+		EndTime:    optionalTime(e.EndTime),
+	}
+	if e.Err != nil {
+		rec.ExitMessage = e.Err.Error()
+	}
+	steps := append([]*StepExecution{}, e.steps...)
+	e.mu.Unlock()
+
+	for _, se := range steps {
+		rec.Steps = append(rec.Steps, se.Record())
+	}
+	return rec
+}
+
+func finishedStatus(err error) Status {
+	switch {
+	case err == nil:
+		return StatusCompleted
+	case errors.Is(err, ErrStopped):
+		return StatusStopped
+	default:
+		return StatusFailed
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// JobExecutionRecord is the snapshot of a JobExecution
+// stored in the job repository.
+type JobExecutionRecord struct {
+	ID          int64                 `json:"id"`
+	JobName     string                `json:"job_name"`
+	Parameters  JobParameters         `json:"parameters,omitempty"`
+	Status      Status                `json:"status"`
+	StartTime   time.Time             `json:"start_time"`
+	EndTime     *time.Time            `json:"end_time,omitempty"`
+	ExitMessage string                `json:"exit_message,omitempty"`
+	Steps       []StepExecutionRecord `json:"steps,omitempty"`
+}
+
+// StepExecutionRecord is the snapshot of a StepExecution.
+type StepExecutionRecord struct {
+	StepName    string     `json:"step_name"`
+	Status      Status     `json:"status"`
+	StartTime   time.Time  `json:"start_time"`
+	EndTime     *time.Time `json:"end_time,omitempty"`
+	ReadCount   uint64     `json:"read_count"`
+	WriteCount  uint64     `json:"write_count"`
+	SkipCount   uint64     `json:"skip_count"`
+	ChunkCount  uint64     `json:"chunk_count"`
+	ExitMessage string     `json:"exit_message,omitempty"`
+
+	// Context is the snapshot of the execution context of the step.
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// FlowExecution is the runtime state of a flow,
+// the unit of steps executed concurrently.
+type FlowExecution struct {
+	JobExecution *JobExecution
+
+	// Index is the position of the flow in the job starting from 0.
+	Index int
+
+	// StepNames are the names of the steps in the flow.
+	StepNames []string
+
+	StartTime time.Time
+	EndTime   time.Time
+	Err       error
+}
+
+// StepExecution is the runtime state of a step.
+// The counters are safe for concurrent use.
+type StepExecution struct {
+	// The counters are placed first for 64-bit alignment of atomic operations.
+	readCount  uint64
+	writeCount uint64
+	skipCount  uint64
+	chunkCount uint64
+
+	// The progress reported by the reader.
+	progressTotal    uint64
+	progressPosition uint64
+
+	JobExecution *JobExecution
+
+	// StepName is the method name of Step.
+	StepName string
+
+	Status    Status
+	StartTime time.Time
+	EndTime   time.Time
+	Err       error
+
+	progressUnit ProgressUnit
+	endFuncs     []func(err error) error
+	ended        bool
+	execCtx      *ExecutionContext
+
+	mu sync.Mutex
+}
+
+// NewStepExecution returns a StepExecution of the step.
+func NewStepExecution(je *JobExecution, stepName string) *StepExecution {
+	return &StepExecution{
+		JobExecution: je,
+		StepName:     stepName,
+		Status:       StatusStarting,
+	}
+}
+
+// Start marks the step as started.
+func (e *StepExecution) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.StartTime = time.Now()
+	e.Status = StatusStarted
+}
+
+// Finish marks the step as finished with err.
+// The functions registered by OnEnd are called before
+// in reverse order of registration,
+// including the ones registered while they are called.
+func (e *StepExecution) Finish(err error) {
+	for {
+		e.mu.Lock()
+		endFuncs := e.endFuncs
+		e.endFuncs = nil
+		if len(endFuncs) == 0 {
+			break
+		}
+		e.mu.Unlock()
+		for i := len(endFuncs) - 1; i >= 0; i-- {
+			if errEnd := endFuncs[i](err); err == nil {
+				err = errEnd
+			}
+		}
+	}
+	defer e.mu.Unlock()
+	e.ended = true
+	e.EndTime = time.Now()
+	e.Err = err
+	e.Status = finishedStatus(err)
+}
+
+// OnEnd registers f called when the step ends with the error of the step.
+// If f returns an error, the step fails with it.
+// Writers use it to publish their output only when the step succeeds.
+// If the step has already finished, f is called at once with its error
+// and the error of f is only logged.
+func (e *StepExecution) OnEnd(f func(err error) error) {
+	e.mu.Lock()
+	if !e.ended {
+		e.endFuncs = append(e.endFuncs, f)
+		e.mu.Unlock()
+		return
+	}
+	err := e.Err
+	e.mu.Unlock()
+	if errEnd := f(err); errEnd != nil {
+		LoggerFromContext(WithStepExecution(context.Background(), e)).Error(errEnd)
+	}
+}
+
+// Record returns the snapshot of the step.
+func (e *StepExecution) Record() StepExecutionRecord {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rec := StepExecutionRecord{
+		StepName:   e.StepName,
+		Status:     e.Status,
+		StartTime:  e.StartTime,
+		EndTime:    optionalTime(e.EndTime),
+		ReadCount:  e.ReadCount(),
+		WriteCount: e.WriteCount(),
+		SkipCount:  e.SkipCount(),
+		ChunkCount: e.ChunkCount(),
+	}
+	if e.Err != nil {
+		rec.ExitMessage = e.Err.Error()
+	}
+	if e.execCtx != nil {
+		rec.Context = e.execCtx.Values()
+	}
+	return rec
+}
+
+// ExecutionContext returns the execution context of the step.
+func (e *StepExecution) ExecutionContext() *ExecutionContext {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.execCtx == nil {
+		e.execCtx = new(ExecutionContext)
+	}
+	return e.execCtx
+}
+
+// JobName returns the name of the job the step belongs to.
+func (e *StepExecution) JobName() string {
+	if e.JobExecution == nil {
+		return ""
+	}
+	return e.JobExecution.JobName
+}
+
+// ReadCount returns the number of items sent by the reader.
+func (e *StepExecution) ReadCount() uint64 {
+	return atomic.LoadUint64(&e.readCount)
+}
+
+// WriteCount returns the number of items processed by the writer.
+// The items of a chunk are counted when the writer receives the next chunk
+// or returns without error.
+func (e *StepExecution) WriteCount() uint64 {
+	return atomic.LoadUint64(&e.writeCount)
+}
+
+// SkipCount returns the number of items skipped by the reader or writer.
+func (e *StepExecution) SkipCount() uint64 {
+	return atomic.LoadUint64(&e.skipCount)
+}
+
+// ChunkCount returns the number of chunks passed to the writer.
+func (e *StepExecution) ChunkCount() uint64 {
+	return atomic.LoadUint64(&e.chunkCount)
+}
+
+// AddReadCount adds n to the read count.
+func (e *StepExecution) AddReadCount(n uint64) {
+	atomic.AddUint64(&e.readCount, n)
+}
+
+// AddWriteCount adds n to the write count.
+func (e *StepExecution) AddWriteCount(n uint64) {
+	atomic.AddUint64(&e.writeCount, n)
+}
+
+// AddSkipCount adds n to the skip count.
+// Readers and writers call it for the items they drop.
+func (e *StepExecution) AddSkipCount(n uint64) {
+	atomic.AddUint64(&e.skipCount, n)
+}
+
+// AddChunkCount adds n to the chunk count.
+func (e *StepExecution) AddChunkCount(n uint64) {
+	atomic.AddUint64(&e.chunkCount, n)
+}
+
+// ExecutionContext is the key-value store of a step.
+// Readers, writers and listeners share values through it,
+// e.g. the batch ID parsed from the header of a file.
+// It is safe for concurrent use and the nil value is an empty store.
+type ExecutionContext struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+// Put stores value with key.
+// It does nothing if c is nil.
+func (c *ExecutionContext) Put(key string, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// Get returns the value stored with key.
+func (c *ExecutionContext) Get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// GetString returns the value stored with key if it is a string.
+// Otherwise it returns the empty string.
+func (c *ExecutionContext) GetString(key string) string {
+	value, _ := c.Get(key)
+	s, _ := value.(string)
+	return s
+}
+
+// Values returns the copy of all values.
+func (c *ExecutionContext) Values() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.values) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(c.values))
+	for k, v := range c.values {
+		values[k] = v
+	}
+	return values
+}
+
+// ChunkExecution is the result of passing a chunk from the reader to the writer.
+// Only StepExecution, Index and StartTime are set before the chunk is received.
+type ChunkExecution struct {
+	StepExecution *StepExecution
+
+	// Index is the position of the chunk in the step starting from 0.
+	Index uint64
+
+	// Items is the number of items in the chunk.
+	Items uint64
+
+	// StartTime is the time the reader began reading the chunk,
+	// i.e. the time the step received the previous chunk.
+	StartTime time.Time
+
+	// EndTime is the time the writer received the chunk.
+	EndTime time.Time
+
+	// ReadWait is the time spent waiting for the reader to send the chunk.
+	ReadWait time.Duration
+
+	// WriteWait is the time spent waiting for the writer to receive the chunk.
+	WriteWait time.Duration
+
+	mu      sync.Mutex
+	written []func()
+}
+
+// OnWritten registers f called when the writer has processed the chunk,
+// i.e. when the writer receives the next chunk or returns without error.
+// It is never called if the step fails before.
+func (e *ChunkExecution) OnWritten(f func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.written = append(e.written, f)
+}
+
+// Written calls the functions registered by OnWritten in order of registration.
+// It is called by the step.
+func (e *ChunkExecution) Written() {
+	e.mu.Lock()
+	written := e.written
+	e.written = nil
+	e.mu.Unlock()
+	for _, f := range written {
+		f()
+	}
+}
+
+// Duration returns the time from StartTime to EndTime.
+func (e *ChunkExecution) Duration() time.Duration {
+	return e.EndTime.Sub(e.StartTime)
+}
+
+// CountItems returns the number of items in the chunk.
+// Slices count their elements and any other value counts as one item.
+func CountItems(chunk interface{}) uint64 {
+	v := reflect.ValueOf(chunk)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return uint64(v.Len())
+	}
+	return 1
+}
+
+type executionContextKey int
+
+const (
+	jobExecutionKey executionContextKey = iota
+	stepExecutionKey
+	chunkExecutionKey
+	chunkContextKey
+)
+
+// WithJobExecution returns a copy of ctx holding the JobExecution.
+func WithJobExecution(ctx context.Context, je *JobExecution) context.Context {
+	return context.WithValue(ctx, jobExecutionKey, je)
+}
+
+// JobExecutionFromContext returns the JobExecution held by ctx.
+// It returns nil if ctx has no JobExecution.
+func JobExecutionFromContext(ctx context.Context) *JobExecution {
+	je, _ := ctx.Value(jobExecutionKey).(*JobExecution)
+	return je
+}
+
+// WithStepExecution returns a copy of ctx holding the StepExecution.
+func WithStepExecution(ctx context.Context, se *StepExecution) context.Context {
+	return context.WithValue(ctx, stepExecutionKey, se)
+}
+
+// StepExecutionFromContext returns the StepExecution held by ctx.
+// It returns nil if ctx has no StepExecution.
+func StepExecutionFromContext(ctx context.Context) *StepExecution {
+	se, _ := ctx.Value(stepExecutionKey).(*StepExecution)
+	return se
+}
+
+// WithChunkExecution returns a copy of ctx holding the ChunkExecution.
+func WithChunkExecution(ctx context.Context, ce *ChunkExecution) context.Context {
+	return context.WithValue(ctx, chunkExecutionKey, ce)
+}
+
+// ChunkExecutionFromContext returns the ChunkExecution held by ctx.
+// It returns nil if ctx has no ChunkExecution.
+func ChunkExecutionFromContext(ctx context.Context) *ChunkExecution {
+	ce, _ := ctx.Value(chunkExecutionKey).(*ChunkExecution)
+	return ce
+}
+
+// chunkContexts gives the contexts of the chunks in order
+// to a reader or a writer.
+type chunkContexts struct {
+	chunk func(index uint64) context.Context
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// chunkContext is the context holding the values of a chunk
+// over the context given to a reader or a writer.
+type chunkContext struct {
+	context.Context
+	chunk context.Context
+}
+
+func (c *chunkContext) Value(key interface{}) interface{} {
+	if v := c.chunk.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// WithChunkContext returns a copy of ctx given to a reader or a writer,
+// with which NextChunkContext returns the context of each chunk given by chunk.
+// chunk returns nil if the chunk of index has already been processed.
+func WithChunkContext(ctx context.Context, chunk func(index uint64) context.Context) context.Context {
+	return context.WithValue(ctx, chunkContextKey, &chunkContexts{chunk: chunk})
+}
+
+// NextChunkContext returns a copy of ctx to process the next chunk
+// by the reader or the writer running with ctx.
+// The reader calls it before sending each chunk and the writer
+// after receiving each chunk, so that the spans started with
+// the returned context are the children of the span of the chunk.
+// The returned context also holds the index of the chunk.
+// It returns ctx if ctx is not given to a reader or a writer by the step.
+func NextChunkContext(ctx context.Context) context.Context {
+	cc, ok := ctx.Value(chunkContextKey).(*chunkContexts)
+	if !ok {
+		return ctx
+	}
+	cc.mu.Lock()
+	index := cc.next
+	cc.next++
+	cc.mu.Unlock()
+
+	chunkCtx := cc.chunk(index)
+	if chunkCtx == nil {
+		return ctx
+	}
+	return &chunkContext{Context: ctx, chunk: chunkCtx}
+}
+
+// WithoutChunkContext returns a copy of ctx with which NextChunkContext
+// returns ctx and OnChunkWritten returns false.
+// It is given to the reader or the writer wrapped by another one,
+// so that the chunks are counted only by the outer one.
+func WithoutChunkContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, chunkContextKey, nil)
+}
+
+// OnChunkWritten registers f to the ChunkExecution of the next chunk
+// sent by the reader running with ctx. See ChunkExecution.OnWritten.
+// The reader calls it before sending each chunk, e.g. to advance
+// the position to restart from only after the writer has processed the chunk.
+// It returns false without registering f if ctx is not given
+// to a reader by the step.
+func OnChunkWritten(ctx context.Context, f func()) bool {
+	cc, ok := ctx.Value(chunkContextKey).(*chunkContexts)
+	if !ok {
+		return false
+	}
+	cc.mu.Lock()
+	index := cc.next
+	cc.mu.Unlock()
+
+	chunkCtx := cc.chunk(index)
+	if chunkCtx == nil {
+		return false
+	}
+	ce := ChunkExecutionFromContext(chunkCtx)
+	if ce == nil {
+		return false
+	}
+	ce.OnWritten(f)
+	return true
+}
+
+// JobParametersFromContext returns the parameters of the job held by ctx.
+// It returns nil if ctx has no JobExecution.
+func JobParametersFromContext(ctx context.Context) JobParameters {
+	if je := JobExecutionFromContext(ctx); je != nil {
+		return je.Parameters
+	}
+	return nil
+}
+
+// AddSkipCount adds n to the skip count of the StepExecution held by ctx.
+// It does nothing if ctx has no StepExecution.
+func AddSkipCount(ctx context.Context, n uint64) {
+	if se := StepExecutionFromContext(ctx); se != nil {
+		se.AddSkipCount(n)
+	}
+}
+
+// OnStepEnd registers f to the StepExecution held by ctx.
+// See StepExecution.OnEnd.
+// It returns false without registering f if ctx has no StepExecution.
+func OnStepEnd(ctx context.Context, f func(err error) error) bool {
+	se := StepExecutionFromContext(ctx)
+	if se == nil {
+		return false
+	}
+	se.OnEnd(f)
+	return true
+}
+
+// ExecutionContextFromContext returns the execution context
+// of the StepExecution held by ctx.
+// It returns nil, which is an empty store ignoring Put,
+// if ctx has no StepExecution.
+func ExecutionContextFromContext(ctx context.Context) *ExecutionContext {
+	if se := StepExecutionFromContext(ctx); se != nil {
+		return se.ExecutionContext()
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/yackrru/gogger"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ FieldLogger = new(GoggerLogger)
+
+// Field is a key value pair attached to log entries.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// FieldLogger is the logger attaching fields to its log entries.
+// If Logger implements FieldLogger, LoggerFromContext returns
+// the logger attaching the fields of job, step and chunk.
+type FieldLogger interface {
+	gogger.Logger
+
+	// With returns a logger attaching fields in addition to its own fields.
+	With(fields ...Field) FieldLogger
+}
+
+// Keys of the fields attached by LoggerFromContext.
+const (
+	FieldJob         = "job"
+	FieldExecutionID = "execution_id"
+	FieldStep        = "step"
+	FieldChunk       = "chunk"
+)
+
+type logContextKey int
+
+const (
+	chunkIndexKey logContextKey = iota
+	logFieldsKey
+)
+
+// WithChunkIndex returns a copy of ctx holding the index of the chunk.
+func WithChunkIndex(ctx context.Context, idx uint64) context.Context {
+	return context.WithValue(ctx, chunkIndexKey, idx)
+}
+
+// WithLogFields returns a copy of ctx holding user's own log fields.
+func WithLogFields(ctx context.Context, fields ...Field) context.Context {
+	fields = append(append([]Field{}, logFieldsFromContext(ctx)...), fields...)
+	return context.WithValue(ctx, logFieldsKey, fields)
+}
+
+func logFieldsFromContext(ctx context.Context) []Field {
+	fields, _ := ctx.Value(logFieldsKey).([]Field)
+	return fields
+}
+
+// ContextFields returns the fields of job, step and chunk held by ctx
+// followed by the fields given by WithLogFields.
+func ContextFields(ctx context.Context) []Field {
+	var fields []Field
+	if je := JobExecutionFromContext(ctx); je != nil {
+		fields = append(fields,
+			Field{Key: FieldJob, Value: je.JobName},
+			Field{Key: FieldExecutionID, Value: je.ID})
+	}
+	if se := StepExecutionFromContext(ctx); se != nil && se.StepName != "" {
+		fields = append(fields, Field{Key: FieldStep, Value: se.StepName})
+	}
+	if idx, ok := ctx.Value(chunkIndexKey).(uint64); ok {
+		fields = append(fields, Field{Key: FieldChunk, Value: idx})
+	}
+	return append(fields, logFieldsFromContext(ctx)...)
+}
+
+// LoggerFromContext returns Logger attaching the fields held by ctx.
+// If Logger does not implement FieldLogger, it is returned as it is.
+// If Logger is nil, a logger discarding all log entries is returned.
+func LoggerFromContext(ctx context.Context) gogger.Logger {
+	if Logger == nil {
+		return nopLogger{}
+	}
+	fl, ok := Logger.(FieldLogger)
+	if !ok {
+		return Logger
+	}
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return fl
+	}
+	return fl.With(fields...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(args ...interface{})                  {}
+func (nopLogger) Debug(args ...interface{})                 {}
+func (nopLogger) Warn(args ...interface{})                  {}
+func (nopLogger) Error(args ...interface{})                 {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+// GoggerLogger is an implementation of FieldLogger
+// that outputs through gogger's LogWriter and LogFormatter.
+// The fields are prepended to the message as key=value pairs.
+type GoggerLogger struct {
+	conf   *gogger.LogConfig
+	fields []Field
+}
+
+// NewGoggerLogger returns a GoggerLogger configured same as gogger.NewLog.
+func NewGoggerLogger(conf *gogger.LogConfig) *GoggerLogger {
+	c := *conf
+	if c.Formatter == nil {
+		c.Formatter = gogger.NewLogSimpleFormatter(gogger.DefaultLogSimpleFormatterTmpl)
+	}
+	if c.TimeFormat == "" {
+		c.TimeFormat = gogger.DefaultTimeFormat
+	}
+	if c.LogMinLevel == gogger.LevelDefault {
+		c.LogMinLevel = gogger.LevelInfo
+	}
+	return &GoggerLogger{
+		conf: &c,
+	}
+}
+
+func (l *GoggerLogger) With(fields ...Field) FieldLogger {
+	return &GoggerLogger{
+		conf:   l.conf,
+		fields: append(append([]Field{}, l.fields...), fields...),
+	}
+}
+
+func (l *GoggerLogger) Info(args ...interface{}) {
+	l.log(gogger.LevelInfo, fmt.Sprint(args...))
+}
+
+func (l *GoggerLogger) Debug(args ...interface{}) {
+	l.log(gogger.LevelDebug, fmt.Sprint(args...))
+}
+
+func (l *GoggerLogger) Warn(args ...interface{}) {
+	l.log(gogger.LevelWarn, fmt.Sprint(args...))
+}
+
+func (l *GoggerLogger) Error(args ...interface{}) {
+	l.log(gogger.LevelError, fmt.Sprint(args...))
+}
+
+func (l *GoggerLogger) Infof(format string, args ...interface{}) {
+	l.log(gogger.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (l *GoggerLogger) Debugf(format string, args ...interface{}) {
+	l.log(gogger.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *GoggerLogger) Warnf(format string, args ...interface{}) {
+	l.log(gogger.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (l *GoggerLogger) Errorf(format string, args ...interface{}) {
+	l.log(gogger.LevelError, fmt.Sprintf(format, args...))
+}
+
+func (l *GoggerLogger) log(level gogger.LogLevel, msg string) {
+	if level < l.conf.LogMinLevel {
+		return
+	}
+
+	var b strings.Builder
+	for _, f := range l.fields {
+		fmt.Fprintf(&b, "%s=%v ", f.Key, f.Value)
+	}
+	b.WriteString(msg)
+
+	entry := l.conf.Formatter.Format(time.Now().Format(l.conf.TimeFormat),
+		levelString(level), caller(3), b.String())
+	for _, w := range l.conf.Writers {
+		w.Write(entry)
+	}
+}
+
+func levelString(level gogger.LogLevel) string {
+	switch level {
+	case gogger.LevelDebug:
+		return "DEBUG"
+	case gogger.LevelInfo:
+		return "INFO"
+	case gogger.LevelWarn:
+		return "WARN"
+	case gogger.LevelError:
+		return "ERROR"
+	}
+	return ""
+}
+
+// caller returns the file and line of the caller
+// in the same format as gogger.
+func caller(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	components := strings.Split(file, "/")
+	if len(components) > 2 {
+		components = components[len(components)-2:]
+	}
+	return strings.Join(components, "/") + ":" + strconv.Itoa(line)
+}
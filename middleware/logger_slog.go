@@ -0,0 +1,110 @@
+//go:build go1.21
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/yackrru/gogger"
+	"io"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+var _ FieldLogger = new(SlogLogger)
+
+// SlogLogger is an implementation of FieldLogger
+// that outputs through the handler of slog.Logger.
+// The fields are passed to the handler as attributes.
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a SlogLogger wrapping logger.
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{
+		logger: logger,
+	}
+}
+
+// NewJSONLogger returns a SlogLogger writing each log entry to w
+// as a JSON object per line by JSONHandler of log/slog.
+// Log entries under minLevel are discarded.
+func NewJSONLogger(w io.Writer, minLevel gogger.LogLevel) *SlogLogger {
+	return NewSlogLogger(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slogLevel(minLevel),
+	})))
+}
+
+func slogLevel(level gogger.LogLevel) slog.Level {
+	switch level {
+	case gogger.LevelDebug:
+		return slog.LevelDebug
+	case gogger.LevelWarn:
+		return slog.LevelWarn
+	case gogger.LevelError:
+		return slog.LevelError
+	case gogger.LevelOff:
+		return slog.LevelError + 1
+	}
+	return slog.LevelInfo
+}
+
+func (l *SlogLogger) With(fields ...Field) FieldLogger {
+	args := make([]any, 0, len(fields))
+	for _, f := range fields {
+		args = append(args, slog.Any(f.Key, f.Value))
+	}
+	return &SlogLogger{
+		logger: l.logger.With(args...),
+	}
+}
+
+func (l *SlogLogger) Info(args ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprint(args...))
+}
+
+func (l *SlogLogger) Debug(args ...interface{}) {
+	l.log(slog.LevelDebug, fmt.Sprint(args...))
+}
+
+func (l *SlogLogger) Warn(args ...interface{}) {
+	l.log(slog.LevelWarn, fmt.Sprint(args...))
+}
+
+func (l *SlogLogger) Error(args ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprint(args...))
+}
+
+func (l *SlogLogger) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (l *SlogLogger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *SlogLogger) Warnf(format string, args ...interface{}) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (l *SlogLogger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+func (l *SlogLogger) log(level slog.Level, msg string) {
+	ctx := context.Background()
+	handler := l.logger.Handler()
+	if !handler.Enabled(ctx, level) {
+		return
+	}
+
+	// Skip runtime.Callers, log and the logging method
+	// so that the source points to the caller.
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	handler.Handle(ctx, r)
+}
@@ -0,0 +1,71 @@
+//go:build go1.21
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		AddSource: true,
+	})))
+	defer func() { Logger = nil }()
+
+	LoggerFromContext(newTestContext()).Warnf("Skipped %d items", 2)
+
+	var entry struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Job    string `json:"job"`
+		Step   string `json:"step"`
+		Source struct {
+			File string `json:"file"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "WARN", entry.Level)
+	assert.Equal(t, "Skipped 2 items", entry.Msg)
+	assert.Equal(t, "FooJob", entry.Job)
+	assert.Equal(t, "BarStep", entry.Step)
+	assert.Contains(t, entry.Source.File, "logger_slog_test.go")
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	Logger = NewJSONLogger(&buf, gogger.LevelWarn)
+	defer func() { Logger = nil }()
+
+	LoggerFromContext(newTestContext()).Info("Discarded")
+	LoggerFromContext(newTestContext()).Error("Failed")
+
+	var entry struct {
+		Level       string `json:"level"`
+		Msg         string `json:"msg"`
+		Job         string `json:"job"`
+		ExecutionID int64  `json:"execution_id"`
+		Step        string `json:"step"`
+		Chunk       uint64 `json:"chunk"`
+		Source      struct {
+			File string `json:"file"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ERROR", entry.Level)
+	assert.Equal(t, "Failed", entry.Msg)
+	assert.Equal(t, "FooJob", entry.Job)
+	assert.Equal(t, int64(3), entry.ExecutionID)
+	assert.Equal(t, "BarStep", entry.Step)
+	assert.Equal(t, uint64(5), entry.Chunk)
+	assert.Contains(t, entry.Source.File, "logger_slog_test.go")
+}
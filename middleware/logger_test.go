@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"strings"
+	"testing"
+)
+
+type bufferWriter struct {
+	bytes.Buffer
+}
+
+func (w *bufferWriter) Open()  {}
+func (w *bufferWriter) Close() {}
+
+func (w *bufferWriter) Write(entry string) {
+	w.WriteString(entry)
+}
+
+func newTestContext() context.Context {
+	je := NewJobExecution("FooJob", nil)
+	je.ID = 3
+	se := NewStepExecution(je, "BarStep")
+	ctx := WithJobExecution(context.Background(), je)
+	ctx = WithStepExecution(ctx, se)
+	return WithChunkIndex(ctx, 5)
+}
+
+func TestContextFields(t *testing.T) {
+	assert.Empty(t, ContextFields(context.Background()))
+
+	ctx := WithLogFields(newTestContext(), Field{Key: "file", Value: "a.csv"})
+	assert.Equal(t, []Field{
+		{Key: FieldJob, Value: "FooJob"},
+		{Key: FieldExecutionID, Value: int64(3)},
+		{Key: FieldStep, Value: "BarStep"},
+		{Key: FieldChunk, Value: uint64(5)},
+		{Key: "file", Value: "a.csv"},
+	}, ContextFields(ctx))
+}
+
+func TestGoggerLogger(t *testing.T) {
+	w := new(bufferWriter)
+	Logger = NewGoggerLogger(&gogger.LogConfig{
+		Writers:   []gogger.LogWriter{w},
+		Formatter: gogger.NewLogSimpleFormatter("%level% %pkg% %args%"),
+	})
+	defer func() { Logger = nil }()
+
+	LoggerFromContext(newTestContext()).Infof("Hello %s", "world")
+	LoggerFromContext(context.Background()).Debug("Discarded")
+
+	out := w.String()
+	assert.True(t, strings.HasPrefix(out, "INFO middleware/logger_test.go:"), out)
+	assert.Contains(t, out, "job=FooJob execution_id=3 step=BarStep chunk=5 Hello world")
+	assert.NotContains(t, out, "Discarded")
+}
+
+func TestLoggerFromContextWithoutLogger(t *testing.T) {
+	Logger = nil
+	assert.NotPanics(t, func() {
+		LoggerFromContext(newTestContext()).Info("Discarded")
+	})
+}
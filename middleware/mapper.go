@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"database/sql/driver"
+	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 )
 
 const CustomMapperTag = "prop"
@@ -65,17 +68,88 @@ func CustomMapperToFlatItems(chunk []CustomMapperType,
 
 	var mapMapperChunk []MapMapperType
 	for _, itemBuf := range chunk {
-		v := reflect.ValueOf(itemBuf.Props)
-		t := reflect.TypeOf(itemBuf.Props)
-
-		resultSet := make(MapMapperType)
-		for i := 0; i < v.NumField(); i++ {
-			key := t.Field(i).Tag.Get(CustomMapperTag)
-			val := v.Field(i).String()
-			resultSet[key] = val
-		}
-		mapMapperChunk = append(mapMapperChunk, resultSet)
+		mapMapperChunk = append(mapMapperChunk, CustomMapperToMapMapper(itemBuf))
 	}
 
 	return MapMapperToFlatItems(mapMapperChunk, propsBindPosition)
 }
+
+// CustomMapperToMapMapper converts an item of CustomMapperType
+// to MapMapperType keyed by the prop tags.
+// The fields are formatted as FormatValue does,
+// e.g. sql.NullString not valid and nil pointers are converted to the empty string.
+// The fields without prop tag or tagged `prop:"-"` are ignored.
+func CustomMapperToMapMapper(item CustomMapperType) MapMapperType {
+	v := reflect.ValueOf(item.Props)
+	t := reflect.TypeOf(item.Props)
+
+	resultSet := make(MapMapperType)
+	for i := 0; i < v.NumField(); i++ {
+		key := t.Field(i).Tag.Get(CustomMapperTag)
+		if key == "" || key == "-" {
+			continue
+		}
+		resultSet[key] = formatField(v.Field(i))
+	}
+	return resultSet
+}
+
+// formatField formats the field of CustomMapperType.Props as string.
+// The values of driver.Valuer, e.g. sql.NullInt64, are formatted by FormatValue.
+func formatField(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.CanInterface() {
+		if valuer, ok := v.Interface().(driver.Valuer); ok {
+			if val, err := valuer.Value(); err == nil {
+				return FormatValue(val)
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+	if v.CanInterface() {
+		return FormatValue(v.Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+// ToMapMapperChunk converts chunk of MapMapperType, CustomMapperType or *Record
+// to chunk of MapMapperType.
+func ToMapMapperChunk(chunk interface{}) ([]MapMapperType, error) {
+	switch c := chunk.(type) {
+	case []MapMapperType:
+		return c, nil
+	case []CustomMapperType:
+		mapMapperChunk := make([]MapMapperType, 0, len(c))
+		for _, item := range c {
+			mapMapperChunk = append(mapMapperChunk, CustomMapperToMapMapper(item))
+		}
+		return mapMapperChunk, nil
+	case []*Record:
+		mapMapperChunk := make([]MapMapperType, 0, len(c))
+		for _, item := range c {
+			mapMapperChunk = append(mapMapperChunk, item.ToMapMapper())
+		}
+		return mapMapperChunk, nil
+	default:
+		return nil, fmt.Errorf("Not supported such a chunk type: %s", reflect.TypeOf(chunk))
+	}
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"database/sql"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestMapMapperToFlatItems(t *testing.T) {
@@ -74,3 +76,51 @@ func TestConvertItemsCustomMapper(t *testing.T) {
 		assert.Equal(t, target.Props.(TestChunkType).Name, item[0])
 	}
 }
+
+type TestTypedChunkType struct {
+	Id        int64           `prop:"id"`
+	Price     float64         `prop:"price"`
+	Active    bool            `prop:"active"`
+	Name      sql.NullString  `prop:"name"`
+	Score     sql.NullInt64   `prop:"score"`
+	Note      *string         `prop:"note"`
+	Count     *int            `prop:"count"`
+	CreatedAt time.Time       `prop:"created_at"`
+	UpdatedAt sql.NullTime    `prop:"updated_at"`
+	Rate      sql.NullFloat64 `prop:"rate"`
+	Ignored   string          `prop:"-"`
+	Untagged  string
+}
+
+func TestCustomMapperToMapMapper(t *testing.T) {
+	count := 3
+	createdAt := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	item := CustomMapperType{
+		Props: TestTypedChunkType{
+			Id:        42,
+			Price:     19.5,
+			Active:    true,
+			Name:      sql.NullString{String: "Alice", Valid: true},
+			Score:     sql.NullInt64{Int64: 7},
+			Count:     &count,
+			CreatedAt: createdAt,
+			UpdatedAt: sql.NullTime{Time: createdAt, Valid: true},
+			Rate:      sql.NullFloat64{Float64: 0.25, Valid: true},
+			Ignored:   "ignored",
+			Untagged:  "untagged",
+		},
+	}
+
+	assert.Equal(t, MapMapperType{
+		"id":         "42",
+		"price":      "19.5",
+		"active":     "true",
+		"name":       "Alice",
+		"score":      "",
+		"note":       "",
+		"count":      "3",
+		"created_at": "2026-10-01T09:30:00Z",
+		"updated_at": "2026-10-01T09:30:00Z",
+		"rate":       "0.25",
+	}, CustomMapperToMapMapper(item))
+}
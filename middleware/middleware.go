@@ -6,4 +6,8 @@ import (
 
 // Logger is the global logger.
 // both of framework and user's app.
+//
+// If it is set before WolfX.Run, WolfX keeps it instead of creating its own logger.
+// Set a FieldLogger such as GoggerLogger or SlogLogger to get the fields
+// of job and step in the log entries of LoggerFromContext.
 var Logger gogger.Logger
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressUnit is the unit of the total reported by the reader.
+type ProgressUnit string
+
+const (
+	// ProgressItems means the total is the number of items,
+	// e.g. the row count of the query.
+	// The progress is measured by the write count of the step.
+	ProgressItems ProgressUnit = "items"
+
+	// ProgressBytes means the total is the number of bytes,
+	// e.g. the size of the file.
+	// The progress is measured by the position reported by the reader.
+	ProgressBytes ProgressUnit = "bytes"
+)
+
+// Progress is the snapshot of the progress of a step.
+type Progress struct {
+	StepName string
+
+	// Unit is the unit of Done and Total.
+	Unit ProgressUnit
+
+	// Done is the amount processed so far.
+	Done uint64
+
+	// Total is the amount reported by the reader.
+	// It is 0 if the reader reports no total.
+	Total uint64
+
+	// Items is the number of items received by the writer.
+	Items uint64
+
+	// Elapsed is the time since the step started.
+	Elapsed time.Duration
+
+	// ItemsPerSecond is the throughput of the step.
+	ItemsPerSecond float64
+
+	// Percent is the ratio of Done to Total.
+	// It is 0 if Total is unknown.
+	Percent float64
+
+	// ETA is the estimated remaining time and EstimatedEnd is
+	// the estimated completion time.
+	// They are zero if Total is unknown or nothing is done yet.
+	ETA          time.Duration
+	EstimatedEnd time.Time
+}
+
+// String returns the progress in the form of a log message.
+func (p *Progress) String() string {
+	var b strings.Builder
+	if p.Total > 0 {
+		fmt.Fprintf(&b, "%d/%d %s (%.1f%%)", p.Done, p.Total, p.Unit, p.Percent)
+	} else {
+		fmt.Fprintf(&b, "%d %s", p.Done, p.Unit)
+	}
+	fmt.Fprintf(&b, ", %.1f items/s", p.ItemsPerSecond)
+	if !p.EstimatedEnd.IsZero() {
+		fmt.Fprintf(&b, ", ETA %s (at %s)", p.ETA.Round(time.Second),
+			p.EstimatedEnd.Format("2006-01-02 15:04:05"))
+	}
+	return b.String()
+}
+
+// SetProgressTotal sets the total of the step for progress reporting.
+func (e *StepExecution) SetProgressTotal(total uint64, unit ProgressUnit) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.progressUnit = unit
+	atomic.StoreUint64(&e.progressTotal, total)
+}
+
+// SetProgressPosition sets the amount read so far
+// when the unit of the total is ProgressBytes.
+func (e *StepExecution) SetProgressPosition(pos uint64) {
+	atomic.StoreUint64(&e.progressPosition, pos)
+}
+
+// Progress returns the current progress of the step.
+func (e *StepExecution) Progress() *Progress {
+	now := time.Now()
+	e.mu.Lock()
+	unit := e.progressUnit
+	start := e.StartTime
+	e.mu.Unlock()
+
+	p := &Progress{
+		StepName: e.StepName,
+		Unit:     unit,
+		Total:    atomic.LoadUint64(&e.progressTotal),
+		Items:    e.WriteCount(),
+		Elapsed:  now.Sub(start),
+	}
+	if p.Unit == ProgressBytes {
+		p.Done = atomic.LoadUint64(&e.progressPosition)
+	} else {
+		p.Unit = ProgressItems
+		p.Done = p.Items
+	}
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.ItemsPerSecond = float64(p.Items) / secs
+	}
+	if p.Total > 0 && p.Done > 0 {
+		ratio := float64(p.Done) / float64(p.Total)
+		if ratio > 1 {
+			ratio = 1
+		}
+		p.Percent = ratio * 100
+		p.ETA = time.Duration(float64(p.Elapsed) * (1 - ratio) / ratio)
+		p.EstimatedEnd = now.Add(p.ETA)
+	}
+	return p
+}
+
+// SetProgressTotal sets the total of the StepExecution held by ctx.
+// Readers call it to enable the estimation of the completion time.
+// It does nothing if ctx has no StepExecution.
+func SetProgressTotal(ctx context.Context, total uint64, unit ProgressUnit) {
+	if se := StepExecutionFromContext(ctx); se != nil {
+		se.SetProgressTotal(total, unit)
+	}
+}
+
+// SetProgressPosition sets the position of the StepExecution held by ctx.
+// Readers reporting the total in ProgressBytes call it as they read.
+// It does nothing if ctx has no StepExecution.
+func SetProgressPosition(ctx context.Context, pos uint64) {
+	if se := StepExecutionFromContext(ctx); se != nil {
+		se.SetProgressPosition(pos)
+	}
+}
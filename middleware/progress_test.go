@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	se := NewStepExecution(nil, "FooStep")
+	se.Start()
+	se.StartTime = time.Now().Add(-10 * time.Second)
+
+	t.Run("Without total", func(t *testing.T) {
+		se.AddWriteCount(100)
+		p := se.Progress()
+		assert.Equal(t, ProgressItems, p.Unit)
+		assert.Equal(t, uint64(100), p.Done)
+		assert.Zero(t, p.Total)
+		assert.InDelta(t, 10.0, p.ItemsPerSecond, 0.1)
+		assert.Zero(t, p.ETA)
+		assert.True(t, p.EstimatedEnd.IsZero())
+		assert.Equal(t, "100 items, 10.0 items/s", p.String())
+	})
+
+	t.Run("With total of items", func(t *testing.T) {
+		se.SetProgressTotal(400, ProgressItems)
+		p := se.Progress()
+		assert.Equal(t, uint64(400), p.Total)
+		assert.InDelta(t, 25.0, p.Percent, 0.001)
+		assert.InDelta(t, float64(30*time.Second), float64(p.ETA), float64(100*time.Millisecond))
+		assert.WithinDuration(t, time.Now().Add(30*time.Second), p.EstimatedEnd, time.Second)
+		assert.Contains(t, p.String(), "100/400 items (25.0%), 10.0 items/s, ETA 30s")
+	})
+
+	t.Run("With total of bytes", func(t *testing.T) {
+		se.SetProgressTotal(1000, ProgressBytes)
+		se.SetProgressPosition(500)
+		p := se.Progress()
+		assert.Equal(t, ProgressBytes, p.Unit)
+		assert.Equal(t, uint64(500), p.Done)
+		assert.Equal(t, uint64(100), p.Items)
+		assert.InDelta(t, 50.0, p.Percent, 0.001)
+		assert.InDelta(t, float64(10*time.Second), float64(p.ETA), float64(100*time.Millisecond))
+	})
+}
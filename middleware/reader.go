@@ -7,5 +7,7 @@ type Reader interface {
 
 	// Read should close the channel at the end of the process
 	// or call defer close(channel).
+	// It must return soon after ctx is canceled,
+	// since the step waits for it to return even if the step is canceled.
 	Read(ctx context.Context, ch chan<- interface{}) error
 }
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Record is the sending data type keeping the Go types of the values
+// and NULL, e.g. scanned from database.
+type Record struct {
+	Columns []string
+
+	// Values are the values of Columns.
+	// The value of NULL is nil.
+	Values []interface{}
+}
+
+func NewRecord(columns []string, values []interface{}) *Record {
+	return &Record{
+		Columns: columns,
+		Values:  values,
+	}
+}
+
+// Get returns the value of column.
+// The 2nd return value reports whether column exists.
+func (r *Record) Get(column string) (interface{}, bool) {
+	for i, col := range r.Columns {
+		if col == column {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// IsNull reports whether the value of column is NULL.
+// It returns false if column does not exist.
+func (r *Record) IsNull(column string) bool {
+	v, ok := r.Get(column)
+	return ok && v == nil
+}
+
+// ToMapMapper converts the record to MapMapperType.
+// NULL is converted to the empty string and time.Time to RFC 3339 format.
+func (r *Record) ToMapMapper() MapMapperType {
+	resultSet := make(MapMapperType, len(r.Columns))
+	for i, col := range r.Columns {
+		resultSet[col] = FormatValue(r.Values[i])
+	}
+	return resultSet
+}
+
+// FormatValue formats the value of Record as string.
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	record := NewRecord(
+		[]string{"id", "name", "price", "active", "joined_at", "data"},
+		[]interface{}{int64(1), nil, 1.25, true, time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC), []byte("ab")},
+	)
+
+	v, ok := record.Get("id")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), v)
+	_, ok = record.Get("unknown")
+	assert.False(t, ok)
+
+	assert.True(t, record.IsNull("name"))
+	assert.False(t, record.IsNull("id"))
+	assert.False(t, record.IsNull("unknown"))
+
+	assert.Equal(t, MapMapperType{
+		"id":        "1",
+		"name":      "",
+		"price":     "1.25",
+		"active":    "true",
+		"joined_at": "2026-10-01T09:00:00Z",
+		"data":      "ab",
+	}, record.ToMapMapper())
+}
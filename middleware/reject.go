@@ -0,0 +1,62 @@
+package middleware
+
+import "context"
+
+// Reject is a record rejected by a reader or a writer
+// instead of failing the step.
+type Reject struct {
+	// StepName is the name of the step.
+	StepName string
+
+	// Source is the name of the input or the output, e.g. the path of the file.
+	Source string
+
+	// Line is the line number of the record in the input.
+	// It is 0 if unknown.
+	Line int
+
+	// Item is the number of the item in the chunk written, starting from 1.
+	// It is 0 if the record is rejected by a reader.
+	Item int
+
+	// Raw is the original line of the record.
+	// It is empty if not available.
+	Raw string
+
+	// Values are the fields or the values of the record.
+	Values []string
+
+	// Err is the cause of the rejection.
+	Err error
+}
+
+// RejectWriter receives the rejected records.
+// WriteReject may be called concurrently by the steps.
+type RejectWriter interface {
+	WriteReject(ctx context.Context, reject *Reject) error
+}
+
+// RejectWriterFunc is the function implementing RejectWriter.
+type RejectWriterFunc func(ctx context.Context, reject *Reject) error
+
+func (f RejectWriterFunc) WriteReject(ctx context.Context, reject *Reject) error {
+	return f(ctx, reject)
+}
+
+// WriteReject sets the step name held by ctx to reject
+// and sends it to w, counting it as skipped.
+// The error is returned only if w fails, which should fail the step.
+func WriteReject(ctx context.Context, w RejectWriter, reject *Reject) error {
+	if se := StepExecutionFromContext(ctx); se != nil {
+		reject.StepName = se.StepName
+	}
+	AddSkipCount(ctx, 1)
+	if reject.Line > 0 {
+		LoggerFromContext(ctx).Warnf("Rejected the record at line %d: %v", reject.Line, reject.Err)
+	} else if reject.Item > 0 {
+		LoggerFromContext(ctx).Warnf("Rejected the item %d of the chunk: %v", reject.Item, reject.Err)
+	} else {
+		LoggerFromContext(ctx).Warnf("Rejected the record: %v", reject.Err)
+	}
+	return w.WriteReject(ctx, reject)
+}
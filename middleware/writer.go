@@ -6,5 +6,9 @@ import (
 
 // Writer receives data from channel and writes to datasource.
 type Writer interface {
+
+	// Write should return when the channel is closed.
+	// It must return soon after ctx is canceled,
+	// since the step waits for it to return even if the step is canceled.
 	Write(ctx context.Context, ch <-chan interface{}) error
 }
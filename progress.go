@@ -0,0 +1,79 @@
+package wolfx
+
+import (
+	"context"
+	"github.com/yackrru/wolfx/middleware"
+	"sync"
+	"time"
+)
+
+// progressReporter logs the progress of running steps
+// and notifies ProgressListeners of it.
+type progressReporter struct {
+	interval time.Duration
+	chunks   uint64
+
+	mu    sync.Mutex
+	stops map[*middleware.StepExecution]func()
+}
+
+func newProgressReporter(interval time.Duration, chunks uint64) *progressReporter {
+	return &progressReporter{
+		interval: interval,
+		chunks:   chunks,
+		stops:    make(map[*middleware.StepExecution]func()),
+	}
+}
+
+func (r *progressReporter) BeforeStep(ctx context.Context, se *middleware.StepExecution) context.Context {
+	if r.interval <= 0 {
+		return ctx
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.report(ctx, se)
+			}
+		}
+	}()
+
+	r.mu.Lock()
+	r.stops[se] = func() {
+		close(done)
+		wg.Wait()
+	}
+	r.mu.Unlock()
+	return ctx
+}
+
+func (r *progressReporter) AfterStep(ctx context.Context, se *middleware.StepExecution) {
+	r.mu.Lock()
+	stop, ok := r.stops[se]
+	delete(r.stops, se)
+	r.mu.Unlock()
+	if ok {
+		stop()
+	}
+}
+
+func (r *progressReporter) AfterChunk(ctx context.Context, ce *middleware.ChunkExecution) {
+	if r.chunks > 0 && (ce.Index+1)%r.chunks == 0 {
+		r.report(ctx, ce.StepExecution)
+	}
+}
+
+func (r *progressReporter) report(ctx context.Context, se *middleware.StepExecution) {
+	p := se.Progress()
+	middleware.LoggerFromContext(ctx).Infof("Progress: %s", p)
+	listenersFromContext(ctx).progress(ctx, p)
+}
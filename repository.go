@@ -0,0 +1,128 @@
+package wolfx
+
+import (
+	"errors"
+	"github.com/yackrru/wolfx/middleware"
+	"sync"
+)
+
+var _ JobRepository = new(MemoryJobRepository)
+
+// ErrJobExecutionNotFound is returned when the job repository
+// has no job execution of the ID.
+var ErrJobExecutionNotFound = errors.New("Not found job execution")
+
+// JobRepository stores the records of job executions.
+type JobRepository interface {
+	// SaveJobExecution stores the record.
+	// If ID of the record is 0, it assigns a new ID to the record.
+	// Otherwise it replaces the record of the same ID.
+	SaveJobExecution(rec *middleware.JobExecutionRecord) error
+
+	// FindJobExecution returns the record of the ID.
+	FindJobExecution(id int64) (*middleware.JobExecutionRecord, error)
+
+	// FindJobExecutions returns the records matching the query
+	// in descending order of ID.
+	FindJobExecutions(q JobExecutionQuery) (*JobExecutionPage, error)
+}
+
+// JobExecutionQuery is the condition of JobRepository.FindJobExecutions.
+type JobExecutionQuery struct {
+	// JobName filters records by the job name if it is not empty.
+	JobName string
+
+	// Offset is the number of records to skip.
+	Offset int
+
+	// Limit is the maximum number of records to return.
+	// If specify 0, all records are returned.
+	Limit int
+}
+
+// JobExecutionPage is the result of JobRepository.FindJobExecutions.
+type JobExecutionPage struct {
+	// Total is the number of records matching the query before paging.
+	Total  int                             `json:"total"`
+	Offset int                             `json:"offset"`
+	Limit  int                             `json:"limit"`
+	Items  []middleware.JobExecutionRecord `json:"items"`
+}
+
+// MemoryJobRepository is an implementation of JobRepository.
+// It holds the records in memory, so that they are lost at the end of process.
+type MemoryJobRepository struct {
+	mu      sync.Mutex
+	records []middleware.JobExecutionRecord
+	lastID  int64
+}
+
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return new(MemoryJobRepository)
+}
+
+func (r *MemoryJobRepository) SaveJobExecution(rec *middleware.JobExecutionRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rec.ID == 0 {
+		r.lastID++
+		rec.ID = r.lastID
+		r.records = append(r.records, *rec)
+		return nil
+	}
+	for i := range r.records {
+		if r.records[i].ID == rec.ID {
+			r.records[i] = *rec
+			return nil
+		}
+	}
+	return ErrJobExecutionNotFound
+}
+
+func (r *MemoryJobRepository) FindJobExecution(id int64) (*middleware.JobExecutionRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.records {
+		if r.records[i].ID == id {
+			rec := r.records[i]
+			return &rec, nil
+		}
+	}
+	return nil, ErrJobExecutionNotFound
+}
+
+func (r *MemoryJobRepository) FindJobExecutions(q JobExecutionQuery) (*JobExecutionPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return pageJobExecutions(r.records, q), nil
+}
+
+// pageJobExecutions filters the records stored in ascending order of ID
+// and pages them in descending order.
+func pageJobExecutions(records []middleware.JobExecutionRecord,
+	q JobExecutionQuery) *JobExecutionPage {
+
+	page := &JobExecutionPage{
+		Offset: q.Offset,
+		Limit:  q.Limit,
+		Items:  []middleware.JobExecutionRecord{},
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if q.JobName != "" && rec.JobName != q.JobName {
+			continue
+		}
+		page.Total++
+		if page.Total <= q.Offset {
+			continue
+		}
+		if q.Limit > 0 && len(page.Items) >= q.Limit {
+			continue
+		}
+		page.Items = append(page.Items, rec)
+	}
+	return page
+}
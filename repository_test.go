@@ -0,0 +1,51 @@
+package wolfx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/wolfx/middleware"
+	"testing"
+)
+
+func TestMemoryJobRepository(t *testing.T) {
+	repo := NewMemoryJobRepository()
+	for _, name := range []string{"FooJob", "BarJob", "FooJob", "FooJob"} {
+		rec := &middleware.JobExecutionRecord{JobName: name}
+		if err := repo.SaveJobExecution(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := &middleware.JobExecutionRecord{ID: 2, JobName: "BarJob", Status: middleware.StatusCompleted}
+	if err := repo.SaveJobExecution(rec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.FindJobExecution(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, middleware.StatusCompleted, got.Status)
+
+	_, err = repo.FindJobExecution(5)
+	assert.ErrorIs(t, err, ErrJobExecutionNotFound)
+	err = repo.SaveJobExecution(&middleware.JobExecutionRecord{ID: 5})
+	assert.ErrorIs(t, err, ErrJobExecutionNotFound)
+
+	page, err := repo.FindJobExecutions(JobExecutionQuery{JobName: "FooJob", Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, int64(3), page.Items[0].ID)
+
+	page, err = repo.FindJobExecutions(JobExecutionQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, page.Total)
+	var ids []int64
+	for _, item := range page.Items {
+		ids = append(ids, item.ID)
+	}
+	assert.Equal(t, []int64{4, 3, 2, 1}, ids)
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+var _ Exporter = new(JSONExporter)
+
+// JSONExporter is an implementation of Exporter.
+// It writes each span as a JSON object per line,
+// so that spans are available without a collector.
+type JSONExporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONExporter returns a JSONExporter writing spans to w.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// NewJSONFileExporter returns a JSONExporter appending spans to the file at path.
+// Close must be called to close the file.
+func NewJSONFileExporter(path string) (*JSONExporter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e := NewJSONExporter(file)
+	e.closer = file
+	return e, nil
+}
+
+func (e *JSONExporter) ExportSpan(s *SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.encoder.Encode(s)
+}
+
+// Close closes the file opened by NewJSONFileExporter.
+func (e *JSONExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
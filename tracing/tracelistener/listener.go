@@ -0,0 +1,133 @@
+package tracelistener
+
+import (
+	"context"
+	"fmt"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"strings"
+	"sync"
+)
+
+var (
+	_ wolfx.JobListener         = new(Listener)
+	_ wolfx.FlowListener        = new(Listener)
+	_ wolfx.StepListener        = new(Listener)
+	_ wolfx.ChunkListener       = new(Listener)
+	_ wolfx.BeforeChunkListener = new(Listener)
+)
+
+// Listener opens a span per job, flow, step and chunk.
+// The spans of job, flow and step are carried by the context
+// passed to Step, so that readers and writers can start child spans
+// by tracing.StartSpan.
+// The span of a chunk begins when the reader begins reading it
+// and ends when the writer receives it,
+// so that the spans started by the reader and the writer
+// with the context given by middleware.NextChunkContext are its children.
+type Listener struct {
+	tracer tracing.Tracer
+
+	mu sync.Mutex
+	// chunks are the spans of the chunks being read by the running steps.
+	chunks map[*middleware.StepExecution]map[uint64]tracing.Span
+}
+
+func NewListener(tracer tracing.Tracer) *Listener {
+	return &Listener{
+		tracer: tracer,
+		chunks: make(map[*middleware.StepExecution]map[uint64]tracing.Span),
+	}
+}
+
+func (l *Listener) BeforeJob(ctx context.Context, je *middleware.JobExecution) context.Context {
+	ctx = tracing.ContextWithTracer(ctx, l.tracer)
+	ctx, _ = l.tracer.Start(ctx, "job "+je.JobName,
+		tracing.WithTimestamp(je.StartTime),
+		tracing.WithAttributes(tracing.String(tracing.AttrJobName, je.JobName)))
+	return ctx
+}
+
+func (l *Listener) AfterJob(ctx context.Context, je *middleware.JobExecution) {
+	span := tracing.SpanFromContext(ctx)
+	span.RecordError(je.Err)
+	span.End(tracing.WithTimestamp(je.EndTime))
+}
+
+func (l *Listener) BeforeFlow(ctx context.Context, fe *middleware.FlowExecution) context.Context {
+	ctx, _ = l.tracer.Start(ctx, fmt.Sprintf("flow %d", fe.Index),
+		tracing.WithTimestamp(fe.StartTime),
+		tracing.WithAttributes(
+			tracing.Int(tracing.AttrFlowIndex, int64(fe.Index)),
+			tracing.String(tracing.AttrFlowSteps, strings.Join(fe.StepNames, ","))))
+	return ctx
+}
+
+func (l *Listener) AfterFlow(ctx context.Context, fe *middleware.FlowExecution) {
+	span := tracing.SpanFromContext(ctx)
+	span.RecordError(fe.Err)
+	span.End(tracing.WithTimestamp(fe.EndTime))
+}
+
+func (l *Listener) BeforeStep(ctx context.Context, se *middleware.StepExecution) context.Context {
+	ctx, _ = l.tracer.Start(ctx, "step "+se.StepName,
+		tracing.WithTimestamp(se.StartTime),
+		tracing.WithAttributes(tracing.String(tracing.AttrStepName, se.StepName)))
+	return ctx
+}
+
+func (l *Listener) AfterStep(ctx context.Context, se *middleware.StepExecution) {
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttributes(
+		tracing.Int(tracing.AttrStepReadCount, int64(se.ReadCount())),
+		tracing.Int(tracing.AttrStepWriteCount, int64(se.WriteCount())),
+		tracing.Int(tracing.AttrStepSkipCount, int64(se.SkipCount())))
+	span.RecordError(se.Err)
+
+	l.mu.Lock()
+	chunks := l.chunks[se]
+	delete(l.chunks, se)
+	l.mu.Unlock()
+	for _, chunk := range chunks {
+		// The reader has sent no more chunk.
+		chunk.SetAttributes(tracing.Int(tracing.AttrChunkItems, 0))
+		chunk.End(tracing.WithTimestamp(se.EndTime))
+	}
+	span.End(tracing.WithTimestamp(se.EndTime))
+}
+
+func (l *Listener) BeforeChunk(ctx context.Context, ce *middleware.ChunkExecution) context.Context {
+	ctx, span := l.tracer.Start(ctx, fmt.Sprintf("chunk %d", ce.Index),
+		tracing.WithTimestamp(ce.StartTime),
+		tracing.WithAttributes(
+			tracing.String(tracing.AttrStepName, ce.StepExecution.StepName),
+			tracing.Int(tracing.AttrChunkIndex, int64(ce.Index))))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	chunks, ok := l.chunks[ce.StepExecution]
+	if !ok {
+		chunks = make(map[uint64]tracing.Span)
+		l.chunks[ce.StepExecution] = chunks
+	}
+	chunks[ce.Index] = span
+	return ctx
+}
+
+func (l *Listener) AfterChunk(ctx context.Context, ce *middleware.ChunkExecution) {
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttributes(
+		tracing.Int(tracing.AttrChunkItems, int64(ce.Items)),
+		tracing.Float(tracing.AttrChunkReadWait, ce.ReadWait.Seconds()),
+		tracing.Float(tracing.AttrChunkWriteWait, ce.WriteWait.Seconds()))
+	span.End(tracing.WithTimestamp(ce.EndTime))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	chunks, ok := l.chunks[ce.StepExecution]
+	if !ok {
+		return
+	}
+	delete(chunks, ce.Index)
+}
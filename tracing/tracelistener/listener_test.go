@@ -0,0 +1,128 @@
+package tracelistener
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"github.com/yackrru/wolfx/tracing"
+	"testing"
+)
+
+type TestJob struct{}
+
+func (j *TestJob) Name() string {
+	return "TestJob"
+}
+
+func (j *TestJob) Run() error {
+	return wolfx.NewJobBuilder().
+		Single(j.TestStep).
+		Build()
+}
+
+func (j *TestJob) TestStep(ctx context.Context) error {
+	return wolfx.NewStepBuilder(ctx).
+		SetReader(new(TestReader)).
+		SetWriter(new(TestWriter)).
+		Build()
+}
+
+type TestReader struct{}
+
+func (r *TestReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+	_, span := tracing.StartSpan(ctx, "query")
+	span.End()
+	chunks := [][]middleware.MapMapperType{
+		{{"id": "0"}, {"id": "1"}},
+		{{"id": "2"}},
+	}
+	for i, chunk := range chunks {
+		_, span := tracing.StartSpan(middleware.NextChunkContext(ctx), fmt.Sprintf("map %d", i))
+		span.End()
+		ch <- chunk
+	}
+	return nil
+}
+
+type TestWriter struct{}
+
+func (w *TestWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	var n int
+	for range ch {
+		_, span := tracing.StartSpan(middleware.NextChunkContext(ctx), fmt.Sprintf("flush %d", n))
+		span.End()
+		n++
+	}
+	return fmt.Errorf("TestWriter error")
+}
+
+func TestListener(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewJSONExporter(&buf))
+
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	wx.Add(new(TestJob)).AddListener(NewListener(tracer))
+	if err := wx.Run("TestJob"); err == nil {
+		t.Fatal("Want error but got nil")
+	}
+
+	spans := make(map[string]*tracing.SpanData)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		s := new(tracing.SpanData)
+		if err := json.Unmarshal(scanner.Bytes(), s); err != nil {
+			t.Fatal(err)
+		}
+		spans[s.Name] = s
+	}
+	assert.Len(t, spans, 11)
+
+	job := spans["job TestJob"]
+	flow := spans["flow 0"]
+	step := spans["step TestStep"]
+	assert.Empty(t, job.ParentSpanID)
+	assert.Equal(t, job.SpanID, flow.ParentSpanID)
+	assert.Equal(t, flow.SpanID, step.ParentSpanID)
+	assert.Equal(t, step.SpanID, spans["chunk 0"].ParentSpanID)
+	assert.Equal(t, step.SpanID, spans["chunk 1"].ParentSpanID)
+	assert.Equal(t, step.SpanID, spans["chunk 2"].ParentSpanID)
+	assert.Equal(t, step.SpanID, spans["query"].ParentSpanID)
+	// The spans of the reader and the writer are the children of the chunks.
+	assert.Equal(t, spans["chunk 0"].SpanID, spans["map 0"].ParentSpanID)
+	assert.Equal(t, spans["chunk 1"].SpanID, spans["map 1"].ParentSpanID)
+	assert.Equal(t, spans["chunk 0"].SpanID, spans["flush 0"].ParentSpanID)
+	assert.Equal(t, spans["chunk 1"].SpanID, spans["flush 1"].ParentSpanID)
+	for _, s := range spans {
+		assert.Equal(t, job.TraceID, s.TraceID)
+	}
+
+	assert.Equal(t, tracing.StatusError, job.Status)
+	assert.Equal(t, "TestWriter error", step.Error)
+	assert.Equal(t, "TestStep", step.Attributes[tracing.AttrStepName])
+	assert.EqualValues(t, 3, step.Attributes[tracing.AttrStepReadCount])
+	assert.EqualValues(t, 1, spans["chunk 1"].Attributes[tracing.AttrChunkIndex])
+	assert.EqualValues(t, 2, spans["chunk 0"].Attributes[tracing.AttrChunkItems])
+	// The reader has sent no more chunk.
+	assert.EqualValues(t, 0, spans["chunk 2"].Attributes[tracing.AttrChunkItems])
+	assert.Equal(t, tracing.StatusOK, spans["chunk 0"].Status)
+}
+
+func TestAfterChunkWithoutBeforeChunk(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewListener(tracing.NewTracer(tracing.NewJSONExporter(&buf)))
+	ce := &middleware.ChunkExecution{
+		StepExecution: middleware.NewStepExecution(nil, "TestStep"),
+	}
+	assert.NotPanics(t, func() {
+		l.AfterChunk(context.Background(), ce)
+	})
+}
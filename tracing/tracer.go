@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/yackrru/wolfx/middleware"
+	"sync"
+	"time"
+)
+
+var _ Tracer = new(InProcessTracer)
+
+// Exporter receives the spans ended.
+type Exporter interface {
+	ExportSpan(s *SpanData) error
+}
+
+// SpanData is the record of an ended span.
+type SpanData struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	StartTime    time.Time              `json:"start_time"`
+	EndTime      time.Time              `json:"end_time"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Status       string                 `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// InProcessTracer is an implementation of Tracer.
+// It records spans in process and passes them to Exporter on ending.
+type InProcessTracer struct {
+	exporter Exporter
+}
+
+func NewTracer(exporter Exporter) *InProcessTracer {
+	return &InProcessTracer{
+		exporter: exporter,
+	}
+}
+
+func (t *InProcessTracer) Start(ctx context.Context, name string,
+	opts ...SpanOption) (context.Context, Span) {
+
+	conf := NewSpanConfig(opts...)
+	s := &span{
+		tracer: t,
+		data: SpanData{
+			SpanID:     newID(8),
+			Name:       name,
+			StartTime:  conf.Timestamp,
+			Attributes: make(map[string]interface{}),
+			Status:     StatusOK,
+		},
+	}
+	if parent := SpanFromContext(ctx).SpanContext(); parent.IsValid() {
+		s.data.TraceID = parent.TraceID
+		s.data.ParentSpanID = parent.SpanID
+	} else {
+		s.data.TraceID = newID(16)
+	}
+	s.SetAttributes(conf.Attributes...)
+
+	return ContextWithSpan(ctx, s), s
+}
+
+type span struct {
+	tracer *InProcessTracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return SpanContext{
+		TraceID: s.data.TraceID,
+		SpanID:  s.data.SpanID,
+	}
+}
+
+func (s *span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	for _, attr := range attrs {
+		s.data.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Status = StatusError
+	s.data.Error = err.Error()
+}
+
+func (s *span) End(opts ...SpanOption) {
+	conf := NewSpanConfig(opts...)
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = conf.Timestamp
+	for _, attr := range conf.Attributes {
+		s.data.Attributes[attr.Key] = attr.Value
+	}
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer.exporter == nil {
+		return
+	}
+	if err := s.tracer.exporter.ExportSpan(&data); err != nil {
+		middleware.LoggerFromContext(context.Background()).
+			Error("Failed to export span: ", err)
+	}
+}
+
+func newID(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"time"
+)
+
+// Attribute keys set by the listener of wolfx in the tracelistener package
+// and the built-in readers and writers.
+const (
+	AttrJobName        = "wolfx.job.name"
+	AttrFlowIndex      = "wolfx.flow.index"
+	AttrFlowSteps      = "wolfx.flow.steps"
+	AttrStepName       = "wolfx.step.name"
+	AttrStepReadCount  = "wolfx.step.read_count"
+	AttrStepWriteCount = "wolfx.step.write_count"
+	AttrStepSkipCount  = "wolfx.step.skip_count"
+	AttrChunkIndex     = "wolfx.chunk.index"
+	AttrChunkItems     = "wolfx.chunk.items"
+	AttrChunkReadWait  = "wolfx.chunk.read_wait_seconds"
+	AttrChunkWriteWait = "wolfx.chunk.write_wait_seconds"
+)
+
+// Tracer creates spans.
+type Tracer interface {
+	// Start starts a span as a child of the span held by ctx
+	// and returns a copy of ctx holding the new span.
+	Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span)
+}
+
+// Span is a timed operation of a trace.
+type Span interface {
+	// SpanContext returns the identifiers of the span.
+	SpanContext() SpanContext
+
+	// SetAttributes sets attributes to the span.
+	SetAttributes(attrs ...Attribute)
+
+	// RecordError marks the span as failed by err.
+	// It does nothing if err is nil.
+	RecordError(err error)
+
+	// End completes the span.
+	End(opts ...SpanOption)
+}
+
+// SpanContext is the identifiers of a span propagated to its children.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// IsValid reports whether the SpanContext has both of identifiers.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+// Attribute is a key value pair of a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns an Attribute of string value.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an Attribute of integer value.
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Float returns an Attribute of float value.
+func Float(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanConfig is the configuration of starting or ending a span.
+type SpanConfig struct {
+	Timestamp  time.Time
+	Attributes []Attribute
+}
+
+// SpanOption configures SpanConfig.
+type SpanOption func(c *SpanConfig)
+
+// WithTimestamp sets the start or end time of a span instead of the current time.
+func WithTimestamp(t time.Time) SpanOption {
+	return func(c *SpanConfig) {
+		c.Timestamp = t
+	}
+}
+
+// WithAttributes sets attributes at starting a span.
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(c *SpanConfig) {
+		c.Attributes = append(c.Attributes, attrs...)
+	}
+}
+
+// NewSpanConfig applies opts to a SpanConfig.
+func NewSpanConfig(opts ...SpanOption) *SpanConfig {
+	c := new(SpanConfig)
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.Timestamp.IsZero() {
+		c.Timestamp = time.Now()
+	}
+	return c
+}
+
+type contextKey int
+
+const (
+	tracerKey contextKey = iota
+	spanKey
+)
+
+// ContextWithTracer returns a copy of ctx holding the Tracer.
+func ContextWithTracer(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, t)
+}
+
+// TracerFromContext returns the Tracer held by ctx.
+// It returns a no-op Tracer if ctx has no Tracer.
+func TracerFromContext(ctx context.Context) Tracer {
+	if t, ok := ctx.Value(tracerKey).(Tracer); ok {
+		return t
+	}
+	return noopTracer{}
+}
+
+// ContextWithSpan returns a copy of ctx holding the Span.
+func ContextWithSpan(ctx context.Context, s Span) context.Context {
+	return context.WithValue(ctx, spanKey, s)
+}
+
+// SpanFromContext returns the Span held by ctx.
+// It returns a no-op Span if ctx has no Span.
+func SpanFromContext(ctx context.Context) Span {
+	if s, ok := ctx.Value(spanKey).(Span); ok {
+		return s
+	}
+	return noopSpan{}
+}
+
+// StartSpan starts a span with the Tracer held by ctx.
+// Readers and writers use it to trace their own operations.
+func StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span) {
+	return TracerFromContext(ctx).Start(ctx, name, opts...)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SpanContext() SpanContext         { return SpanContext{} }
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End(opts ...SpanOption)           {}
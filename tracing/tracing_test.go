@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartSpanWithoutTracer(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "noop")
+	span.SetAttributes(String("key", "value"))
+	span.End()
+	assert.False(t, span.SpanContext().IsValid())
+	assert.Equal(t, span, SpanFromContext(ctx))
+}
+
+func TestJSONFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	exporter, err := NewJSONFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := NewTracer(exporter)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child", WithAttributes(Int("n", 1)))
+	child.End()
+	parent.End()
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var got SpanData
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "child", got.Name)
+	assert.Equal(t, parent.SpanContext().SpanID, got.ParentSpanID)
+	assert.EqualValues(t, 1, got.Attributes["n"])
+}
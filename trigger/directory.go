@@ -0,0 +1,317 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultParameterName is the job parameter of the path of the file.
+	DefaultParameterName = "file"
+
+	// DefaultPollInterval is the interval to scan the directory.
+	DefaultPollInterval = 5 * time.Second
+
+	// DefaultProcessedDir and DefaultFailedDir are the subdirectories
+	// the files are moved to after the job.
+	DefaultProcessedDir = "processed"
+	DefaultFailedDir    = "failed"
+
+	// DefaultLedgerName is the file of the ledger in the directory.
+	DefaultLedgerName = ".wolfx-ledger.jsonl"
+)
+
+// DirectoryPoller watches a directory for files matching a pattern
+// and runs the job for each file once its size stops changing.
+// After the job, the file is moved to the processed or failed subdirectory
+// and recorded in the ledger so that it is never picked up twice.
+//
+// The hidden files beginning with "." are ignored,
+// e.g. the temp files of the atomic output and the ledger.
+type DirectoryPoller struct {
+	conf *DirectoryPollerConfig
+
+	// observed are the sizes and the modification times
+	// of the files not stable yet.
+	observed map[string]observation
+}
+
+// DirectoryPollerConfig is the configuration of DirectoryPoller.
+type DirectoryPollerConfig struct {
+	WolfX *wolfx.WolfX
+
+	// JobName is the job run for each file.
+	JobName string
+
+	// ParameterName is the job parameter of the path of the file.
+	// If it is empty, DefaultParameterName is used.
+	ParameterName string
+
+	// Parameters are the other job parameters.
+	Parameters middleware.JobParameters
+
+	// Dir is the directory to watch.
+	Dir string
+
+	// Pattern is the pattern of the file names in the syntax of filepath.Match.
+	// If it is empty, all files match.
+	Pattern string
+
+	// Interval is the interval to scan the directory.
+	// If specify 0, DefaultPollInterval is used.
+	Interval time.Duration
+
+	// StableDuration is the duration the size and the modification time
+	// of a file must stay unchanged before the job runs.
+	// If specify 0, Interval is used, i.e. the file is unchanged
+	// between two scans.
+	StableDuration time.Duration
+
+	// ProcessedDir and FailedDir are the directories the files are moved to
+	// after the job completes or fails.
+	// Relative paths are in Dir.
+	// If they are empty, DefaultProcessedDir and DefaultFailedDir are used.
+	ProcessedDir string
+	FailedDir    string
+
+	// Ledger remembers the processed files.
+	// If it is nil, FileLedger of DefaultLedgerName in Dir is used.
+	Ledger Ledger
+}
+
+// observation is the state of a file at a scan.
+type observation struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+func NewDirectoryPoller(conf *DirectoryPollerConfig) *DirectoryPoller {
+	return &DirectoryPoller{
+		conf:     conf,
+		observed: make(map[string]observation),
+	}
+}
+
+// Run scans the directory at Interval until ctx is done.
+// It returns nil when ctx is done
+// and the error if the files cannot be scanned or moved.
+func (p *DirectoryPoller) Run(ctx context.Context) error {
+	interval := p.conf.Interval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll scans the directory once and runs the job for each stable file
+// in order of name.
+// The failure of the job is not returned but the file is moved to FailedDir.
+// If ctx is done while the job is running, the job is requested to stop
+// and the file is left in Dir without being recorded after the job ends.
+func (p *DirectoryPoller) Poll(ctx context.Context) error {
+	ledger := p.ledger()
+	files, err := p.scan()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return nil
+		}
+		processed, err := ledger.Processed(f)
+		if err != nil {
+			return err
+		}
+		if processed {
+			// The job has completed but the file was not moved.
+			if err := p.move(f, p.processedDir()); err != nil {
+				return err
+			}
+			continue
+		}
+		if !p.stable(f) {
+			continue
+		}
+
+		errJob := p.runJob(ctx, f)
+		if ctx.Err() != nil {
+			// The job is stopped and the file is retried after the restart.
+			return nil
+		}
+		delete(p.observed, f.Name)
+		if errors.Is(errJob, wolfx.ErrJobNotFound) {
+			return errJob
+		}
+		if err := ledger.Record(f, errJob); err != nil {
+			return err
+		}
+		dir := p.processedDir()
+		if errJob != nil {
+			dir = p.failedDir()
+		}
+		if err := p.move(f, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan returns the files matching Pattern in order of name
+// and forgets the files removed.
+func (p *DirectoryPoller) scan() ([]File, error) {
+	entries, err := os.ReadDir(p.conf.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if p.conf.Pattern != "" {
+			matched, err := filepath.Match(p.conf.Pattern, name)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		seen[name] = true
+		files = append(files, File{
+			Path:    filepath.Join(p.conf.Dir, name),
+			Name:    name,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	for name := range p.observed {
+		if !seen[name] {
+			delete(p.observed, name)
+		}
+	}
+	return files, nil
+}
+
+// stable reports whether the file has been unchanged for StableDuration.
+func (p *DirectoryPoller) stable(f File) bool {
+	now := time.Now()
+	prev, ok := p.observed[f.Name]
+	if !ok || prev.size != f.Size || !prev.modTime.Equal(f.ModTime) {
+		p.observed[f.Name] = observation{size: f.Size, modTime: f.ModTime, since: now}
+		return false
+	}
+
+	duration := p.conf.StableDuration
+	if duration == 0 {
+		duration = p.conf.Interval
+		if duration == 0 {
+			duration = DefaultPollInterval
+		}
+	}
+	return now.Sub(prev.since) >= duration
+}
+
+// runJob runs the job for f and waits until it is closed.
+// If ctx is done, the job is requested to stop and waited.
+func (p *DirectoryPoller) runJob(ctx context.Context, f File) error {
+	name := p.conf.ParameterName
+	if name == "" {
+		name = DefaultParameterName
+	}
+	params := make(middleware.JobParameters, len(p.conf.Parameters)+1)
+	for k, v := range p.conf.Parameters {
+		params[k] = v
+	}
+	params[name] = f.Path
+
+	je, err := p.conf.WolfX.Launch(p.conf.JobName, params)
+	if err != nil {
+		return err
+	}
+	middleware.LoggerFromContext(middleware.WithJobExecution(context.Background(), je)).
+		Infof("Processing file: %s", f.Path)
+	select {
+	case <-je.Done():
+		return je.Wait()
+	case <-ctx.Done():
+		je.Stop()
+		je.Wait()
+		return ctx.Err()
+	}
+}
+
+// move moves the file to dir.
+// If a file of the same name exists in dir,
+// the suffix of the modification time is added.
+func (p *DirectoryPoller) move(f File, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	dest := filepath.Join(dir, f.Name)
+	if _, err := os.Stat(dest); err == nil {
+		dest = fmt.Sprintf("%s.%s", dest, f.ModTime.Format("20060102150405.000000000"))
+	}
+	return os.Rename(f.Path, dest)
+}
+
+func (p *DirectoryPoller) processedDir() string {
+	return p.subdir(p.conf.ProcessedDir, DefaultProcessedDir)
+}
+
+func (p *DirectoryPoller) failedDir() string {
+	return p.subdir(p.conf.FailedDir, DefaultFailedDir)
+}
+
+func (p *DirectoryPoller) subdir(dir, defaultDir string) string {
+	if dir == "" {
+		dir = defaultDir
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(p.conf.Dir, dir)
+}
+
+func (p *DirectoryPoller) ledger() Ledger {
+	if p.conf.Ledger == nil {
+		p.conf.Ledger = NewFileLedger(filepath.Join(p.conf.Dir, DefaultLedgerName))
+	}
+	return p.conf.Ledger
+}
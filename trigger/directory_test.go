@@ -0,0 +1,308 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ImportJob reads the file of the job parameter
+// and fails if the content is "fail".
+// If the content is "block", it blocks until release is closed.
+type ImportJob struct {
+	mu    sync.Mutex
+	files []string
+
+	started chan struct{}
+	release chan struct{}
+}
+
+func (j *ImportJob) Name() string {
+	return "ImportJob"
+}
+
+func (j *ImportJob) Run() error {
+	return j.RunContext(context.Background())
+}
+
+func (j *ImportJob) RunContext(ctx context.Context) error {
+	return wolfx.NewJobBuilderContext(ctx).
+		Single(j.ImportStep).
+		Build()
+}
+
+func (j *ImportJob) ImportStep(ctx context.Context) error {
+	return wolfx.NewStepBuilder(ctx).
+		SetReader(&FileReader{job: j}).
+		SetWriter(new(DiscardWriter)).
+		Build()
+}
+
+type FileReader struct {
+	job *ImportJob
+}
+
+func (r *FileReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+	params := middleware.JobParametersFromContext(ctx)
+	b, err := os.ReadFile(params["file"])
+	if err != nil {
+		return err
+	}
+	r.job.mu.Lock()
+	r.job.files = append(r.job.files, filepath.Base(params["file"])+":"+params["env"])
+	r.job.mu.Unlock()
+	if string(b) == "fail" {
+		return errors.New("Invalid file")
+	}
+	if string(b) == "block" {
+		close(r.job.started)
+		<-r.job.release
+	}
+	ch <- b
+	return nil
+}
+
+type DiscardWriter struct{}
+
+func (w *DiscardWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	for range ch {
+	}
+	return nil
+}
+
+func newTestPoller(t *testing.T) (*ImportJob, *DirectoryPoller, string) {
+	middleware.Logger = gogger.NewLog(&gogger.LogConfig{LogMinLevel: gogger.LevelOff})
+	job := new(ImportJob)
+	wx := wolfx.New()
+	wx.Add(job)
+
+	dir := t.TempDir()
+	poller := NewDirectoryPoller(&DirectoryPollerConfig{
+		WolfX:          wx,
+		JobName:        "ImportJob",
+		Parameters:     middleware.JobParameters{"env": "test"},
+		Dir:            dir,
+		Pattern:        "*.csv",
+		StableDuration: time.Millisecond,
+	})
+	return job, poller, dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pollTwice(t *testing.T, poller *DirectoryPoller) {
+	assert.NoError(t, poller.Poll(context.TODO()))
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, poller.Poll(context.TODO()))
+}
+
+func TestPoll(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	writeFile(t, filepath.Join(dir, "a.csv"), "ok")
+	writeFile(t, filepath.Join(dir, "b.csv"), "fail")
+	writeFile(t, filepath.Join(dir, "c.txt"), "ok")
+	writeFile(t, filepath.Join(dir, ".d.csv"), "ok")
+
+	// The files are not stable at the 1st scan.
+	assert.NoError(t, poller.Poll(context.TODO()))
+	assert.Empty(t, job.files)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, poller.Poll(context.TODO()))
+	assert.Equal(t, []string{"a.csv:test", "b.csv:test"}, job.files)
+
+	assert.FileExists(t, filepath.Join(dir, "processed", "a.csv"))
+	assert.FileExists(t, filepath.Join(dir, "failed", "b.csv"))
+	assert.FileExists(t, filepath.Join(dir, "c.txt"))
+	assert.FileExists(t, filepath.Join(dir, ".d.csv"))
+	assert.NoFileExists(t, filepath.Join(dir, "a.csv"))
+	assert.NoFileExists(t, filepath.Join(dir, "b.csv"))
+}
+
+func TestPollWaitsForStableSize(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	path := filepath.Join(dir, "a.csv")
+	writeFile(t, path, "o")
+
+	assert.NoError(t, poller.Poll(context.TODO()))
+	time.Sleep(5 * time.Millisecond)
+	writeFile(t, path, "ok")
+	assert.NoError(t, poller.Poll(context.TODO()))
+	assert.Empty(t, job.files)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, poller.Poll(context.TODO()))
+	assert.Equal(t, []string{"a.csv:test"}, job.files)
+}
+
+func TestPollSkipsProcessedFiles(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	path := filepath.Join(dir, "a.csv")
+	writeFile(t, path, "ok")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pollTwice(t, poller)
+	assert.Len(t, job.files, 1)
+
+	// The same file is put back, e.g. the move failed after the job.
+	if err := os.Rename(filepath.Join(dir, "processed", "a.csv"), path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ledger is reloaded by another poller, e.g. after the restart.
+	poller = NewDirectoryPoller(poller.conf)
+	poller.conf.Ledger = nil
+	assert.NoError(t, poller.Poll(context.TODO()))
+	assert.Len(t, job.files, 1)
+	assert.NoFileExists(t, path)
+	entries, err := os.ReadDir(filepath.Join(dir, "processed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, entries, 1)
+
+	// The file of the same name with other contents is processed.
+	writeFile(t, path, "ok2")
+	pollTwice(t, poller)
+	assert.Len(t, job.files, 2)
+}
+
+func TestPollRetriesFailedFiles(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	path := filepath.Join(dir, "a.csv")
+	writeFile(t, path, "fail")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pollTwice(t, poller)
+	assert.Len(t, job.files, 1)
+
+	// The failed file is put back after the fix of the job.
+	if err := os.Rename(filepath.Join(dir, "failed", "a.csv"), path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	poller = NewDirectoryPoller(poller.conf)
+	poller.conf.Ledger = nil
+	pollTwice(t, poller)
+	assert.Len(t, job.files, 2)
+	assert.NoFileExists(t, path)
+}
+
+func TestPollWithCancel(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	job.started = make(chan struct{})
+	job.release = make(chan struct{})
+	path := filepath.Join(dir, "a.csv")
+	writeFile(t, path, "block")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, poller.Poll(ctx))
+	time.Sleep(5 * time.Millisecond)
+	go func() {
+		<-job.started
+		cancel()
+		// The job is requested to stop while blocking.
+		for {
+			executions := poller.conf.WolfX.RunningExecutions()
+			if len(executions) == 1 && executions[0].IsStopping() {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(job.release)
+	}()
+	assert.NoError(t, poller.Poll(ctx))
+
+	// The job has stopped when Poll returns.
+	assert.Empty(t, poller.conf.WolfX.RunningExecutions())
+	rec, err := poller.conf.WolfX.FindJobExecution(1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, middleware.StatusStopped, rec.Status)
+	}
+
+	// The file is neither moved nor recorded.
+	assert.FileExists(t, path)
+	assert.NoFileExists(t, filepath.Join(dir, DefaultLedgerName))
+}
+
+func TestPollWhileJobRunning(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	job.started = make(chan struct{})
+	job.release = make(chan struct{})
+	blocking := filepath.Join(t.TempDir(), "blocking.csv")
+	writeFile(t, blocking, "block")
+	writeFile(t, filepath.Join(dir, "a.csv"), "ok")
+
+	assert.NoError(t, poller.Poll(context.TODO()))
+	je, err := poller.conf.WolfX.Launch("ImportJob", middleware.JobParameters{"file": blocking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-job.started
+	time.Sleep(5 * time.Millisecond)
+	// The file is processed while another job is running.
+	assert.NoError(t, poller.Poll(context.TODO()))
+	assert.FileExists(t, filepath.Join(dir, "processed", "a.csv"))
+
+	close(job.release)
+	assert.NoError(t, je.Wait())
+}
+
+func TestPollWithJobNotFound(t *testing.T) {
+	_, poller, dir := newTestPoller(t)
+	poller.conf.JobName = "UnknownJob"
+	writeFile(t, filepath.Join(dir, "a.csv"), "ok")
+
+	assert.NoError(t, poller.Poll(context.TODO()))
+	time.Sleep(5 * time.Millisecond)
+	assert.ErrorIs(t, poller.Poll(context.TODO()), wolfx.ErrJobNotFound)
+	assert.FileExists(t, filepath.Join(dir, "a.csv"))
+}
+
+func TestRun(t *testing.T) {
+	job, poller, dir := newTestPoller(t)
+	poller.conf.Interval = time.Millisecond
+	writeFile(t, filepath.Join(dir, "a.csv"), "ok")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- poller.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(filepath.Join(dir, "processed", "a.csv")); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	assert.NoError(t, <-errCh)
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	assert.Equal(t, []string{"a.csv:test"}, job.files)
+}
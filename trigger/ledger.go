@@ -0,0 +1,165 @@
+package trigger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a file found in the directory.
+type File struct {
+	// Path is the path of the file in the directory.
+	Path string
+
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Ledger remembers the files processed by DirectoryPoller.
+// A file is identified by its name, size and modification time,
+// so that a file of the same name dropped again with other contents
+// is processed again.
+type Ledger interface {
+	// Processed reports whether the job has completed for f.
+	// The file of the failed job is not regarded as processed.
+	Processed(f File) (bool, error)
+
+	// Record records the result of the job for f.
+	// Arg err is the error of the job or nil if it completed.
+	Record(f File, err error) error
+}
+
+// LedgerEntry is a line of the file written by FileLedger.
+type LedgerEntry struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+const (
+	LedgerStatusCompleted = "COMPLETED"
+	LedgerStatusFailed    = "FAILED"
+)
+
+// FileLedger is an implementation of Ledger.
+// It appends an entry in JSON lines to the file for each processed file.
+// It is safe for concurrent use.
+type FileLedger struct {
+	path string
+
+	mu     sync.Mutex
+	loaded bool
+
+	// statuses are the statuses of the last entries of the files.
+	statuses map[ledgerKey]string
+}
+
+type ledgerKey struct {
+	name    string
+	size    int64
+	modTime int64
+}
+
+func newLedgerKey(name string, size int64, modTime time.Time) ledgerKey {
+	return ledgerKey{name: name, size: size, modTime: modTime.UnixNano()}
+}
+
+func NewFileLedger(path string) *FileLedger {
+	return &FileLedger{
+		path:     path,
+		statuses: make(map[ledgerKey]string),
+	}
+}
+
+func (l *FileLedger) Processed(f File) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return false, err
+	}
+	return l.statuses[newLedgerKey(f.Name, f.Size, f.ModTime)] == LedgerStatusCompleted, nil
+}
+
+func (l *FileLedger) Record(f File, err error) error {
+	entry := LedgerEntry{
+		Name:        f.Name,
+		Size:        f.Size,
+		ModTime:     f.ModTime,
+		Status:      LedgerStatusCompleted,
+		ProcessedAt: time.Now(),
+	}
+	if err != nil {
+		entry.Status = LedgerStatusFailed
+		entry.Error = err.Error()
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	l.statuses[newLedgerKey(f.Name, f.Size, f.ModTime)] = entry.Status
+	return nil
+}
+
+// load reads the file once.
+// The file not existing is regarded as empty.
+func (l *FileLedger) load() error {
+	if l.loaded {
+		return nil
+	}
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		l.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return err
+		}
+		l.statuses[newLedgerKey(entry.Name, entry.Size, entry.ModTime)] = entry.Status
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	l.loaded = true
+	return nil
+}
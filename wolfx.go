@@ -1,7 +1,9 @@
 package wolfx
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/yackrru/gogger"
 	"github.com/yackrru/wolfx/middleware"
@@ -9,6 +11,11 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -22,6 +29,11 @@ A batch framework for golang.
 `
 )
 
+var (
+	// ErrJobNotFound is returned when no JobExecutor has the job name.
+	ErrJobNotFound = errors.New("Not found job name")
+)
+
 // WolfX is the top-level framework instance.
 // It is the collection of batch jobs.
 type WolfX struct {
@@ -31,7 +43,30 @@ type WolfX struct {
 	ArtOFF bool
 
 	// LogLevel is gogger's LogLevel.
+	// It applies to the logger created by WolfX
+	// and is ignored if middleware.Logger is configured by the user.
+	// The logger is shared by the WolfX instances running jobs at the same time,
+	// so the LogLevel of the first of them applies.
 	LogLevel gogger.LogLevel
+
+	// Listeners are notified of the lifecycle of jobs, flows, steps and chunks.
+	Listeners []Listener
+
+	// ProgressInterval is the interval to report the progress of running steps.
+	// If specify 0, the progress is not reported periodically.
+	ProgressInterval time.Duration
+
+	// ProgressChunks is the number of chunks to report the progress
+	// of running steps.
+	// If specify 0, the progress is not reported by chunks.
+	ProgressChunks uint64
+
+	// Repository stores the job executions.
+	// If it is nil, an in-memory JobRepository is used.
+	Repository JobRepository
+
+	mu      sync.Mutex
+	running map[int64]*middleware.JobExecution
 }
 
 // New returns a WolfX instance.
@@ -43,45 +78,92 @@ func New() *WolfX {
 //
 // Arg jobName must be corresponded one of the name of WolfX.JobExecutors.
 func (wx *WolfX) Run(jobName string) error {
-	logWriter := gogger.NewLogStreamWriter(gogger.LogStreamWriterOption{
-		Output: os.Stderr,
-	})
-	logWriter.Open()
-	defer logWriter.Close()
-	conf := &gogger.LogConfig{
-		Writers:   []gogger.LogWriter{logWriter},
-		Formatter: gogger.NewLogSimpleFormatter(gogger.DefaultLogSimpleFormatterTmpl),
-	}
-	if wx.LogLevel > gogger.LevelDefault {
-		conf.LogMinLevel = wx.LogLevel
-	}
-	middleware.Logger = gogger.NewLog(conf)
+	return wx.RunWithParameters(jobName, nil)
+}
+
+// RunWithParameters boots WolfX application
+// and runs the job with the job parameters.
+func (wx *WolfX) RunWithParameters(jobName string, params middleware.JobParameters) error {
+	defer wx.useDefaultLogger()()
 
 	if !wx.ArtOFF {
 		fmt.Fprintf(os.Stdout, "%s\n", art)
 	}
 	middleware.Logger.Info("Launched WolfX application.")
 
-	for _, e := range wx.JobExecutors {
-		if jobName == e.Name() {
-			middleware.Logger.Infof("Target job: %s", jobName)
-			err := e.Run()
+	je, err := wx.Launch(jobName, params)
+	if err != nil {
+		middleware.Logger.Error(err.Error())
+		middleware.Logger.Info("Terminate WolfX application...")
+		return err
+	}
 
-			if err == nil {
-				middleware.Logger.Info("Completed WolfX application.")
-			} else {
-				middleware.Logger.Error("Errors have occurred.")
-			}
-			middleware.Logger.Info("Terminate WolfX application...")
+	err = je.Wait()
+	if err == nil {
+		middleware.Logger.Info("Completed WolfX application.")
+	} else {
+		middleware.Logger.Error("Errors have occurred.")
+	}
+	middleware.Logger.Info("Terminate WolfX application...")
 
-			return err
-		}
+	return err
+}
+
+var (
+	loggerMu sync.Mutex
+	// defaultLogger is the logger created by WolfX.
+	// Its writer is closed when the last of its users ends,
+	// but it is kept in middleware.Logger.
+	defaultLogger      gogger.Logger
+	defaultLogWriter   *gogger.LogStreamWriter
+	defaultLoggerUsers int
+)
+
+// useDefaultLogger sets the logger created by WolfX to middleware.Logger
+// unless a logger is configured by the user.
+// It returns the function to end using the logger,
+// which closes the writer to flush the logs
+// when no one else uses the logger.
+func (wx *WolfX) useDefaultLogger() func() {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	if middleware.Logger != nil && middleware.Logger != defaultLogger {
+		return func() {}
+	}
+	if defaultLoggerUsers == 0 {
+		defaultLogWriter = gogger.NewLogStreamWriter(gogger.LogStreamWriterOption{
+			Output: os.Stderr,
+		})
+		defaultLogWriter.Open()
+		defaultLogger = wx.newLogger(defaultLogWriter)
+		middleware.Logger = defaultLogger
 	}
+	defaultLoggerUsers++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			loggerMu.Lock()
+			defer loggerMu.Unlock()
+			defaultLoggerUsers--
+			if defaultLoggerUsers > 0 {
+				return
+			}
+			defaultLogWriter.Close()
+			defaultLogWriter = nil
+		})
+	}
+}
 
-	errStr := "Not found job name: " + jobName
-	middleware.Logger.Error(errStr)
-	middleware.Logger.Info("Terminate WolfX application...")
-	return fmt.Errorf(errStr)
+func (wx *WolfX) newLogger(w gogger.LogWriter) gogger.Logger {
+	conf := &gogger.LogConfig{
+		Writers:   []gogger.LogWriter{w},
+		Formatter: gogger.NewLogSimpleFormatter(gogger.DefaultLogSimpleFormatterTmpl),
+	}
+	if wx.LogLevel > gogger.LevelDefault {
+		conf.LogMinLevel = wx.LogLevel
+	}
+	return middleware.NewGoggerLogger(conf)
 }
 
 // Add adds JobExecutor to the WolfX instance.
@@ -90,6 +172,191 @@ func (wx *WolfX) Add(e JobExecutor) *WolfX {
 	return wx
 }
 
+// AddListener adds Listener to the WolfX instance.
+func (wx *WolfX) AddListener(l Listener) *WolfX {
+	wx.Listeners = append(wx.Listeners, l)
+	return wx
+}
+
+// Launch starts the job asynchronously and returns its JobExecution.
+// The result of the job is given by JobExecution.Wait.
+//
+// The logger created by WolfX is used until the job ends
+// unless middleware.Logger is configured by the user.
+func (wx *WolfX) Launch(jobName string, params middleware.JobParameters) (*middleware.JobExecution, error) {
+	var executor JobExecutor
+	for _, e := range wx.JobExecutors {
+		if jobName == e.Name() {
+			executor = e
+			break
+		}
+	}
+	if executor == nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, jobName)
+	}
+
+	je := middleware.NewJobExecution(jobName, params)
+	rec := je.Record()
+	if err := wx.repository().SaveJobExecution(&rec); err != nil {
+		return nil, err
+	}
+	endLogger := wx.useDefaultLogger()
+	je.ID = rec.ID
+
+	wx.mu.Lock()
+	if wx.running == nil {
+		wx.running = make(map[int64]*middleware.JobExecution)
+	}
+	wx.running[je.ID] = je
+	wx.mu.Unlock()
+
+	middleware.LoggerFromContext(middleware.WithJobExecution(context.Background(), je)).
+		Infof("Target job: %s", jobName)
+	go wx.execute(executor, je, endLogger)
+
+	return je, nil
+}
+
+func (wx *WolfX) execute(e JobExecutor, je *middleware.JobExecution, endLogger func()) {
+	defer je.Close()
+	defer endLogger()
+	defer func() {
+		wx.mu.Lock()
+		delete(wx.running, je.ID)
+		wx.mu.Unlock()
+	}()
+
+	repo := wx.repository()
+	ls := append(listeners{&repositoryListener{repo: repo}}, wx.Listeners...)
+	if wx.ProgressInterval > 0 || wx.ProgressChunks > 0 {
+		ls = append(ls, newProgressReporter(wx.ProgressInterval, wx.ProgressChunks))
+	}
+	ctx := middleware.WithJobExecution(context.Background(), je)
+	ctx = withListeners(ctx, ls)
+	je.Start()
+	ctx = ls.beforeJob(ctx, je)
+
+	if ce, ok := e.(ContextJobExecutor); ok {
+		je.Finish(ce.RunContext(ctx))
+	} else {
+		je.Finish(runJob(ctx, e))
+	}
+	ls.afterJob(ctx, je)
+}
+
+// jobContexts are the contexts of the jobs being invoked by JobExecutor.Run
+// keyed by the ID of the goroutine calling it.
+var jobContexts sync.Map
+
+// runJob calls e.Run with ctx picked up by NewJobBuilder
+// called in the same goroutine.
+func runJob(ctx context.Context, e JobExecutor) error {
+	id := goroutineID()
+	jobContexts.Store(id, ctx)
+	defer jobContexts.Delete(id)
+	return e.Run()
+}
+
+// goroutineID returns the ID of the current goroutine
+// from the header of its stack trace, e.g. "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
+
+func (wx *WolfX) repository() JobRepository {
+	wx.mu.Lock()
+	defer wx.mu.Unlock()
+	if wx.Repository == nil {
+		wx.Repository = NewMemoryJobRepository()
+	}
+	return wx.Repository
+}
+
+// RunningExecutions returns the executions of running jobs.
+func (wx *WolfX) RunningExecutions() []*middleware.JobExecution {
+	wx.mu.Lock()
+	defer wx.mu.Unlock()
+
+	executions := make([]*middleware.JobExecution, 0, len(wx.running))
+	for _, je := range wx.running {
+		executions = append(executions, je)
+	}
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].ID < executions[j].ID
+	})
+	return executions
+}
+
+// Stop requests the running job execution of the ID to stop gracefully.
+func (wx *WolfX) Stop(id int64) error {
+	wx.mu.Lock()
+	je, ok := wx.running[id]
+	wx.mu.Unlock()
+	if !ok {
+		return ErrJobExecutionNotFound
+	}
+	je.Stop()
+	return nil
+}
+
+// FindJobExecution returns the record of the job execution of the ID.
+// The record of a running job reflects its latest progress.
+func (wx *WolfX) FindJobExecution(id int64) (*middleware.JobExecutionRecord, error) {
+	wx.mu.Lock()
+	je, ok := wx.running[id]
+	wx.mu.Unlock()
+	if ok {
+		rec := je.Record()
+		return &rec, nil
+	}
+	return wx.repository().FindJobExecution(id)
+}
+
+// FindJobExecutions returns the records of job executions from the repository.
+func (wx *WolfX) FindJobExecutions(q JobExecutionQuery) (*JobExecutionPage, error) {
+	return wx.repository().FindJobExecutions(q)
+}
+
+// repositoryListener saves the job execution to the repository
+// at the end of each step and the job.
+type repositoryListener struct {
+	repo JobRepository
+}
+
+func (l *repositoryListener) BeforeStep(ctx context.Context, se *middleware.StepExecution) context.Context {
+	return ctx
+}
+
+func (l *repositoryListener) AfterStep(ctx context.Context, se *middleware.StepExecution) {
+	l.save(ctx, se.JobExecution)
+}
+
+func (l *repositoryListener) BeforeJob(ctx context.Context, je *middleware.JobExecution) context.Context {
+	l.save(ctx, je)
+	return ctx
+}
+
+func (l *repositoryListener) AfterJob(ctx context.Context, je *middleware.JobExecution) {
+	l.save(ctx, je)
+}
+
+func (l *repositoryListener) save(ctx context.Context, je *middleware.JobExecution) {
+	if je == nil {
+		return
+	}
+	rec := je.Record()
+	if err := l.repo.SaveJobExecution(&rec); err != nil {
+		middleware.LoggerFromContext(ctx).Error("Failed to save job execution: ", err)
+	}
+}
+
 // JobExecutor is the top-level batch job.
 //
 // A job has a unique name and a single bootstrap.
@@ -103,6 +370,20 @@ type JobExecutor interface {
 	Run() error
 }
 
+// ContextJobExecutor is the JobExecutor which receives the context of the job.
+//
+// WolfX calls RunContext instead of Run if the JobExecutor implements it.
+// The context holds the JobExecution, the job parameters and the listeners,
+// and is handed down to the steps by passing it to NewJobBuilderContext.
+// A job invoked by Run gets the same context by NewJobBuilder
+// called in the goroutine of Run.
+type ContextJobExecutor interface {
+	JobExecutor
+
+	// RunContext invokes bootstrap with the context of the job.
+	RunContext(ctx context.Context) error
+}
+
 var (
 	_ JobBuilderAPI  = new(JobBuilder)
 	_ StepBuilderAPI = new(StepBuilder)
@@ -134,38 +415,83 @@ type ConcurrentJob interface {
 
 // JobBuilder implements JobBuilderAPI.
 type JobBuilder struct {
+	ctx   context.Context
 	Flows []Flow
 }
 
 // Flow holds the steps in execution units.
 type Flow []Step
 
+// NewJobBuilder returns a JobBuilder.
+// If it is called by JobExecutor.Run invoked by WolfX,
+// the steps are run with the context of the job as NewJobBuilderContext.
+// It is not picked up in the goroutines started by Run.
 func NewJobBuilder() *JobBuilder {
 	builder := new(JobBuilder)
+	if ctx, ok := jobContexts.Load(goroutineID()); ok {
+		builder.ctx = ctx.(context.Context)
+	}
 	return builder
 }
 
+// NewJobBuilderContext returns a JobBuilder
+// which runs the steps with the context of the job.
+func NewJobBuilderContext(ctx context.Context) *JobBuilder {
+	return &JobBuilder{
+		ctx: ctx,
+	}
+}
+
 func (b *JobBuilder) Build() error {
-	for _, flow := range b.Flows {
+	jobCtx := b.ctx
+	if jobCtx == nil {
+		jobCtx = context.Background()
+	}
+	je := middleware.JobExecutionFromContext(jobCtx)
+	ls := listenersFromContext(jobCtx)
+	logger := middleware.LoggerFromContext(jobCtx)
+
+	for idx, flow := range b.Flows {
+		if je != nil && je.IsStopping() {
+			logger.Info("Job execution stopped")
+			return middleware.ErrStopped
+		}
 		if len(flow) == 1 {
-			middleware.Logger.Info("Start single step")
+			logger.Info("Start single step")
 		} else {
-			middleware.Logger.Infof("Start %d steps parallelly", len(flow))
+			logger.Infof("Start %d steps parallelly", len(flow))
 		}
 
-		eg, ctx := errgroup.WithContext(context.Background())
-		for _, step := range flow {
+		fe := &middleware.FlowExecution{
+			JobExecution: je,
+			Index:        idx,
+			StartTime:    time.Now(),
+		}
+		fNames := make([]string, len(flow))
+		for i, step := range flow {
+			fValue := reflect.ValueOf(step)
+			fNames[i] = runtime.FuncForPC(fValue.Pointer()).Name()
+			fe.StepNames = append(fe.StepNames, stepName(fNames[i]))
+		}
+		flowCtx := ls.beforeFlow(jobCtx, fe)
+
+		eg, ctx := errgroup.WithContext(flowCtx)
+		for i, step := range flow {
 			step := step
+			fName := fNames[i]
+			se := middleware.NewStepExecution(je, fe.StepNames[i])
 			eg.Go(func() error {
-				fValue := reflect.ValueOf(step)
-				fName := runtime.FuncForPC(fValue.Pointer()).Name()
-				middleware.Logger.Infof("Execute step: %s", fName)
-				return step(ctx)
+				return runStep(ctx, step, fName, se, ls)
 			})
 		}
 
-		if err := eg.Wait(); err != nil {
-			middleware.Logger.Error("Step execution canceled: ", err)
+		err := eg.Wait()
+		fe.EndTime = time.Now()
+		fe.Err = err
+		ls.afterFlow(flowCtx, fe)
+
+		if err != nil {
+			logger.Error("Step execution canceled: ", err)
 			return err
 		}
 	}
@@ -173,6 +499,31 @@ func (b *JobBuilder) Build() error {
 	return nil
 }
 
+func runStep(ctx context.Context, step Step, fName string,
+	se *middleware.StepExecution, ls listeners) error {
+
+	se.Start()
+	if se.JobExecution != nil {
+		se.JobExecution.AddStepExecution(se)
+	}
+	ctx = middleware.WithStepExecution(ctx, se)
+	middleware.LoggerFromContext(ctx).Infof("Execute step: %s", fName)
+	ctx = ls.beforeStep(ctx, se)
+	se.Finish(step(ctx))
+	ls.afterStep(ctx, se)
+
+	return se.Err
+}
+
+// stepName returns the method name from the full function name of Step.
+func stepName(fName string) string {
+	name := strings.TrimSuffix(fName, "-fm")
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return name
+}
+
 func (b *JobBuilder) Single(s Step) *JobBuilder {
 	b.Flows = append(b.Flows, Flow{s})
 	return b
@@ -238,19 +589,83 @@ func (b *StepBuilder) Build() error {
 		return fmt.Errorf("ERROR: Writer must be set.")
 	}
 
-	eg, ctx := errgroup.WithContext(b.ctx)
-	ch := make(chan interface{})
+	stepCtx := b.ctx
+	se := middleware.StepExecutionFromContext(stepCtx)
+	if se == nil {
+		// The step is run outside JobBuilder,
+		// so it is finished here to call the functions of OnEnd.
+		se = middleware.NewStepExecution(middleware.JobExecutionFromContext(stepCtx), "")
+		se.Start()
+		se.Finish(b.run(middleware.WithStepExecution(stepCtx, se), se))
+		return se.Err
+	}
+	return b.run(stepCtx, se)
+}
+
+// run runs the reader and the writer of the step.
+// It returns after both of them return even if the step is canceled,
+// so that they have registered their functions of OnEnd.
+func (b *StepBuilder) run(stepCtx context.Context, se *middleware.StepExecution) error {
+	ls := listenersFromContext(stepCtx)
+	var stopping <-chan struct{}
+	if se.JobExecution != nil {
+		stopping = se.JobExecution.Stopping()
+	}
+
+	eg, ctx := errgroup.WithContext(stepCtx)
+	// The reader is canceled alone at stopping,
+	// so that the writer can finish the chunks already received.
+	readerCtx, cancelReader := context.WithCancel(ctx)
+	defer cancelReader()
+	chunks := &chunkScope{
+		ctx:    ctx,
+		se:     se,
+		ls:     ls,
+		chunks: make(map[uint64]*scopedChunk),
+	}
+	// The 1st chunk begins before the reader starts reading it.
+	chunks.chunk(0)
+	readerCtx = middleware.WithChunkContext(readerCtx, chunks.context)
+	writerCtx := middleware.WithChunkContext(ctx, chunks.context)
+	readerCh := make(chan interface{})
+	writerCh := make(chan interface{})
+	readerDone := make(chan struct{})
+	var stopped bool
+	var unwritten uint64
+	var workers sync.WaitGroup
 	// Run reader
 	eg.Go(func() error {
-		return readerWorker(ctx, ch, b.Reader)
+		defer close(readerDone)
+		err := readerWorker(readerCtx, readerCh, b.Reader, &workers)
+		if err != nil && readerCtx.Err() != nil && ctx.Err() == nil {
+			// Canceled by stopping.
+			return nil
+		}
+		return err
 	})
 	// Run writer
 	eg.Go(func() error {
-		return writerWorker(ctx, ch, b.Writer)
+		return writerWorker(writerCtx, writerCh, b.Writer, &workers)
+	})
+	// Pass chunks from reader to writer
+	eg.Go(func() error {
+		var err error
+		stopped, err = chunkWorker(ctx, readerCh, writerCh, readerDone,
+			stopping, cancelReader, chunks, &unwritten)
+		return err
 	})
-	if err := eg.Wait(); err != nil {
+	err := eg.Wait()
+	waitWorkers(&workers, readerCh)
+	if err != nil {
 		return err
 	}
+	// The writer returned after writing the last chunk.
+	se.AddWriteCount(unwritten)
+	chunks.written()
+	if stopped {
+		middleware.LoggerFromContext(stepCtx).Info("Step execution stopped")
+		return middleware.ErrStopped
+	}
 
 	return nil
 }
@@ -265,14 +680,18 @@ func (b *StepBuilder) SetWriter(w middleware.Writer) *StepBuilder {
 	return b
 }
 
-func readerWorker(ctx context.Context, ch chan<- interface{}, reader middleware.Reader) error {
+func readerWorker(ctx context.Context, ch chan<- interface{}, reader middleware.Reader,
+	workers *sync.WaitGroup) error {
+
 	var err error
+	rv := reflect.ValueOf(reader)
+	middleware.LoggerFromContext(ctx).Infof("Use reader: %s", rv.Type())
 	worker := func() <-chan interface{} {
 		terminated := make(chan interface{})
+		workers.Add(1)
 		go func() {
+			defer workers.Done()
 			defer close(terminated)
-			rv := reflect.ValueOf(reader)
-			middleware.Logger.Infof("Use reader: %s", rv.Type())
 			err = reader.Read(ctx, ch)
 		}()
 		return terminated
@@ -287,14 +706,18 @@ func readerWorker(ctx context.Context, ch chan<- interface{}, reader middleware.
 	return err
 }
 
-func writerWorker(ctx context.Context, ch <-chan interface{}, writer middleware.Writer) error {
+func writerWorker(ctx context.Context, ch <-chan interface{}, writer middleware.Writer,
+	workers *sync.WaitGroup) error {
+
 	var err error
+	wv := reflect.ValueOf(writer)
+	middleware.LoggerFromContext(ctx).Infof("Use writer: %s", wv.Type())
 	worker := func() <-chan interface{} {
 		terminated := make(chan interface{})
+		workers.Add(1)
 		go func() {
+			defer workers.Done()
 			defer close(terminated)
-			wv := reflect.ValueOf(writer)
-			middleware.Logger.Infof("Use writer: %s", wv.Type())
 			err = writer.Write(ctx, ch)
 		}()
 		return terminated
@@ -307,5 +730,180 @@ func writerWorker(ctx context.Context, ch <-chan interface{}, writer middleware.
 	}
 
 	return err
+}
+
+// waitWorkers waits for the reader and the writer to return,
+// draining the chunks which the canceled reader is still sending.
+// It has no timeout, so that the functions of OnEnd are registered
+// before the step finishes; it relies on middleware.Reader
+// and middleware.Writer returning when their context is canceled.
+func waitWorkers(workers *sync.WaitGroup, readerCh <-chan interface{}) {
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case _, ok := <-readerCh:
+			if !ok {
+				readerCh = nil
+			}
+		}
+	}
+}
+
+// chunkScope holds the chunks of a step being processed
+// by the reader or the writer.
+type chunkScope struct {
+	ctx context.Context
+	se  *middleware.StepExecution
+	ls  listeners
+
+	mu     sync.Mutex
+	chunks map[uint64]*scopedChunk
+	// released is the index of the oldest chunk not released.
+	released uint64
+	// unwritten is the last chunk passed to the writer,
+	// which is processed when the writer returns without error.
+	unwritten *middleware.ChunkExecution
+}
+
+// scopedChunk is a chunk and its context.
+type scopedChunk struct {
+	ce  *middleware.ChunkExecution
+	ctx context.Context
+}
+
+// chunk returns the chunk of idx, beginning it if it has not begun.
+// It is called by the reader, the writer and chunkWorker,
+// so that the chunk begins as soon as the reader begins reading it.
+// It returns nil if the chunk has been released.
+func (s *chunkScope) chunk(idx uint64) *scopedChunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx < s.released {
+		return nil
+	}
+	if c, ok := s.chunks[idx]; ok {
+		return c
+	}
+	ce := &middleware.ChunkExecution{
+		StepExecution: s.se,
+		Index:         idx,
+		StartTime:     time.Now(),
+	}
+	ctx := middleware.WithChunkExecution(middleware.WithChunkIndex(s.ctx, idx), ce)
+	c := &scopedChunk{
+		ce:  ce,
+		ctx: s.ls.beforeChunk(ctx, ce),
+	}
+	s.chunks[idx] = c
+	return c
+}
+
+// context returns the context of the chunk of idx for middleware.NextChunkContext.
+func (s *chunkScope) context(idx uint64) context.Context {
+	c := s.chunk(idx)
+	if c == nil {
+		return nil
+	}
+	return c.ctx
+}
+
+// release releases the chunks before idx,
+// which neither the reader nor the writer processes any more,
+// and notifies that the writer has processed them.
+func (s *chunkScope) release(idx uint64) {
+	s.mu.Lock()
+	var written []*middleware.ChunkExecution
+	for ; s.released < idx; s.released++ {
+		if c, ok := s.chunks[s.released]; ok {
+			written = append(written, c.ce)
+		}
+		delete(s.chunks, s.released)
+	}
+	s.unwritten = nil
+	if c, ok := s.chunks[idx]; ok {
+		s.unwritten = c.ce
+	}
+	s.mu.Unlock()
 
+	for _, ce := range written {
+		ce.Written()
+	}
+}
+
+// written notifies that the writer has processed the last chunk.
+func (s *chunkScope) written() {
+	s.mu.Lock()
+	ce := s.unwritten
+	s.unwritten = nil
+	s.mu.Unlock()
+	if ce != nil {
+		ce.Written()
+	}
+}
+
+// chunkWorker passes chunks from reader to writer and
+// records them on the StepExecution.
+// The items of a chunk are counted as written when the writer receives
+// the next chunk, which means it has processed the chunk.
+// The items of the last chunk are left in unwritten.
+// It reports true if the step is stopped before the reader finished.
+func chunkWorker(ctx context.Context, readerCh <-chan interface{},
+	writerCh chan<- interface{}, readerDone <-chan struct{},
+	stopping <-chan struct{}, cancelReader context.CancelFunc,
+	chunks *chunkScope, unwritten *uint64) (bool, error) {
+
+	defer close(writerCh)
+
+	se, ls := chunks.se, chunks.ls
+	for idx := uint64(0); ; idx++ {
+		start := time.Now()
+		var chunk interface{}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-stopping:
+			cancelReader()
+			return true, nil
+		case <-readerDone:
+			// The reader returned without closing the channel.
+			return false, nil
+		case c, ok := <-readerCh:
+			if !ok {
+				return false, nil
+			}
+			chunk = c
+		}
+		received := time.Now()
+		c := chunks.chunk(idx)
+		// The reader begins reading the next chunk after sending the chunk.
+		chunks.chunk(idx + 1)
+		items := middleware.CountItems(chunk)
+		se.AddReadCount(items)
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case writerCh <- chunk:
+		}
+		end := time.Now()
+		se.AddWriteCount(*unwritten)
+		*unwritten = items
+		se.AddChunkCount(1)
+		// The writer has received the chunk after processing the previous one.
+		chunks.release(idx)
+
+		middleware.LoggerFromContext(c.ctx).
+			Debugf("Passed chunk of %d items to writer", items)
+		c.ce.Items = items
+		c.ce.EndTime = end
+		c.ce.ReadWait = received.Sub(start)
+		c.ce.WriteWait = end.Sub(received)
+		ls.afterChunk(c.ctx, c.ce)
+	}
 }
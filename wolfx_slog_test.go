@@ -0,0 +1,43 @@
+//go:build go1.21
+
+package wolfx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yackrru/gogger"
+	"github.com/yackrru/wolfx"
+	"github.com/yackrru/wolfx/middleware"
+	"strings"
+	"testing"
+)
+
+func TestContextualLogging(t *testing.T) {
+	var buf bytes.Buffer
+	middleware.Logger = middleware.NewJSONLogger(&buf, gogger.LevelInfo)
+	defer func() { middleware.Logger = nil }()
+
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.Add(NewBarJob(t))
+	if err := wx.Run("BarJob"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stepLogs int
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if msg, _ := entry["msg"].(string); strings.HasPrefix(msg, "Execute step") {
+			stepLogs++
+			assert.Equal(t, "BarJob", entry[middleware.FieldJob])
+			assert.NotNil(t, entry[middleware.FieldExecutionID])
+			assert.Contains(t, []interface{}{"EchoStep", "WEchoStep"}, entry[middleware.FieldStep])
+		}
+	}
+	assert.Equal(t, 2, stepLogs)
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/yackrru/gogger"
 	"github.com/yackrru/wolfx"
 	"github.com/yackrru/wolfx/middleware"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -179,6 +181,27 @@ func (w *WEchoWriter) Write(ctx context.Context, ch <-chan interface{}) error {
 	return nil
 }
 
+func TestConcurrentJobs(t *testing.T) {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	wx.Add(NewBarJob(t))
+
+	var executions []*middleware.JobExecution
+	for i := 0; i < 2; i++ {
+		je, err := wx.Launch("BarJob", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		executions = append(executions, je)
+	}
+	for _, je := range executions {
+		assert.NoError(t, je.Wait())
+		rec := je.Record()
+		assert.Len(t, rec.Steps, 2)
+	}
+}
+
 func TestJobExecutionWithCancel(t *testing.T) {
 	wx := wolfx.New()
 	wx.ArtOFF = true
@@ -290,3 +313,236 @@ func (w *SleepWriter) Write(ctx context.Context, ch <-chan interface{}) error {
 	time.Sleep(10 * time.Second)
 	return nil
 }
+
+func TestListeners(t *testing.T) {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	listener := new(RecordListener)
+	wx.Add(NewBarJob(t)).AddListener(listener)
+
+	if err := wx.Run("BarJob"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		"BeforeJob BarJob",
+		"BeforeFlow 0 [EchoStep]",
+		"BeforeStep EchoStep",
+		"AfterChunk EchoStep 0 1",
+		"AfterStep EchoStep 1 1",
+		"AfterFlow 0",
+		"BeforeFlow 1 [WEchoStep]",
+		"BeforeStep WEchoStep",
+		"AfterChunk WEchoStep 0 1",
+		"AfterChunk WEchoStep 1 1",
+		"AfterStep WEchoStep 2 2",
+		"AfterFlow 1",
+		"AfterJob BarJob",
+	}, listener.events)
+}
+
+type RecordListener struct {
+	events []string
+}
+
+func (l *RecordListener) BeforeJob(ctx context.Context, je *middleware.JobExecution) context.Context {
+	l.events = append(l.events, "BeforeJob "+je.JobName)
+	return ctx
+}
+
+func (l *RecordListener) AfterJob(ctx context.Context, je *middleware.JobExecution) {
+	l.events = append(l.events, "AfterJob "+je.JobName)
+}
+
+func (l *RecordListener) BeforeFlow(ctx context.Context, fe *middleware.FlowExecution) context.Context {
+	l.events = append(l.events, fmt.Sprintf("BeforeFlow %d %v", fe.Index, fe.StepNames))
+	return ctx
+}
+
+func (l *RecordListener) AfterFlow(ctx context.Context, fe *middleware.FlowExecution) {
+	l.events = append(l.events, fmt.Sprintf("AfterFlow %d", fe.Index))
+}
+
+func (l *RecordListener) BeforeStep(ctx context.Context, se *middleware.StepExecution) context.Context {
+	l.events = append(l.events, "BeforeStep "+se.StepName)
+	return ctx
+}
+
+func (l *RecordListener) AfterStep(ctx context.Context, se *middleware.StepExecution) {
+	l.events = append(l.events, fmt.Sprintf("AfterStep %s %d %d",
+		se.StepName, se.ReadCount(), se.WriteCount()))
+}
+
+func (l *RecordListener) AfterChunk(ctx context.Context, ce *middleware.ChunkExecution) {
+	l.events = append(l.events, fmt.Sprintf("AfterChunk %s %d %d",
+		ce.StepExecution.StepName, ce.Index, ce.Items))
+}
+
+func TestDefaultLogger(t *testing.T) {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	wx.Add(NewBarJob(t))
+
+	je, err := wx.Launch("BarJob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, je.Wait())
+	// The logger created by WolfX is kept when the job ends.
+	assert.NotNil(t, middleware.Logger)
+
+	assert.NoError(t, wx.Run("BarJob"))
+	assert.NotPanics(t, func() { middleware.Logger.Info("After run") })
+}
+
+func TestProgressReporting(t *testing.T) {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	wx.ProgressChunks = 1
+	listener := new(ProgressRecordListener)
+	wx.Add(NewBarJob(t)).AddListener(listener)
+
+	if err := wx.Run("BarJob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The items of a chunk are counted as written
+	// when the writer receives the next chunk.
+	assert.Equal(t, []string{
+		"EchoStep 0 items",
+		"WEchoStep 0 items",
+		"WEchoStep 1 items",
+	}, listener.events)
+}
+
+type ProgressRecordListener struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *ProgressRecordListener) OnProgress(ctx context.Context, p *middleware.Progress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf("%s %d %s", p.StepName, p.Done, p.Unit))
+}
+
+func TestOnStepEnd(t *testing.T) {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	writer := new(CommitWriter)
+	wx.Add(&BazJob{
+		reader: new(EchoReader),
+		writer: writer,
+	})
+
+	err := wx.Run("BazJob")
+	assert.EqualError(t, err, "Commit Error")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&writer.ended))
+}
+
+func TestOnStepEndWithoutJob(t *testing.T) {
+	writer := new(CommitWriter)
+	err := wolfx.NewStepBuilder(context.Background()).
+		SetReader(new(EchoReader)).
+		SetWriter(writer).
+		Build()
+	assert.EqualError(t, err, "Commit Error")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&writer.ended))
+}
+
+func TestOnEndAfterFinish(t *testing.T) {
+	se := middleware.NewStepExecution(nil, "FooStep")
+	se.Finish(fmt.Errorf("Step Error"))
+
+	var got error
+	se.OnEnd(func(err error) error {
+		got = err
+		return nil
+	})
+	assert.EqualError(t, got, "Step Error")
+}
+
+// CommitWriter fails to publish its output at the end of step.
+type CommitWriter struct {
+	ended int32
+}
+
+func (w *CommitWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	<-ch
+	middleware.OnStepEnd(ctx, func(err error) error {
+		atomic.AddInt32(&w.ended, 1)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("Commit Error")
+	})
+	return nil
+}
+
+func TestStopJobInvokedByRun(t *testing.T) {
+	wx := wolfx.New()
+	wx.ArtOFF = true
+	wx.LogLevel = gogger.LevelOff
+	wx.Add(new(EndlessJob))
+
+	je, err := wx.Launch("EndlessJob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(je.Record().Steps) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	je.Stop()
+	assert.ErrorIs(t, je.Wait(), middleware.ErrStopped)
+	rec := je.Record()
+	if assert.Len(t, rec.Steps, 1) {
+		assert.Equal(t, "EndlessStep", rec.Steps[0].StepName)
+	}
+}
+
+// EndlessJob is invoked by Run and runs until it is stopped.
+type EndlessJob struct{}
+
+func (j *EndlessJob) Name() string {
+	return "EndlessJob"
+}
+
+func (j *EndlessJob) Run() error {
+	return wolfx.NewJobBuilder().
+		Single(j.EndlessStep).
+		Single(j.EndlessStep).
+		Build()
+}
+
+func (j *EndlessJob) EndlessStep(ctx context.Context) error {
+	return wolfx.NewStepBuilder(ctx).
+		SetReader(new(EndlessReader)).
+		SetWriter(new(DiscardWriter)).
+		Build()
+}
+
+type EndlessReader struct{}
+
+func (r *EndlessReader) Read(ctx context.Context, ch chan<- interface{}) error {
+	defer close(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ch <- []string{"a", "b"}:
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+type DiscardWriter struct{}
+
+func (w *DiscardWriter) Write(ctx context.Context, ch <-chan interface{}) error {
+	for range ch {
+	}
+	return nil
+}